@@ -0,0 +1,379 @@
+package main
+
+import (
+    "bufio"
+    "crypto/ed25519"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "encoding/pem"
+    "errors"
+    "fmt"
+    "io"
+    "math/big"
+    "os"
+    "os/user"
+    "strings"
+    "time"
+)
+
+// The prev_hash of the first entry in an audit log.
+var auditGenesisHash = strings.Repeat("0", 64)
+
+// The part of an audit entry covered by the hash chain. Only metadata about
+// the operation is recorded here: never the secret or any share values.
+type auditRecord struct {
+    Seq       int        `json:"seq"`
+    Time      string     `json:"time"`
+    Operation string     `json:"operation"`
+    SplitID   string     `json:"split_id,omitempty"`
+    N         int        `json:"n,omitempty"`
+    T         int        `json:"t,omitempty"`
+    Indices   []*big.Int `json:"indices,omitempty"`
+    Operator  string     `json:"operator"`
+    Success   bool       `json:"success"`
+    Error     string     `json:"error,omitempty"`
+    PrevHash  string     `json:"prev_hash"`
+}
+
+// A single line of the audit log. Hash is SHA-256 over the JSON encoding of
+// the embedded auditRecord, which includes the hash of the previous entry, so
+// editing, removing or reordering entries breaks the chain. Signature is an
+// optional Ed25519 signature over the raw hash bytes.
+type auditEntry struct {
+    auditRecord
+    Hash      string `json:"hash"`
+    Signature string `json:"signature,omitempty"`
+}
+
+// The part of an audit log's head covered by its signature.
+type auditHeadRecord struct {
+    Seq  int    `json:"seq"`
+    Hash string `json:"hash"`
+}
+
+// The head of an audit log, kept next to it in a file of the same name ending
+// in .head and rewritten on every append. It records how many entries the log
+// has and the hash of the last one, so that entries cut off the end of the
+// log, which leave a chain that is still valid, are noticed. Signature is an
+// optional Ed25519 signature over the SHA-256 of the JSON encoding of the
+// embedded auditHeadRecord.
+type auditHead struct {
+    auditHeadRecord
+    Signature string `json:"signature,omitempty"`
+}
+
+// Appends entries to a hash-chained audit log file. A nil *auditLog records
+// nothing, so callers do not need to check whether auditing is enabled.
+type auditLog struct {
+    path     string
+    key      ed25519.PrivateKey
+    operator string
+}
+
+// Returns an audit log writing to path, or nil if path is empty. If keyPath is
+// set, every entry is signed with the Ed25519 private key stored there.
+func newAuditLog(path, keyPath, operator string) (*auditLog, error) {
+    if path == "" {
+        if keyPath != "" {
+            return nil, errors.New("audit key given without an audit log")
+        }
+        return nil, nil
+    }
+    l := &auditLog{path: path, operator: operator}
+    if l.operator == "" {
+        l.operator = currentOperator()
+    }
+    if keyPath != "" {
+        key, err := loadPrivateKey(keyPath)
+        if err != nil {
+            return nil, err
+        }
+        l.key = key
+    }
+    return l, nil
+}
+
+// The identity of whoever is running the tool, for when no operator is given.
+func currentOperator() string {
+    if u, err := user.Current(); err == nil && u.Username != "" {
+        return u.Username
+    }
+    if name := os.Getenv("USER"); name != "" {
+        return name
+    }
+    return "unknown"
+}
+
+// Hashes the chained part of an audit entry.
+func hashAuditRecord(r auditRecord) (string, error) {
+    data, err := json.Marshal(r)
+    if err != nil {
+        return "", err
+    }
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:]), nil
+}
+
+// The file the head of the audit log at path is kept in.
+func auditHeadPath(path string) string {
+    return path + ".head"
+}
+
+// Hashes the signed part of an audit log head.
+func hashAuditHead(r auditHeadRecord) ([]byte, error) {
+    data, err := json.Marshal(r)
+    if err != nil {
+        return nil, err
+    }
+    sum := sha256.Sum256(data)
+    return sum[:], nil
+}
+
+// Reads the head of the audit log at path.
+func readAuditHead(path string) (auditHead, error) {
+    var head auditHead
+    data, err := os.ReadFile(auditHeadPath(path))
+    if err != nil {
+        return head, err
+    }
+    if err := json.Unmarshal(data, &head); err != nil {
+        return head, fmt.Errorf("%s: %v", auditHeadPath(path), err)
+    }
+    return head, nil
+}
+
+// Replaces the head of the log with one for entry e, signed if the log has a
+// key. The new head is written to a temporary file and renamed into place, so
+// the head is never left half written.
+func (l *auditLog) writeHead(e auditEntry) error {
+    head := auditHead{auditHeadRecord: auditHeadRecord{Seq: e.Seq, Hash: e.Hash}}
+    if l.key != nil {
+        digest, err := hashAuditHead(head.auditHeadRecord)
+        if err != nil {
+            return err
+        }
+        head.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(l.key, digest))
+    }
+    data, err := json.Marshal(head)
+    if err != nil {
+        return err
+    }
+    tmp := auditHeadPath(l.path) + ".tmp"
+    if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+        return err
+    }
+    return os.Rename(tmp, auditHeadPath(l.path))
+}
+
+// Reads every entry in the audit log at path.
+func readAuditLog(path string) ([]auditEntry, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    return readAuditEntries(f)
+}
+
+// Reads every entry of an audit log from r.
+func readAuditEntries(r io.Reader) ([]auditEntry, error) {
+    entries := []auditEntry{}
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+    for line := 1; scanner.Scan(); line++ {
+        if len(strings.TrimSpace(scanner.Text())) == 0 {
+            continue
+        }
+        var e auditEntry
+        if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+            return nil, fmt.Errorf("audit log line %d: %v", line, err)
+        }
+        entries = append(entries, e)
+    }
+    return entries, scanner.Err()
+}
+
+// Fills in the sequence number, chain hash and signature of r and appends it
+// to the log, creating it if need be. The operator and time are set here so
+// callers only describe the operation itself.
+func (l *auditLog) record(r auditRecord) error {
+    if l == nil {
+        return nil
+    }
+    f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    // Hold the log from reading the last entry until the new one is written,
+    // so that two operations at once cannot both chain off the same entry.
+    if err := lockFile(f); err != nil {
+        return err
+    }
+    entries, err := readAuditEntries(f)
+    if err != nil {
+        return err
+    }
+    r.Seq = 1
+    r.PrevHash = auditGenesisHash
+    if len(entries) > 0 {
+        last := entries[len(entries)-1]
+        r.Seq = last.Seq + 1
+        r.PrevHash = last.Hash
+    }
+    r.Time = time.Now().UTC().Format(time.RFC3339Nano)
+    r.Operator = l.operator
+
+    e := auditEntry{auditRecord: r}
+    e.Hash, err = hashAuditRecord(r)
+    if err != nil {
+        return err
+    }
+    if l.key != nil {
+        digest, _ := hex.DecodeString(e.Hash)
+        e.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(l.key, digest))
+    }
+    data, err := json.Marshal(e)
+    if err != nil {
+        return err
+    }
+    if _, err := f.Write(append(data, '\n')); err != nil {
+        return err
+    }
+    // The head is replaced while the log is still locked, so it always
+    // describes the entry appended last.
+    if err := l.writeHead(e); err != nil {
+        return err
+    }
+    return f.Close()
+}
+
+// Checks that every entry in the log hashes correctly and links to the one
+// before it. If pub is non-nil, every entry must also carry a valid signature
+// from the matching private key. The log must also end where its head says it
+// does, with the head signed by the same key, so that entries cut off the end
+// are noticed. Returns the number of entries checked. A missing or empty log
+// fails: every log that has been written to has at least one entry, so it has
+// been deleted or emptied.
+func verifyAuditLog(path string, pub ed25519.PublicKey) (int, error) {
+    entries, err := readAuditLog(path)
+    if errors.Is(err, os.ErrNotExist) {
+        return 0, fmt.Errorf("%s does not exist", path)
+    }
+    if err != nil {
+        return 0, err
+    }
+    if len(entries) == 0 {
+        return 0, fmt.Errorf("%s has no entries", path)
+    }
+    prev := auditGenesisHash
+    for i, e := range entries {
+        if e.Seq != i+1 {
+            return i, fmt.Errorf("entry %d: expected sequence number %d, got %d", i+1, i+1, e.Seq)
+        }
+        if e.PrevHash != prev {
+            return i, fmt.Errorf("entry %d: chain broken, previous hash does not match", e.Seq)
+        }
+        hash, err := hashAuditRecord(e.auditRecord)
+        if err != nil {
+            return i, err
+        }
+        if hash != e.Hash {
+            return i, fmt.Errorf("entry %d: hash mismatch, entry has been modified", e.Seq)
+        }
+        if pub != nil {
+            sig, err := base64.StdEncoding.DecodeString(e.Signature)
+            if e.Signature == "" || err != nil {
+                return i, fmt.Errorf("entry %d: missing or malformed signature", e.Seq)
+            }
+            digest, _ := hex.DecodeString(e.Hash)
+            if !ed25519.Verify(pub, digest, sig) {
+                return i, fmt.Errorf("entry %d: invalid signature", e.Seq)
+            }
+        }
+        prev = e.Hash
+    }
+    if err := verifyAuditHead(path, entries[len(entries)-1], pub); err != nil {
+        return len(entries), err
+    }
+    return len(entries), nil
+}
+
+// Checks that the head of the audit log at path names last as the final entry,
+// and if pub is non-nil that the head is signed by the matching private key.
+func verifyAuditHead(path string, last auditEntry, pub ed25519.PublicKey) error {
+    head, err := readAuditHead(path)
+    if errors.Is(err, os.ErrNotExist) {
+        return fmt.Errorf("%s does not exist", auditHeadPath(path))
+    }
+    if err != nil {
+        return err
+    }
+    if head.Seq != last.Seq {
+        return fmt.Errorf("log has %d entries but its head records %d, entries have been removed or added", last.Seq, head.Seq)
+    }
+    if head.Hash != last.Hash {
+        return fmt.Errorf("head does not match entry %d", last.Seq)
+    }
+    if pub != nil {
+        sig, err := base64.StdEncoding.DecodeString(head.Signature)
+        if head.Signature == "" || err != nil {
+            return errors.New("head: missing or malformed signature")
+        }
+        digest, err := hashAuditHead(head.auditHeadRecord)
+        if err != nil {
+            return err
+        }
+        if !ed25519.Verify(pub, digest, sig) {
+            return errors.New("head: invalid signature")
+        }
+    }
+    return nil
+}
+
+// Reads a PEM encoded PKCS #8 Ed25519 private key, as produced by
+// `openssl genpkey -algorithm ed25519`.
+func loadPrivateKey(path string) (ed25519.PrivateKey, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    block, _ := pem.Decode(data)
+    if block == nil {
+        return nil, fmt.Errorf("%s: no PEM data found", path)
+    }
+    key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    ed, ok := key.(ed25519.PrivateKey)
+    if !ok {
+        return nil, fmt.Errorf("%s: not an Ed25519 private key", path)
+    }
+    return ed, nil
+}
+
+// Reads a PEM encoded PKIX Ed25519 public key, as produced by
+// `openssl pkey -pubout`.
+func loadPublicKey(path string) (ed25519.PublicKey, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    block, _ := pem.Decode(data)
+    if block == nil {
+        return nil, fmt.Errorf("%s: no PEM data found", path)
+    }
+    key, err := x509.ParsePKIXPublicKey(block.Bytes)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    ed, ok := key.(ed25519.PublicKey)
+    if !ok {
+        return nil, fmt.Errorf("%s: not an Ed25519 public key", path)
+    }
+    return ed, nil
+}
@@ -0,0 +1,14 @@
+//go:build darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd
+
+package main
+
+import (
+    "os"
+    "syscall"
+)
+
+// Takes an exclusive lock on f, waiting for any other holder to let go. It is
+// released when f is closed.
+func lockFile(f *os.File) error {
+    return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
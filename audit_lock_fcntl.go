@@ -0,0 +1,16 @@
+//go:build aix || (solaris && !illumos)
+
+package main
+
+import (
+    "os"
+    "syscall"
+)
+
+// Takes an exclusive lock on f, waiting for any other holder to let go. These
+// systems have no flock, so this uses a POSIX record lock over the whole file
+// instead. It is released when f is closed.
+func lockFile(f *os.File) error {
+    lock := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: 0, Start: 0, Len: 0}
+    return syscall.FcntlFlock(f.Fd(), syscall.F_SETLKW, &lock)
+}
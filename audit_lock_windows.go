@@ -0,0 +1,23 @@
+package main
+
+import (
+    "os"
+    "syscall"
+    "unsafe"
+)
+
+var procLockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+
+const LOCKFILE_EXCLUSIVE_LOCK = 2
+
+// Takes an exclusive lock on f, waiting for any other holder to let go. It is
+// released when f is closed.
+func lockFile(f *os.File) error {
+    var overlapped syscall.Overlapped
+    // Lock the whole file: as many bytes as there can be, from the start.
+    r, _, err := procLockFileEx.Call(f.Fd(), LOCKFILE_EXCLUSIVE_LOCK, 0, 0xffffffff, 0xffffffff, uintptr(unsafe.Pointer(&overlapped)))
+    if r == 0 {
+        return err
+    }
+    return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+    "crypto/ed25519"
+    "math/big"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+)
+
+func TestAuditLogChain(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "audit.log")
+    audit, err := newAuditLog(path, "", "alice")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := audit.record(auditRecord{Operation: "split", SplitID: "0123", N: 5, T: 3, Success: true}); err != nil {
+        t.Fatal(err)
+    }
+    if err := audit.record(auditRecord{Operation: "combine", SplitID: "0123", Indices: []*big.Int{big.NewInt(1), big.NewInt(4), big.NewInt(5)}, Success: true}); err != nil {
+        t.Fatal(err)
+    }
+
+    count, err := verifyAuditLog(path, nil)
+    if err != nil {
+        t.Fatalf("Expected valid log, got: %s", err)
+    }
+    if count != 2 {
+        t.Errorf("Expected 2 entries, got %d", count)
+    }
+
+    entries, _ := readAuditLog(path)
+    if entries[1].PrevHash != entries[0].Hash {
+        t.Errorf("Expected entry 2 to chain to entry 1")
+    }
+    if entries[0].Operator != "alice" {
+        t.Errorf("Expected operator alice, got %s", entries[0].Operator)
+    }
+
+    // Changing any recorded field must break the chain.
+    data, _ := os.ReadFile(path)
+    tampered := strings.Replace(string(data), `"t":3`, `"t":2`, 1)
+    os.WriteFile(path, []byte(tampered), 0600)
+    if _, err := verifyAuditLog(path, nil); err == nil {
+        t.Errorf("Expected tampered log to fail verification")
+    }
+}
+
+func TestAuditLogDroppedEntry(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "audit.log")
+    audit, _ := newAuditLog(path, "", "bob")
+    for i := 0; i < 3; i++ {
+        audit.record(auditRecord{Operation: "split", N: 3, T: 2, Success: true})
+    }
+    data, _ := os.ReadFile(path)
+    lines := strings.SplitAfter(string(data), "\n")
+    os.WriteFile(path, []byte(lines[0]+lines[2]), 0600)
+    if _, err := verifyAuditLog(path, nil); err == nil {
+        t.Errorf("Expected log with a removed entry to fail verification")
+    }
+}
+
+// Entries cut off the end leave a valid chain, so only the head catches them.
+func TestAuditLogTruncated(t *testing.T) {
+    pub, priv, _ := ed25519.GenerateKey(nil)
+    path := filepath.Join(t.TempDir(), "audit.log")
+    audit := &auditLog{path: path, key: priv, operator: "erin"}
+    for i := 0; i < 3; i++ {
+        audit.record(auditRecord{Operation: "split", N: 3, T: 2, Success: true})
+    }
+    entries, _ := readAuditLog(path)
+    data, _ := os.ReadFile(path)
+    lines := strings.SplitAfter(string(data), "\n")
+    os.WriteFile(path, []byte(lines[0]+lines[1]), 0600)
+    if _, err := verifyAuditLog(path, pub); err == nil {
+        t.Errorf("Expected log with its last entry removed to fail verification")
+    }
+
+    // Rewriting the head to match needs the key.
+    (&auditLog{path: path, operator: "erin"}).writeHead(entries[1])
+    if _, err := verifyAuditLog(path, nil); err != nil {
+        t.Errorf("Expected the unsigned head to match the truncated log, got: %s", err)
+    }
+    if _, err := verifyAuditLog(path, pub); err == nil {
+        t.Errorf("Expected an unsigned head to fail verification against a key")
+    }
+
+    os.Remove(auditHeadPath(path))
+    if _, err := verifyAuditLog(path, nil); err == nil {
+        t.Errorf("Expected a log without a head to fail verification")
+    }
+}
+
+func TestAuditLogSignatures(t *testing.T) {
+    pub, priv, err := ed25519.GenerateKey(nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    path := filepath.Join(t.TempDir(), "audit.log")
+    audit := &auditLog{path: path, key: priv, operator: "carol"}
+    audit.record(auditRecord{Operation: "split", N: 3, T: 2, Success: true})
+
+    if _, err := verifyAuditLog(path, pub); err != nil {
+        t.Errorf("Expected signed log to verify, got: %s", err)
+    }
+
+    otherPub, _, _ := ed25519.GenerateKey(nil)
+    if _, err := verifyAuditLog(path, otherPub); err == nil {
+        t.Errorf("Expected verification with the wrong key to fail")
+    }
+
+    // An unsigned log must not pass when a key is required.
+    unsigned := filepath.Join(t.TempDir(), "unsigned.log")
+    (&auditLog{path: unsigned, operator: "carol"}).record(auditRecord{Operation: "split", Success: true})
+    if _, err := verifyAuditLog(unsigned, pub); err == nil {
+        t.Errorf("Expected unsigned log to fail verification against a key")
+    }
+}
+
+func TestNilAuditLog(t *testing.T) {
+    audit, err := newAuditLog("", "", "")
+    if err != nil || audit != nil {
+        t.Fatalf("Expected no audit log, got %v, %v", audit, err)
+    }
+    if err := audit.record(auditRecord{Operation: "split"}); err != nil {
+        t.Errorf("Expected nil audit log to record nothing, got: %s", err)
+    }
+}
+
+// A deleted or emptied log must not pass as a valid one.
+func TestAuditLogMissing(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "audit.log")
+    if _, err := verifyAuditLog(path, nil); err == nil {
+        t.Errorf("Expected a missing log to fail verification")
+    }
+    os.WriteFile(path, nil, 0600)
+    if _, err := verifyAuditLog(path, nil); err == nil {
+        t.Errorf("Expected an empty log to fail verification")
+    }
+}
+
+// Operations recording at the same time must still leave a single chain.
+func TestAuditLogConcurrent(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "audit.log")
+    audit, _ := newAuditLog(path, "", "dave")
+    var wg sync.WaitGroup
+    for i := 0; i < 8; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for j := 0; j < 25; j++ {
+                if err := audit.record(auditRecord{Operation: "split", N: 3, T: 2, Success: true}); err != nil {
+                    t.Error(err)
+                }
+            }
+        }()
+    }
+    wg.Wait()
+    count, err := verifyAuditLog(path, nil)
+    if err != nil || count != 200 {
+        t.Errorf("Expected 200 chained entries, got %d: %v", count, err)
+    }
+}
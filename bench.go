@@ -0,0 +1,222 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Throughput of native split and combine on the machine at hand, for the
+// bench command and the benchmarks in bench_test.go. Both go through
+// splitNative and combineNative, the same code as the split and combine
+// commands, so they measure whatever those do.
+
+const (
+    BENCH_DEFAULT_SIZES = "32B,1KiB,64KiB"
+    BENCH_DEFAULT_SHARES = "3/2,20/10,255/128"
+)
+
+// Share counts and thresholds to measure at.
+type benchShares struct {
+    n int
+    t int
+}
+
+// The units byte sizes are given in, largest first.
+var byteUnits = []struct {
+    suffix string
+    size   int
+}{{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}, {"B", 1}}
+
+// Parses a positive size in bytes with an optional unit, e.g. "32", "32B" or
+// "64KiB".
+func parseByteSize(s string) (int, error) {
+    number, unit := strings.TrimSpace(s), 1
+    for _, u := range(byteUnits) {
+        if strings.HasSuffix(number, u.suffix) {
+            number, unit = strings.TrimSuffix(number, u.suffix), u.size
+            break
+        }
+    }
+    size, err := strconv.Atoi(number)
+    if err != nil || size < 1 || size > int(^uint(0) >> 1) / unit {
+        return 0, newError(ErrInvalidParameters, fmt.Sprintf("Bad size %q, expecting e.g. 32B, 64KiB or 1GiB.", s))
+    }
+    return size * unit, nil
+}
+
+// Writes a size in the largest unit it is a whole number of.
+func formatByteSize(size int) string {
+    for _, u := range(byteUnits) {
+        if size % u.size == 0 {
+            return fmt.Sprintf("%d%s", size / u.size, u.suffix)
+        }
+    }
+    return fmt.Sprintf("%dB", size)
+}
+
+// Parses share counts and thresholds given as e.g. "3/2,20/10".
+func parseBenchShares(s string) ([]benchShares, error) {
+    res := []benchShares{}
+    for _, pair := range(strings.Split(s, ",")) {
+        var shares benchShares
+        if _, err := fmt.Sscanf(strings.TrimSpace(pair), "%d/%d", &shares.n, &shares.t); err != nil {
+            return nil, newError(ErrInvalidParameters, fmt.Sprintf("Bad shares %q, expecting n/t, e.g. '20/10'.", pair))
+        }
+        res = append(res, shares)
+    }
+    return res, nil
+}
+
+// A secret of size printable bytes.
+func benchSecret(size int) string {
+    pattern := "0123456789abcdef"
+    return strings.Repeat(pattern, size / len(pattern) + 1)[:size]
+}
+
+type benchResult struct {
+    Field          string  `json:"field"`
+    Size           int     `json:"size"`
+    N              int     `json:"n"`
+    T              int     `json:"t"`
+    SplitSeconds   float64 `json:"split_seconds"`
+    CombineSeconds float64 `json:"combine_seconds"`
+}
+
+// Throughput in MB/s of an operation on size bytes taking seconds.
+func throughput(size int, seconds float64) float64 {
+    return float64(size) / 1e6 / seconds
+}
+
+// Runs f until it has run for at least duration, and at least once, and
+// returns the mean time it took in seconds.
+func timeOperation(duration time.Duration, f func() error) (float64, error) {
+    count := 0
+    start := time.Now()
+    for count == 0 || time.Since(start) < duration {
+        if err := f(); err != nil {
+            return 0, err
+        }
+        count++
+    }
+    return time.Since(start).Seconds() / float64(count), nil
+}
+
+// The first t shares of a split, as combine takes them.
+func benchCombineInput(records []shareRecord, t int) []string {
+    input := []string{}
+    for _, r := range(records[:t]) {
+        input = append(input, r.index.String(), r.payload)
+    }
+    return input
+}
+
+// Measures splitting a secret of size bytes over field into shares.n shares
+// with threshold shares.t, and combining shares.t of them, each for at least
+// duration.
+func runBench(ctx context.Context, field string, size int, shares benchShares, workers int, duration time.Duration) (benchResult, error) {
+    res := benchResult{Field: field, Size: size, N: shares.n, T: shares.t}
+    secret := benchSecret(size)
+    var records []shareRecord
+    var err error
+    res.SplitSeconds, err = timeOperation(duration, func() error {
+        _, records, err = splitNative(ctx, "bench", secret, shares.n, shares.t, field, workers, "", "", rand.Reader)
+        return err
+    })
+    if err != nil {
+        return res, err
+    }
+    input := benchCombineInput(records, shares.t)
+    res.CombineSeconds, err = timeOperation(duration, func() error {
+        recovered, err := combineNative(ctx, input, nil, field, workers, nil)
+        if err == nil && recovered != secret {
+            err = fmt.Errorf("combine gave back the wrong secret")
+        }
+        return err
+    })
+    return res, err
+}
+
+type jsonBench struct {
+    Version int           `json:"version"`
+    Workers int           `json:"workers"`
+    Results []benchResult `json:"results"`
+}
+
+// Prints a table of split and combine throughput for each field, secret size
+// and share count given.
+func benchCommand(ctx context.Context, args []string) {
+    cmd := newFlagSet("bench")
+    fieldNames := []string{}
+    for _, field := range(fields) {
+        fieldNames = append(fieldNames, field.Name())
+    }
+    benchFields := cmd.String("fields", strings.Join(fieldNames, ","), "Comma separated fields to measure.")
+    sizes := cmd.String("sizes", BENCH_DEFAULT_SIZES, "Comma separated secret sizes, e.g. '32B,64KiB,1GiB'.")
+    benchShareCounts := cmd.String("shares", BENCH_DEFAULT_SHARES, "Comma separated share counts and thresholds, as n/t.")
+    workers := cmd.Int("workers", 0, "Number of goroutines splitting and combining (default: GOMAXPROCS).")
+    duration := cmd.Duration("duration", 200 * time.Millisecond, "How long to repeat each measurement for; each runs at least once.")
+    format := addFormatFlag(cmd)
+    out := parseFlags(cmd, args, format)
+
+    byte_sizes := []int{}
+    for _, s := range(strings.Split(*sizes, ",")) {
+        size, err := parseByteSize(s)
+        if err != nil {
+            out.failWith(err)
+        }
+        byte_sizes = append(byte_sizes, size)
+    }
+    share_counts, err := parseBenchShares(*benchShareCounts)
+    if err != nil {
+        out.failWith(err)
+    }
+    names := strings.Split(*benchFields, ",")
+    for _, name := range(names) {
+        if _, err := lookupField(strings.TrimSpace(name)); err != nil {
+            out.failWith(err)
+        }
+    }
+
+    // Rows are printed as they are measured, so the columns have fixed
+    // widths.
+    row := "%-15s %8s %8s %12s %12s %12s %12s\n"
+    res := jsonBench{JSON_SCHEMA_VERSION, *workers, []benchResult{}}
+    if !out.json {
+        fmt.Printf(row, "Field", "Secret", "n/t", "Split MB/s", "Split time", "Combine MB/s", "Combine time")
+    }
+    for _, name := range(names) {
+        for _, size := range(byte_sizes) {
+            for _, shares := range(share_counts) {
+                result, err := runBench(ctx, strings.TrimSpace(name), size, shares, *workers, *duration)
+                if err != nil {
+                    out.failWith(err)
+                }
+                res.Results = append(res.Results, result)
+                if !out.json {
+                    fmt.Printf(row, result.Field, formatByteSize(size), fmt.Sprintf("%d/%d", shares.n, shares.t),
+                        fmt.Sprintf("%.2f", throughput(size, result.SplitSeconds)), secondsDuration(result.SplitSeconds),
+                        fmt.Sprintf("%.2f", throughput(size, result.CombineSeconds)), secondsDuration(result.CombineSeconds))
+                }
+            }
+        }
+    }
+    if out.json {
+        out.emit(res)
+    }
+}
+
+// A time in seconds as a Duration, rounded for printing.
+func secondsDuration(seconds float64) time.Duration {
+    d := time.Duration(seconds * float64(time.Second))
+    switch {
+        case d > time.Second:
+            return d.Round(time.Millisecond)
+        case d > time.Millisecond:
+            return d.Round(time.Microsecond)
+    }
+    return d
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "flag"
+    "fmt"
+    "testing"
+    "time"
+)
+
+// Benchmarks of native split and combine for every field, over secrets from
+// 32 bytes up to -bench-max-size and the share counts of the bench command,
+// e.g.
+//
+//     go test -run XXX -bench Split -args -bench-max-size 1GiB
+//
+// Secrets of a gigabyte take many gigabytes of memory and minutes an
+// operation, so by default sizes stop at 64KiB.
+var benchMaxSize = flag.String("bench-max-size", "64KiB", "Largest secret size to benchmark split and combine with.")
+
+var benchSizes = []int{32, 1 << 10, 64 << 10, 1 << 20, 16 << 20, 1 << 30}
+
+// The sizes in benchSizes up to -bench-max-size.
+func benchmarkSizes(b *testing.B) []int {
+    max_size, err := parseByteSize(*benchMaxSize)
+    if err != nil {
+        b.Fatal(err)
+    }
+    sizes := []int{}
+    for _, size := range(benchSizes) {
+        if size <= max_size {
+            sizes = append(sizes, size)
+        }
+    }
+    return sizes
+}
+
+// Runs f as a sub-benchmark for each field, size and share count.
+func benchmarkEach(b *testing.B, f func(b *testing.B, field Field, secret string, shares benchShares)) {
+    share_counts, _ := parseBenchShares(BENCH_DEFAULT_SHARES)
+    for _, field := range(fields) {
+        for _, size := range(benchmarkSizes(b)) {
+            secret := benchSecret(size)
+            for _, shares := range(share_counts) {
+                b.Run(fmt.Sprintf("%s/%s/%d-%d", field.Name(), formatByteSize(size), shares.n, shares.t), func(b *testing.B) {
+                    b.SetBytes(int64(size))
+                    b.ReportAllocs()
+                    f(b, field, secret, shares)
+                })
+            }
+        }
+    }
+}
+
+func BenchmarkSplit(b *testing.B) {
+    benchmarkEach(b, func(b *testing.B, field Field, secret string, shares benchShares) {
+        for i := 0; i < b.N; i++ {
+            if _, _, err := splitNative(context.Background(), "bench", secret, shares.n, shares.t, field.Name(), 0, "", "", rand.Reader); err != nil {
+                b.Fatal(err)
+            }
+        }
+    })
+}
+
+func BenchmarkCombine(b *testing.B) {
+    benchmarkEach(b, func(b *testing.B, field Field, secret string, shares benchShares) {
+        _, records, err := splitNative(context.Background(), "bench", secret, shares.n, shares.t, field.Name(), 0, "", "", rand.Reader)
+        if err != nil {
+            b.Fatal(err)
+        }
+        input := benchCombineInput(records, shares.t)
+        b.ResetTimer()
+        for i := 0; i < b.N; i++ {
+            if recovered, err := combineNative(context.Background(), input, nil, field.Name(), 0, nil); err != nil || recovered != secret {
+                b.Fatalf("Expecting the secret back, got: %v", err)
+            }
+        }
+    })
+}
+
+func TestParseByteSize(t *testing.T) {
+    for s, expected := range(map[string]int{"32": 32, "32B": 32, "1KiB": 1024, " 64KiB": 64 << 10, "3MiB": 3 << 20, "1GiB": 1 << 30}) {
+        if size, err := parseByteSize(s); err != nil || size != expected {
+            t.Errorf("Expecting %q to be %d bytes, got: %d, %v", s, expected, size, err)
+        }
+    }
+    for _, s := range([]string{"", "0", "-1KiB", "KiB", "1KB", "1.5MiB", "99999999999GiB"}) {
+        if _, err := parseByteSize(s); err == nil {
+            t.Errorf("Expecting %q to be rejected", s)
+        }
+    }
+    for size, expected := range(map[int]string{32: "32B", 1024: "1KiB", 1536: "1536B", 64 << 20: "64MiB", 1 << 30: "1GiB"}) {
+        if s := formatByteSize(size); s != expected {
+            t.Errorf("Expecting %d bytes to be written %s, got: %s", size, expected, s)
+        }
+    }
+}
+
+func TestParseBenchShares(t *testing.T) {
+    shares, err := parseBenchShares(BENCH_DEFAULT_SHARES)
+    if err != nil || fmt.Sprint(shares) != "[{3 2} {20 10} {255 128}]" {
+        t.Errorf("Expecting the default share counts, got: %v, %v", shares, err)
+    }
+    for _, s := range([]string{"", "3", "3/", "a/b", "3/2,"}) {
+        if _, err := parseBenchShares(s); err == nil {
+            t.Errorf("Expecting %q to be rejected", s)
+        }
+    }
+}
+
+// A measurement of each field splits and combines correctly.
+func TestRunBench(t *testing.T) {
+    for _, field := range(fields) {
+        res, err := runBench(context.Background(), field.Name(), 100, benchShares{5, 3}, 2, time.Millisecond)
+        if err != nil {
+            t.Fatalf("%s: %v", field.Name(), err)
+        }
+        if res.SplitSeconds <= 0 || res.CombineSeconds <= 0 {
+            t.Errorf("%s: expecting positive times, got: %+v", field.Name(), res)
+        }
+    }
+    if _, err := runBench(context.Background(), "p127", 32, benchShares{3, 4}, 1, time.Millisecond); err == nil {
+        t.Error("Expecting a threshold above n to fail")
+    }
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+    "encoding/binary"
+    _ "embed"
+    "fmt"
+    "hash/crc32"
+    "strings"
+)
+
+// Bytewords (https://github.com/BlockchainCommons/Research/blob/master/papers/bcr-2020-012-bytewords.md)
+// writes each byte as one of 256 four letter words, followed by four words
+// of CRC-32. The first and last letters of every word are unique, so the
+// minimal style, used in URs, keeps only those two letters.
+
+//go:embed wordlists/bytewords.txt
+var bytewordsText string
+
+var bytewords = strings.Fields(bytewordsText)
+
+// Maps every word, and its first and last letters, to its byte.
+var bytewordsIndex = func() map[string]byte {
+    index := make(map[string]byte)
+    for i, word := range(bytewords) {
+        index[word] = byte(i)
+        index[word[:1] + word[3:]] = byte(i)
+    }
+    return index
+}()
+
+const (
+    BYTEWORDS_STANDARD = "standard"
+    BYTEWORDS_URI = "uri"
+    BYTEWORDS_MINIMAL = "minimal"
+)
+
+// Encodes data with its checksum in the given style: words separated by
+// spaces (standard) or dashes (uri), or two letter words run together
+// (minimal).
+func encodeBytewords(data []byte, style string) string {
+    sum := make([]byte, 4)
+    binary.BigEndian.PutUint32(sum, crc32.ChecksumIEEE(data))
+    words := []string{}
+    for _, b := range(append(append([]byte{}, data...), sum...)) {
+        word := bytewords[b]
+        if style == BYTEWORDS_MINIMAL {
+            word = word[:1] + word[3:]
+        }
+        words = append(words, word)
+    }
+    switch style {
+        case BYTEWORDS_URI:
+            return strings.Join(words, "-")
+        case BYTEWORDS_MINIMAL:
+            return strings.Join(words, "")
+    }
+    return strings.Join(words, " ")
+}
+
+// Decodes Bytewords in any of the styles and checks the checksum. Words are
+// case-insensitive and may be separated by spaces or dashes, or run together.
+func decodeBytewords(s string) ([]byte, error) {
+    words := splitWords(s)
+    if len(words) == 1 {
+        // Minimal style: two letters to a byte.
+        if len(words[0]) % 2 != 0 {
+            return nil, newError(ErrMalformedShare, "Bytewords must be two letters to a byte in the minimal style.")
+        }
+        minimal := []string{}
+        for i := 0; i < len(words[0]); i += 2 {
+            minimal = append(minimal, words[0][i:i+2])
+        }
+        words = minimal
+    }
+    data := []byte{}
+    for i, word := range(words) {
+        b, ok := bytewordsIndex[word]
+        if !ok {
+            return nil, newError(ErrMalformedShare, fmt.Sprintf("Word %d (%q) is not a byteword.", i+1, word))
+        }
+        data = append(data, b)
+    }
+    if len(data) < 5 {
+        return nil, newError(ErrMalformedShare, "Bytewords are too short.")
+    }
+    body, sum := data[:len(data)-4], data[len(data)-4:]
+    if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(sum) {
+        return nil, newError(ErrMalformedShare, "Bytewords checksum does not match, a word is wrong or missing.")
+    }
+    return body, nil
+}
+
+// Formats a single part Uniform Resource (BCR-2020-005): "ur:<type>/" and the
+// CBOR body as minimal Bytewords.
+func encodeUR(urType string, cbor []byte) string {
+    return "ur:" + urType + "/" + encodeBytewords(cbor, BYTEWORDS_MINIMAL)
+}
+
+// Parses a single part UR, returning its type and CBOR body.
+func decodeUR(s string) (string, []byte, error) {
+    lower := strings.ToLower(strings.TrimSpace(s))
+    if !strings.HasPrefix(lower, "ur:") {
+        return "", nil, newError(ErrMalformedShare, "URs must start with 'ur:'.")
+    }
+    fields := strings.Split(lower[3:], "/")
+    if len(fields) != 2 {
+        return "", nil, newError(ErrMalformedShare, "Only single part URs of the form 'ur:type/bytewords' are supported.")
+    }
+    body, err := decodeBytewords(fields[1])
+    return fields[0], body, err
+}
+
+// Writes data as a CBOR byte string, optionally with a tag of 256..65535
+// in front.
+func cborBytes(data []byte, tag int) []byte {
+    res := []byte{}
+    if tag > 0 {
+        res = append(res, 0xd9, byte(tag >> 8), byte(tag))
+    }
+    switch {
+        case len(data) < 24:
+            res = append(res, 0x40 | byte(len(data)))
+        case len(data) < 256:
+            res = append(res, 0x58, byte(len(data)))
+        default:
+            res = append(res, 0x59, byte(len(data) >> 8), byte(len(data)))
+    }
+    return append(res, data...)
+}
+
+// Reads a CBOR byte string written by cborBytes, returning the tag, or 0 if
+// there is none.
+func parseCborBytes(cbor []byte) (int, []byte, error) {
+    malformed := newError(ErrMalformedShare, "Expected a CBOR byte string.")
+    tag := 0
+    if len(cbor) >= 3 && cbor[0] == 0xd9 {
+        tag = int(cbor[1]) << 8 | int(cbor[2])
+        cbor = cbor[3:]
+    }
+    if len(cbor) == 0 {
+        return 0, nil, malformed
+    }
+    length, header := 0, 1
+    switch {
+        case cbor[0] >= 0x40 && cbor[0] < 0x58:
+            length = int(cbor[0] & 0x1f)
+        case cbor[0] == 0x58 && len(cbor) >= 2:
+            length, header = int(cbor[1]), 2
+        case cbor[0] == 0x59 && len(cbor) >= 3:
+            length, header = int(cbor[1]) << 8 | int(cbor[2]), 3
+        default:
+            return 0, nil, malformed
+    }
+    if len(cbor) != header + length {
+        return 0, nil, malformed
+    }
+    return tag, cbor[header:], nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+    "bytes"
+    "encoding/hex"
+    "errors"
+    "sort"
+    "testing"
+)
+
+func TestBytewordsWordList(t *testing.T) {
+    if len(bytewords) != 256 || len(bytewordsIndex) != 2 * 256 {
+        t.Errorf("Expected 256 words with unique first and last letters, got %d words and %d entries", len(bytewords), len(bytewordsIndex))
+    }
+    if !sort.StringsAreSorted(bytewords) {
+        t.Errorf("Word list is not sorted")
+    }
+}
+
+// The example from the Bytewords specification.
+func TestBytewordsVector(t *testing.T) {
+    data := []byte{0, 1, 2, 128, 255}
+    encodings := map[string]string{
+        BYTEWORDS_STANDARD: "able acid also lava zoom jade need echo taxi",
+        BYTEWORDS_URI: "able-acid-also-lava-zoom-jade-need-echo-taxi",
+        BYTEWORDS_MINIMAL: "aeadaolazmjendeoti",
+    }
+    for style, expected := range(encodings) {
+        if encoded := encodeBytewords(data, style); encoded != expected {
+            t.Errorf("Expecting %s, got: %s", expected, encoded)
+        }
+        decoded, err := decodeBytewords(expected)
+        if err != nil || !bytes.Equal(decoded, data) {
+            t.Errorf("Expecting %x, got: %x (%v)", data, decoded, err)
+        }
+    }
+}
+
+func TestBytewordsErrors(t *testing.T) {
+    for _, s := range([]string{"able acid also lava zoom jade need echo tuna", "able acid also lava zoom jade need echo", "able acid also lava zoom jade need echo tacos", "aeadaolazmjendeot", "able"} ) {
+        if _, err := decodeBytewords(s); !errors.Is(err, ErrMalformedShare) {
+            t.Errorf("Expecting malformed share for %q, got: %v", s, err)
+        }
+    }
+}
+
+func TestUR(t *testing.T) {
+    data := []byte("0123456789abcdef0123456789")
+    urType, cbor, err := decodeUR(encodeUR("bytes", cborBytes(data, 0)))
+    if err != nil || urType != "bytes" {
+        t.Fatalf("Expecting a ur:bytes, got: %q (%v)", urType, err)
+    }
+    tag, decoded, err := parseCborBytes(cbor)
+    if err != nil || tag != 0 || !bytes.Equal(decoded, data) {
+        t.Errorf("Expecting %x, got: %d %x (%v)", data, tag, decoded, err)
+    }
+    // A 24 byte string takes a one byte length, and the tag comes first.
+    if cbor := cborBytes(data[:24], SSKR_TAG); !bytes.Equal(cbor[:5], []byte{0xd9, 0x9d, 0x75, 0x58, 24}) {
+        t.Errorf("Bad CBOR header %x", cbor[:5])
+    }
+    // The crypto-seed example of BCR-2020-006, written by the reference
+    // implementation: a map of the seed under key 1.
+    urType, cbor, err = decodeUR("ur:crypto-seed/oyadgdhkwzdtfthptokigtvwnnjsqzcxknsktdhpyljeda")
+    if err != nil || urType != "crypto-seed" || hex.EncodeToString(cbor) != "a1015059f2293a5bce7d4de59e71b4207ac5d2" {
+        t.Errorf("Expecting ur:crypto-seed a1015059f2293a5bce7d4de59e71b4207ac5d2, got: %q %x (%v)", urType, cbor, err)
+    }
+    if s := encodeUR("crypto-seed", cbor); s != "ur:crypto-seed/oyadgdhkwzdtfthptokigtvwnnjsqzcxknsktdhpyljeda" {
+        t.Errorf("Bad UR %s", s)
+    }
+    for _, s := range([]string{"sskr/aeadaolazmjendeoti", "ur:a/b/c", "ur:bytes/zzzz"}) {
+        if _, _, err := decodeUR(s); !errors.Is(err, ErrMalformedShare) {
+            t.Errorf("Expecting malformed share for %q, got: %v", s, err)
+        }
+    }
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+    "encoding/binary"
+    "math/big"
+    "math/bits"
+)
+
+// Constant-time arithmetic for the fields of field.go. Elements are held as
+// fixed numbers of 64-bit limbs, least significant first, and every operation
+// does the same work on them whatever their values: there are no branches on
+// or table lookups by element data, and carries and borrows are turned into
+// masks. Values get in and out as limbs too, see Parse, Format, Encode and
+// Decode in field.go; math/big is only used for public values such as
+// exponents and x-coordinates.
+
+// The most limbs of any field: 2^521 - 1 takes 9.
+const MAX_LIMBS = 9
+
+// Arithmetic on elements held as limbs, in whatever form suits the field.
+// The result z may be the same slice as a or b.
+type limbArithmetic interface {
+    // The number of limbs of an element.
+    limbs() int
+    // Converts a value given as plain limbs, which must be less than the size
+    // of the field, to the field's form, and back.
+    fromLimbs(z, a []uint64)
+    toLimbs(z, a []uint64)
+    setOne(z []uint64)
+    add(z, a, b []uint64)
+    sub(z, a, b []uint64)
+    mul(z, a, b []uint64)
+}
+
+// Writes x, which must fit, as len(z) limbs.
+func bigToLimbs(z []uint64, x *big.Int) {
+    b := x.FillBytes(make([]byte, 8 * len(z)))
+    for i := range(z) {
+        z[i] = binary.BigEndian.Uint64(b[8 * (len(z) - 1 - i):])
+    }
+}
+
+func limbsToBig(a []uint64) *big.Int {
+    b := make([]byte, 8 * len(a))
+    for i, limb := range(a) {
+        binary.BigEndian.PutUint64(b[8 * (len(a) - 1 - i):], limb)
+    }
+    return new(big.Int).SetBytes(b)
+}
+
+// 1 if a is less than b and 0 otherwise: the borrow out of a - b. Both have
+// the same number of limbs.
+func lessLimbs(a, b []uint64) uint64 {
+    var borrow uint64
+    for i := range(a) {
+        _, borrow = bits.Sub64(a[i], b[i], borrow)
+    }
+    return borrow
+}
+
+// Sets z to z * m + d, dropping any carry out of the top limb.
+func mulAddLimbs(z []uint64, m, d uint64) {
+    carry := d
+    for i := range(z) {
+        hi, lo := bits.Mul64(z[i], m)
+        var c uint64
+        z[i], c = bits.Add64(lo, carry, 0)
+        carry = hi + c
+    }
+}
+
+// Sets z to z / 10 and returns the remainder. The division goes down 32 bits
+// at a time, so that the remainder so far and the next 32 bits fit in 64,
+// and each step divides by multiplying: floor(v / 10) is the top bits of
+// v * ceil(2^67 / 10) for any 64-bit v. The hardware divide takes different
+// times for different values on some processors.
+func divLimbs10(z []uint64) uint64 {
+    var r uint64
+    for i := len(z) - 1; i >= 0; i-- {
+        var q uint64
+        for _, shift := range([2]uint{32, 0}) {
+            v := r << 32 | (z[i] >> shift) & 0xffffffff
+            hi, _ := bits.Mul64(v, 0xcccccccccccccccd)
+            d := hi >> 3
+            r = v - 10 * d
+            q = q << 32 | d
+        }
+        z[i] = q
+    }
+    return r
+}
+
+// Sets z to b if choice is 1 and to a if it is 0.
+func selectLimbs(z, a, b []uint64, choice uint64) {
+    mask := -choice
+    for i := range(z) {
+        z[i] = (b[i] & mask) | (a[i] &^ mask)
+    }
+}
+
+// Evaluates the polynomial with the given coefficients (constant term first)
+// at x using Horner's method, into z.
+func hornerLimbs(arith limbArithmetic, z []uint64, coefficients []Element, x []uint64) {
+    copy(z, coefficients[len(coefficients)-1])
+    for i := len(coefficients) - 2; i >= 0; i-- {
+        arith.mul(z, z, x)
+        arith.add(z, z, coefficients[i])
+    }
+}
+
+// Sets z to the sum of ys[i] * weights[i]. term is scratch space of the same
+// length as z.
+func dotLimbs(arith limbArithmetic, z, term []uint64, ys, weights []Element) {
+    for i := range(z) {
+        z[i] = 0
+    }
+    for i, y := range(ys) {
+        arith.mul(term, y, weights[i])
+        arith.add(z, z, term)
+    }
+}
+
+// Sets z to a^e. The exponent is public, so only its bits decide which
+// operations are done.
+func powLimbs(arith limbArithmetic, z, a []uint64, e *big.Int) {
+    var res [MAX_LIMBS]uint64
+    r := res[:len(z)]
+    arith.setOne(r)
+    for i := e.BitLen() - 1; i >= 0; i-- {
+        arith.mul(r, r, r)
+        if e.Bit(i) == 1 {
+            arith.mul(r, r, a)
+        }
+    }
+    copy(z, r)
+}
+
+// Arithmetic mod an odd prime p in Montgomery form: an element x is held as
+// xR mod p, with R = 2^(64 * limbs), so that multiplying needs no division.
+type montgomery struct {
+    p []uint64
+    // -1/p mod 2^64.
+    pinv uint64
+    // R^2 mod p, which takes an element into Montgomery form, and R mod p,
+    // which is 1 in it.
+    r2  []uint64
+    one []uint64
+}
+
+func newMontgomery(p *big.Int) *montgomery {
+    n := (p.BitLen() + 63) / 64
+    m := &montgomery{p: make([]uint64, n), r2: make([]uint64, n), one: make([]uint64, n)}
+    bigToLimbs(m.p, p)
+    // Newton's iteration doubles the number of correct low bits each time,
+    // and any odd number is its own inverse mod 8.
+    inv := m.p[0]
+    for i := 0; i < 5; i++ {
+        inv *= 2 - m.p[0] * inv
+    }
+    m.pinv = -inv
+    r := new(big.Int).Lsh(big.NewInt(1), uint(64 * n))
+    bigToLimbs(m.one, new(big.Int).Mod(r, p))
+    bigToLimbs(m.r2, new(big.Int).Mod(r.Mul(r, r), p))
+    return m
+}
+
+func (m *montgomery) limbs() int {
+    return len(m.p)
+}
+
+func (m *montgomery) fromLimbs(z, a []uint64) {
+    m.mul(z, a, m.r2)
+}
+
+func (m *montgomery) toLimbs(z, a []uint64) {
+    var one [MAX_LIMBS]uint64
+    one[0] = 1
+    m.mul(z, a, one[:len(m.p)])
+}
+
+func (m *montgomery) setOne(z []uint64) {
+    copy(z, m.one)
+}
+
+// Sets z to a - p, unless that goes below zero, where a is less than 2p and
+// carry is the bit of a above its limbs.
+func (m *montgomery) reduce(z, a []uint64, carry uint64) {
+    var d [MAX_LIMBS]uint64
+    var borrow uint64
+    for i := range(m.p) {
+        d[i], borrow = bits.Sub64(a[i], m.p[i], borrow)
+    }
+    // a - p is kept unless it borrowed past the carry bit.
+    _, borrow = bits.Sub64(carry, 0, borrow)
+    selectLimbs(z, d[:len(m.p)], a, borrow)
+}
+
+func (m *montgomery) add(z, a, b []uint64) {
+    var s [MAX_LIMBS]uint64
+    var carry uint64
+    for i := range(m.p) {
+        s[i], carry = bits.Add64(a[i], b[i], carry)
+    }
+    m.reduce(z, s[:len(m.p)], carry)
+}
+
+// a - b, adding p back if that borrowed.
+func (m *montgomery) sub(z, a, b []uint64) {
+    var d [MAX_LIMBS]uint64
+    var borrow, carry uint64
+    for i := range(m.p) {
+        d[i], borrow = bits.Sub64(a[i], b[i], borrow)
+    }
+    mask := -borrow
+    for i := range(m.p) {
+        z[i], carry = bits.Add64(d[i], m.p[i] & mask, carry)
+    }
+}
+
+// abR^-1 mod p, by the CIOS method: each limb of b is multiplied in and then
+// a multiple of p is added to make the lowest limb zero, which is shifted
+// out. The result is less than 2p, and one conditional subtraction reduces
+// it.
+func (m *montgomery) mul(z, a, b []uint64) {
+    n := len(m.p)
+    var t [MAX_LIMBS + 2]uint64
+    for i := 0; i < n; i++ {
+        var c, carry uint64
+        for j := 0; j < n; j++ {
+            hi, lo := bits.Mul64(a[j], b[i])
+            lo, carry = bits.Add64(lo, t[j], 0)
+            hi += carry
+            t[j], carry = bits.Add64(lo, c, 0)
+            c = hi + carry
+        }
+        t[n], carry = bits.Add64(t[n], c, 0)
+        t[n+1] = carry
+
+        q := t[0] * m.pinv
+        hi, lo := bits.Mul64(q, m.p[0])
+        _, carry = bits.Add64(lo, t[0], 0)
+        c = hi + carry
+        for j := 1; j < n; j++ {
+            hi, lo := bits.Mul64(q, m.p[j])
+            lo, carry = bits.Add64(lo, t[j], 0)
+            hi += carry
+            t[j-1], carry = bits.Add64(lo, c, 0)
+            c = hi + carry
+        }
+        t[n-1], carry = bits.Add64(t[n], c, 0)
+        t[n] = t[n+1] + carry
+    }
+    m.reduce(z, t[:n], t[n])
+}
+
+// Arithmetic in GF(2^degree) modulo the given polynomial, multiplying by
+// shifting and adding with masks rather than with ssss's loop over the set
+// bits or gf256's log tables.
+type gf2k struct {
+    degree int
+    // The polynomial without its x^degree term.
+    poly []uint64
+}
+
+func newGF2k(degree int) *gf2k {
+    f := &gf2k{degree, make([]uint64, (degree + 63) / 64)}
+    poly := newSsssField(degree).poly
+    bigToLimbs(f.poly, new(big.Int).SetBit(poly, degree, 0))
+    return f
+}
+
+func (f *gf2k) limbs() int {
+    return len(f.poly)
+}
+
+func (f *gf2k) fromLimbs(z, a []uint64) {
+    copy(z, a)
+}
+
+func (f *gf2k) toLimbs(z, a []uint64) {
+    copy(z, a)
+}
+
+func (f *gf2k) setOne(z []uint64) {
+    for i := range(z) {
+        z[i] = 0
+    }
+    z[0] = 1
+}
+
+func (f *gf2k) add(z, a, b []uint64) {
+    for i := range(z) {
+        z[i] = a[i] ^ b[i]
+    }
+}
+
+// Subtraction is the same as addition in characteristic 2.
+func (f *gf2k) sub(z, a, b []uint64) {
+    f.add(z, a, b)
+}
+
+// For each bit of b from the bottom, adds a if the bit is set, then
+// multiplies a by x, reducing by the polynomial when the top bit falls off.
+func (f *gf2k) mul(z, a, b []uint64) {
+    n := len(f.poly)
+    var res, s [MAX_LIMBS]uint64
+    copy(s[:n], a)
+    top_limb, top_bit := (f.degree - 1) / 64, uint((f.degree - 1) % 64)
+    for i := 0; i < f.degree; i++ {
+        mask := -((b[i/64] >> uint(i % 64)) & 1)
+        for j := 0; j < n; j++ {
+            res[j] ^= s[j] & mask
+        }
+        overflow := -((s[top_limb] >> top_bit) & 1)
+        for j := n - 1; j > 0; j-- {
+            s[j] = s[j] << 1 | s[j-1] >> 63
+        }
+        s[0] <<= 1
+        if top_bit != 63 {
+            s[top_limb] &= 1 << (top_bit + 1) - 1
+        }
+        for j := 0; j < n; j++ {
+            s[j] ^= f.poly[j] & overflow
+        }
+    }
+    copy(z, res[:n])
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+    "crypto/rand"
+    "math"
+    "math/big"
+    "os"
+    "sort"
+    "testing"
+    "time"
+)
+
+// Values next to the limb boundaries and the size of the field, and some
+// random ones.
+func limbTestValues(field Field) []*big.Int {
+    size := field.Size()
+    values := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2)}
+    for shift := uint(63); int(shift) < size.BitLen() - 1; shift += 64 {
+        power := new(big.Int).Lsh(big.NewInt(1), shift)
+        values = append(values, power, new(big.Int).Sub(power, big.NewInt(1)), new(big.Int).Add(power, big.NewInt(1)))
+    }
+    values = append(values, new(big.Int).Sub(size, big.NewInt(1)), new(big.Int).Sub(size, big.NewInt(2)))
+    for i := 0; i < 10; i++ {
+        x, _ := field.Random(rand.Reader)
+        values = append(values, field.ToBig(x))
+    }
+    return values
+}
+
+func elements(field Field, xs []*big.Int) []Element {
+    res := make([]Element, len(xs))
+    for i, x := range(xs) {
+        res[i] = field.FromBig(x)
+    }
+    return res
+}
+
+// Checks the limb arithmetic of every field against math/big: mod the prime
+// for prime fields, and ssss's multiplication for binary fields.
+func TestLimbArithmetic(t *testing.T) {
+    for _, field := range(fields) {
+        size := field.Size()
+        binary := size.Bit(0) == 0
+        ssss := newSsssField(size.BitLen() - 1)
+        values := limbTestValues(field)
+        for _, x := range(values) {
+            a := field.FromBig(x)
+            for _, y := range(values) {
+                b := field.FromBig(y)
+                var sum, difference, product *big.Int
+                if binary {
+                    sum = new(big.Int).Xor(x, y)
+                    difference = sum
+                    product = ssss.mul(x, y)
+                } else {
+                    sum = new(big.Int).Mod(new(big.Int).Add(x, y), size)
+                    difference = new(big.Int).Mod(new(big.Int).Sub(x, y), size)
+                    product = new(big.Int).Mod(new(big.Int).Mul(x, y), size)
+                }
+                if res := field.ToBig(field.Add(a, b)); res.Cmp(sum) != 0 {
+                    t.Errorf("%s: expecting %s as the sum of %s and %s, got: %s", field.Name(), sum, x, y, res)
+                }
+                if res := field.ToBig(field.Sub(a, b)); res.Cmp(difference) != 0 {
+                    t.Errorf("%s: expecting %s as the difference of %s and %s, got: %s", field.Name(), difference, x, y, res)
+                }
+                if res := field.ToBig(field.Mul(a, b)); res.Cmp(product) != 0 {
+                    t.Errorf("%s: expecting %s as the product of %s and %s, got: %s", field.Name(), product, x, y, res)
+                }
+            }
+        }
+    }
+}
+
+func TestEvaluateAndDot(t *testing.T) {
+    for _, field := range(fields) {
+        coefficients := elements(field, limbTestValues(field))
+        xs := elements(field, []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(255)})
+        ys := field.Evaluate(coefficients, xs)
+        for i, x := range(xs) {
+            expected := coefficients[len(coefficients)-1]
+            for j := len(coefficients) - 2; j >= 0; j-- {
+                expected = field.Add(field.Mul(expected, x), coefficients[j])
+            }
+            if field.ToBig(ys[i]).Cmp(field.ToBig(expected)) != 0 {
+                t.Errorf("%s: expecting %s at %s, got: %s", field.Name(), field.ToBig(expected), field.ToBig(x), field.ToBig(ys[i]))
+            }
+        }
+        expected := field.FromBig(big.NewInt(0))
+        for i := range(coefficients) {
+            expected = field.Add(expected, field.Mul(coefficients[i], coefficients[len(coefficients)-1-i]))
+        }
+        reversed := make([]Element, len(coefficients))
+        for i, c := range(coefficients) {
+            reversed[len(coefficients)-1-i] = c
+        }
+        if res := field.Dot(coefficients, reversed); field.ToBig(res).Cmp(field.ToBig(expected)) != 0 {
+            t.Errorf("%s: expecting %s as the dot product, got: %s", field.Name(), field.ToBig(expected), field.ToBig(res))
+        }
+    }
+}
+
+// A timing leak test in the style of dudect (Reparaz, Balasch and
+// Verbauwhede, "Dude, is my code constant time?"): an operation is timed on
+// inputs from two classes, one fixed and one random, interleaved in a random
+// order, and Welch's t-test tells whether the two timing distributions
+// differ. The slowest samples, which are mostly interrupts and the scheduler,
+// are cropped off first.
+//
+// Wall-clock timings are thrown off by anything else the machine is doing,
+// so the full tests only run when SHAMIR_TIMING_TESTS=1 is set. A plain go
+// test runs a quick version, with few samples and a loose threshold, that only
+// catches gross leaks such as arithmetic going back to math/big.
+
+const (
+    TIMING_SAMPLES = 20000
+    TIMING_QUICK_SAMPLES = 1000
+    // Inverting takes hundreds of multiplications, too slow for a plain go
+    // test at the full count.
+    TIMING_QUICK_INV_SAMPLES = 100
+    // Calls timed together, so that each sample is well above the resolution
+    // of the clock.
+    TIMING_BATCH = 16
+    TIMING_CROP_PERCENTILE = 90
+    // dudect's threshold for a definite leak.
+    TIMING_T_THRESHOLD = 10
+    TIMING_QUICK_T_THRESHOLD = 30
+)
+
+func skipUnlessTiming(t *testing.T) {
+    if testing.Short() || os.Getenv("SHAMIR_TIMING_TESTS") != "1" {
+        t.Skip("Timing measurements are slow and need a quiet machine, set SHAMIR_TIMING_TESTS=1 to run them.")
+    }
+}
+
+// Runs run(class, i) for the given number of samples with randomly chosen
+// classes 0 and 1, and returns the t statistic of the timings of the two classes.
+// run should only pick the inputs for sample i of its class, prepared in
+// advance, so that nothing but the operation itself is timed.
+func timingLeak(samples int, run func(class, i int)) float64 {
+    choices := make([]byte, samples)
+    rand.Read(choices)
+    times := make([]float64, samples)
+    // Warms up caches and the branch predictor.
+    for i := 0; i < samples / 10; i++ {
+        run(int(choices[i] & 1), i)
+    }
+    for i := range(times) {
+        class := int(choices[i] & 1)
+        start := time.Now()
+        for k := 0; k < TIMING_BATCH; k++ {
+            run(class, i)
+        }
+        times[i] = float64(time.Since(start))
+    }
+    sorted := append([]float64{}, times...)
+    sort.Float64s(sorted)
+    cutoff := sorted[len(sorted) * TIMING_CROP_PERCENTILE / 100]
+    classes := [2][]float64{}
+    for i, d := range(times) {
+        if d <= cutoff {
+            classes[choices[i] & 1] = append(classes[choices[i] & 1], d)
+        }
+    }
+    return welchT(classes[0], classes[1])
+}
+
+func welchT(a, b []float64) float64 {
+    mean_var := func(xs []float64) (float64, float64) {
+        var mean, m2 float64
+        for i, x := range(xs) {
+            delta := x - mean
+            mean += delta / float64(i + 1)
+            m2 += delta * (x - mean)
+        }
+        return mean, m2 / float64(len(xs) - 1)
+    }
+    mean_a, var_a := mean_var(a)
+    mean_b, var_b := mean_var(b)
+    return (mean_a - mean_b) / math.Sqrt(var_a / float64(len(a)) + var_b / float64(len(b)))
+}
+
+// Elements for each sample of timingLeak: the fixed elements for every sample
+// of class 0 and as many random ones for class 1. Both classes are laid out
+// alike in one block of memory, so that neither is quicker to load.
+func timingInputs(f *limbField, fixed []Element, samples int) [2][][]Element {
+    n, count := f.arith.limbs(), len(fixed)
+    block := make([]uint64, 2 * samples * count * n)
+    inputs := [2][][]Element{}
+    for class := range(inputs) {
+        inputs[class] = make([][]Element, samples)
+        for i := range(inputs[class]) {
+            inputs[class][i] = make([]Element, count)
+            for j := range(inputs[class][i]) {
+                offset := ((2 * i + class) * count + j) * n
+                inputs[class][i][j] = block[offset:offset + n]
+                x := fixed[j]
+                if class == 1 {
+                    x, _ = f.Random(rand.Reader)
+                }
+                copy(inputs[class][i][j], x)
+            }
+        }
+    }
+    return inputs
+}
+
+// As in dudect the fixed elements of the full tests are themselves random: all
+// zeros draws less power than random data, and on processors that scale their
+// frequency with power that shows up as a leak in any code.
+func randomElements(f *limbField, count int) []Element {
+    res := make([]Element, count)
+    for j := range(res) {
+        res[j], _ = f.Random(rand.Reader)
+    }
+    return res
+}
+
+// The encodings of the first element of each of inputs, for timing Decode.
+func timingEncodings(f *limbField, inputs [2][][]Element) [2][][]byte {
+    encodings := [2][][]byte{}
+    for class := range(encodings) {
+        encodings[class] = make([][]byte, len(inputs[class]))
+        for i := range(encodings[class]) {
+            encodings[class][i] = f.Encode(inputs[class][i][0])
+        }
+    }
+    return encodings
+}
+
+// Times the limb primitives, and the Field methods shares and secrets go
+// through on the way from parsing to output.
+func TestConstantTime(t *testing.T) {
+    skipUnlessTiming(t)
+    const DEGREE = 4
+    for _, field := range(fields) {
+        f := field.(*limbField)
+        n := f.arith.limbs()
+        z, term := make([]uint64, n), make([]uint64, n)
+        x := f.FromBig(big.NewInt(17))
+        xs := []Element{x, f.FromBig(big.NewInt(42))}
+        weight, _ := f.Random(rand.Reader)
+        pairs := timingInputs(f, randomElements(f, 2), TIMING_SAMPLES)
+        polynomials := timingInputs(f, randomElements(f, DEGREE + 1), TIMING_SAMPLES)
+        encodings := timingEncodings(f, pairs)
+        weights := make([]Element, DEGREE + 1)
+        for j := range(weights) {
+            weights[j] = weight
+        }
+        operations := map[string]func(class, i int){
+            "mul": func(class, i int) {
+                f.arith.mul(z, pairs[class][i][0], pairs[class][i][1])
+            },
+            "horner": func(class, i int) {
+                hornerLimbs(f.arith, z, polynomials[class][i], x)
+            },
+            "dot": func(class, i int) {
+                dotLimbs(f.arith, z, term, polynomials[class][i], weights)
+            },
+            "Field.Evaluate": func(class, i int) {
+                f.Evaluate(polynomials[class][i], xs)
+            },
+            "Field.Dot": func(class, i int) {
+                f.Dot(polynomials[class][i], weights)
+            },
+            "Field.Encode": func(class, i int) {
+                f.Encode(pairs[class][i][0])
+            },
+            "Field.Decode": func(class, i int) {
+                f.Decode(encodings[class][i])
+            },
+        }
+        for name, run := range(operations) {
+            if tt := timingLeak(TIMING_SAMPLES, run); math.Abs(tt) > TIMING_T_THRESHOLD {
+                t.Errorf("%s: %s takes different times on fixed and on random inputs, t = %.1f", field.Name(), name, tt)
+            }
+        }
+    }
+}
+
+// The quick version of TestConstantTime, for the Field methods doing the
+// arithmetic. The fixed inputs are all ones, which math/big gets through far
+// quicker than random elements.
+func TestConstantTimeQuick(t *testing.T) {
+    const DEGREE = 4
+    for _, field := range(fields) {
+        f := field.(*limbField)
+        one := f.One()
+        ones := []Element{one, one, one, one, one}
+        xs := []Element{f.FromBig(big.NewInt(17)), f.FromBig(big.NewInt(42))}
+        weights := randomElements(f, DEGREE + 1)
+        pairs := timingInputs(f, ones[:2], TIMING_QUICK_SAMPLES)
+        polynomials := timingInputs(f, ones[:DEGREE + 1], TIMING_QUICK_SAMPLES)
+        operations := map[string]func(class, i int){
+            "Field.Mul": func(class, i int) {
+                f.Mul(pairs[class][i][0], pairs[class][i][1])
+            },
+            "Field.Inv": func(class, i int) {
+                f.Inv(pairs[class][i][0])
+            },
+            "Field.Evaluate": func(class, i int) {
+                f.Evaluate(polynomials[class][i], xs)
+            },
+            "Field.Dot": func(class, i int) {
+                f.Dot(polynomials[class][i], weights)
+            },
+        }
+        for name, run := range(operations) {
+            samples := TIMING_QUICK_SAMPLES
+            if name == "Field.Inv" {
+                samples = TIMING_QUICK_INV_SAMPLES
+            }
+            if tt := timingLeak(samples, run); math.Abs(tt) > TIMING_QUICK_T_THRESHOLD {
+                t.Errorf("%s: %s takes very different times on ones and on random inputs, t = %.1f", field.Name(), name, tt)
+            }
+        }
+    }
+}
+
+// The harness has to be able to see a leak: ssss's multiplication loops over
+// the bits of b, so b = 1 is much quicker than a random b.
+func TestConstantTimeDetectsLeak(t *testing.T) {
+    skipUnlessTiming(t)
+    ssss := newSsssField(128)
+    a, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+    inputs := [2][]*big.Int{make([]*big.Int, TIMING_SAMPLES), make([]*big.Int, TIMING_SAMPLES)}
+    for i := 0; i < TIMING_SAMPLES; i++ {
+        inputs[0][i] = big.NewInt(1)
+        inputs[1][i], _ = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+    }
+    if tt := timingLeak(TIMING_SAMPLES, func(class, i int) { ssss.mul(a, inputs[class][i]) }); math.Abs(tt) <= TIMING_T_THRESHOLD {
+        t.Errorf("Expecting the timing test to catch ssss's multiplication, got t = %.1f", tt)
+    }
+}
+
+// The quick version has to see the leak it is there for: multiplying with
+// math/big takes less time by one than by a random element.
+func TestConstantTimeQuickDetectsLeak(t *testing.T) {
+    p127, _ := new(big.Int).SetString(PRIME, 10)
+    a, _ := rand.Int(rand.Reader, p127)
+    inputs := [2][]*big.Int{make([]*big.Int, TIMING_QUICK_SAMPLES), make([]*big.Int, TIMING_QUICK_SAMPLES)}
+    for i := 0; i < TIMING_QUICK_SAMPLES; i++ {
+        inputs[0][i] = big.NewInt(1)
+        inputs[1][i], _ = rand.Int(rand.Reader, p127)
+    }
+    z := new(big.Int)
+    if tt := timingLeak(TIMING_QUICK_SAMPLES, func(class, i int) { z.Mod(z.Mul(a, inputs[class][i]), p127) }); math.Abs(tt) <= TIMING_QUICK_T_THRESHOLD {
+        t.Errorf("Expecting the quick timing test to catch math/big's multiplication, got t = %.1f", tt)
+    }
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+    "bytes"
+    "crypto/ed25519"
+    "encoding/base32"
+    "encoding/base64"
+    "encoding/binary"
+    "encoding/hex"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "math/big"
+    "sort"
+    "strings"
+)
+
+// First byte of every binary share record. Together with the trailing CRC it
+// lets combine recognise a record whatever text encoding it arrives in.
+const SHARE_MAGIC = 0x53
+
+// Version of the binary share record layout.
+const SHARE_VERSION = 1
+
+// Version of the layout for shares at x-coordinates too big for a uvarint
+// field, which write the share number as a length and big-endian bytes, like
+// the subsecrets. Shares numbered up to MAX_SHARE_FIELD are still written in
+// the first version.
+const SHARE_VERSION_BIG_INDEX = 2
+
+// Version of the layout for shares split over a field other than the
+// default. The field's name follows the split ID, the share number is written
+// as in version 2 and each subsecret is an element of the field, in its fixed
+// length encoding.
+const SHARE_VERSION_FIELD = 3
+
+// The largest value of a uvarint field in a binary share record.
+const MAX_SHARE_FIELD = 1 << 31
+
+// Name of the original encoding, where a share is printed as its number
+// followed by '+'-joined decimal subsecret shares.
+const DECIMAL_ENCODING = "decimal"
+
+// A way of writing a binary share record as text. New encodings only need to
+// be added to shareEncodings to be usable from split and detected by combine.
+type shareEncoding struct {
+    name   string
+    encode func(data []byte) string
+    decode func(s string) ([]byte, error)
+}
+
+// Crockford's base32 alphabet leaves out I, L, O and U so that shares read
+// aloud or copied by hand are hard to get wrong.
+var crockford = base32.NewEncoding("0123456789ABCDEFGHJKMNPQRSTVWXYZ").WithPadding(base32.NoPadding)
+
+// The text encodings, in the order combine tries them.
+var shareEncodings = []shareEncoding{
+    {"hex", hex.EncodeToString, hex.DecodeString},
+    {"base32", encodeCrockford, decodeCrockford},
+    {"base64", base64.StdEncoding.EncodeToString, base64.StdEncoding.DecodeString},
+    {"base64url", base64.RawURLEncoding.EncodeToString, base64.RawURLEncoding.DecodeString},
+    {"words", encodeWords, decodeWords},
+}
+
+func lookupShareEncoding(name string) (shareEncoding, error) {
+    for _, enc := range(shareEncodings) {
+        if enc.name == name {
+            return enc, nil
+        }
+    }
+    names := []string{DECIMAL_ENCODING}
+    for _, enc := range(shareEncodings) {
+        names = append(names, enc.name)
+    }
+    sort.Strings(names)
+    return shareEncoding{}, newError(ErrInvalidParameters, fmt.Sprintf("Unknown share encoding %q, expected one of: %s.", name, strings.Join(names, ", ")))
+}
+
+// Encodes data in Crockford base32, split into dash separated groups of four
+// characters to make it easier to copy.
+// E.g. "5N4Z-0C9A-V5X2"
+func encodeCrockford(data []byte) string {
+    s := crockford.EncodeToString(data)
+    groups := []string{}
+    for i := 0; i < len(s); i += 4 {
+        end := i + 4
+        if end > len(s) {
+            end = len(s)
+        }
+        groups = append(groups, s[i:end])
+    }
+    return strings.Join(groups, "-")
+}
+
+// Decodes Crockford base32, ignoring case and dashes and reading the easily
+// confused letters O, I and L as the digits they look like.
+func decodeCrockford(s string) ([]byte, error) {
+    s = strings.ToUpper(strings.ReplaceAll(s, "-", ""))
+    s = strings.NewReplacer("O", "0", "I", "1", "L", "1").Replace(s)
+    return crockford.DecodeString(s)
+}
+
+// Serializes a share record as:
+// magic (1 byte) | version (1 byte) | signed flag (1 byte)
+// | split ID length (1 byte) | split ID | n, t, index (uvarints)
+// | subsecret count (uvarint) | for each subsecret: length (uvarint), big-endian y
+// | signature (64 bytes, only if signed) | CRC-32 of everything before (4 bytes)
+// In version 2 the index is written like a subsecret, as a length and
+// big-endian bytes. Version 3 adds the field, see SHARE_VERSION_FIELD.
+func (r shareRecord) marshalBinary() ([]byte, error) {
+    split_id, err := hex.DecodeString(r.splitID)
+    if err != nil || len(split_id) > 255 {
+        return nil, newError(ErrMalformedShare, "Split ID must be at most 255 hex encoded bytes.")
+    }
+    field, err := lookupField(r.fieldName())
+    if err != nil {
+        return nil, err
+    }
+    subsecrets := strings.Split(r.payload, "+")
+
+    var buf bytes.Buffer
+    signed := byte(0)
+    if len(r.signature) > 0 {
+        signed = 1
+    }
+    version := byte(SHARE_VERSION)
+    if r.field != "" {
+        version = SHARE_VERSION_FIELD
+    } else if r.index.Cmp(big.NewInt(MAX_SHARE_FIELD)) > 0 {
+        version = SHARE_VERSION_BIG_INDEX
+    }
+    buf.Write([]byte{SHARE_MAGIC, version, signed, byte(len(split_id))})
+    buf.Write(split_id)
+    if version == SHARE_VERSION_FIELD {
+        buf.WriteByte(byte(len(r.field)))
+        buf.WriteString(r.field)
+    }
+    writeUvarint(&buf, uint64(r.n))
+    writeUvarint(&buf, uint64(r.t))
+    if version != SHARE_VERSION {
+        writeUvarint(&buf, uint64(len(r.index.Bytes())))
+        buf.Write(r.index.Bytes())
+    } else {
+        writeUvarint(&buf, r.index.Uint64())
+    }
+    writeUvarint(&buf, uint64(len(subsecrets)))
+    for _, subsecret := range(subsecrets) {
+        if !isShareNumber(subsecret) {
+            return nil, newError(ErrMalformedShare, "Shares must be of the form: 'int+int+int+..+int'.")
+        }
+        y, err := field.Parse(subsecret)
+        if version == SHARE_VERSION_FIELD {
+            if err != nil {
+                return nil, newError(ErrMalformedShare, fmt.Sprintf("Share %s is too big for the %s field.", r.index, field.Name()))
+            }
+            buf.Write(field.Encode(y))
+            continue
+        }
+        b := legacySubsecretBytes(field, y, subsecret)
+        writeUvarint(&buf, uint64(len(b)))
+        buf.Write(b)
+    }
+    buf.Write(r.signature)
+    sum := make([]byte, 4)
+    binary.BigEndian.PutUint32(sum, crc32.ChecksumIEEE(buf.Bytes()))
+    buf.Write(sum)
+    return buf.Bytes(), nil
+}
+
+// Records from before SHARE_VERSION_FIELD write a subsecret as its
+// big-endian bytes without leading zeros, and nothing checked that it was an
+// element. Elements go through the field in constant time; anything bigger
+// cannot be a share of a secret, and is written with math/big as it was.
+func legacySubsecretBytes(field Field, y Element, subsecret string) []byte {
+    if y != nil {
+        return elementBytes(field, y, 0)
+    }
+    x, _ := new(big.Int).SetString(subsecret, 10)
+    return x.Bytes()
+}
+
+// Reads a subsecret written by legacySubsecretBytes back as decimal.
+func legacySubsecretString(field Field, b []byte) string {
+    if length := elementLength(field); len(b) <= length {
+        padded := make([]byte, length)
+        copy(padded[length - len(b):], b)
+        if y, err := field.Decode(padded); err == nil {
+            return field.Format(y)
+        }
+    }
+    return new(big.Int).SetBytes(b).String()
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+    tmp := make([]byte, binary.MaxVarintLen64)
+    buf.Write(tmp[:binary.PutUvarint(tmp, v)])
+}
+
+// Parses a record written by marshalBinary, checking the magic byte, version
+// and CRC.
+func unmarshalShareRecord(data []byte) (shareRecord, error) {
+    malformed := newError(ErrMalformedShare, "Malformed binary share record.")
+    if len(data) < 8 || data[0] != SHARE_MAGIC {
+        return shareRecord{}, malformed
+    }
+    body, sum := data[:len(data)-4], data[len(data)-4:]
+    if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(sum) {
+        return shareRecord{}, newError(ErrMalformedShare, "Share record checksum does not match, the share has been mistyped or corrupted.")
+    }
+    version := body[1]
+    if version != SHARE_VERSION && version != SHARE_VERSION_BIG_INDEX && version != SHARE_VERSION_FIELD {
+        return shareRecord{}, newError(ErrMalformedShare, fmt.Sprintf("Unsupported share record version %d.", body[1]))
+    }
+    signed := body[2]
+    if signed > 1 {
+        return shareRecord{}, malformed
+    }
+    r := bytes.NewReader(body[4:])
+    split_id := make([]byte, body[3])
+    if _, err := io.ReadFull(r, split_id); err != nil {
+        return shareRecord{}, malformed
+    }
+    field_name := ""
+    field, _ := lookupField(DEFAULT_FIELD)
+    if version == SHARE_VERSION_FIELD {
+        length, err := r.ReadByte()
+        name := make([]byte, length)
+        if err != nil || r.Len() < int(length) {
+            return shareRecord{}, malformed
+        }
+        io.ReadFull(r, name)
+        field_name = string(name)
+        if field, err = lookupField(field_name); err != nil || field_name == DEFAULT_FIELD {
+            return shareRecord{}, newError(ErrMalformedShare, fmt.Sprintf("Bad field %q in share record.", field_name))
+        }
+    }
+
+    fields := make([]int, 4)
+    index := new(big.Int)
+    for i := range(fields) {
+        v, err := binary.ReadUvarint(r)
+        if err != nil || v > MAX_SHARE_FIELD {
+            return shareRecord{}, malformed
+        }
+        fields[i] = int(v)
+        if i == 2 && version != SHARE_VERSION {
+            if fields[i] > r.Len() {
+                return shareRecord{}, malformed
+            }
+            b := make([]byte, fields[i])
+            io.ReadFull(r, b)
+            index.SetBytes(b)
+        } else if i == 2 {
+            index.SetInt64(int64(v))
+        }
+    }
+    if fields[3] < 1 || fields[3] > r.Len() {
+        return shareRecord{}, malformed
+    }
+    subsecrets := make([]string, fields[3])
+    for i := range(subsecrets) {
+        if version == SHARE_VERSION_FIELD {
+            y := make([]byte, elementLength(field))
+            if _, err := io.ReadFull(r, y); err != nil {
+                return shareRecord{}, malformed
+            }
+            element, err := field.Decode(y)
+            if err != nil {
+                return shareRecord{}, err
+            }
+            subsecrets[i] = field.Format(element)
+            continue
+        }
+        length, err := binary.ReadUvarint(r)
+        if err != nil || length > uint64(r.Len()) {
+            return shareRecord{}, malformed
+        }
+        y := make([]byte, length)
+        io.ReadFull(r, y)
+        subsecrets[i] = legacySubsecretString(field, y)
+    }
+
+    record := shareRecord{
+        index:   index,
+        splitID: hex.EncodeToString(split_id),
+        field:   field_name,
+        n:       fields[0],
+        t:       fields[1],
+        payload: strings.Join(subsecrets, "+"),
+    }
+    if signed == 1 {
+        record.signature = make([]byte, ed25519.SignatureSize)
+        if _, err := io.ReadFull(r, record.signature); err != nil {
+            return shareRecord{}, malformed
+        }
+    }
+    if r.Len() != 0 {
+        return shareRecord{}, malformed
+    }
+    return record, nil
+}
+
+// Writes a record as a single token in the given encoding.
+func encodeShareRecord(r shareRecord, enc shareEncoding) (string, error) {
+    data, err := r.marshalBinary()
+    if err != nil {
+        return "", err
+    }
+    return enc.encode(data), nil
+}
+
+// Reads a record written by encodeShareRecord, working out which encoding it
+// uses. Returns the name of the encoding alongside the record. If s decodes
+// to something that looks like a record but fails its checks, that error is
+// returned rather than a generic one, so mistyped shares are reported as such.
+func decodeShareRecord(s string) (shareRecord, string, error) {
+    // Words are only ever decoded as words, so that a typo is reported with
+    // its suggested corrections.
+    if looksLikeWords(s) {
+        data, err := decodeWords(s)
+        if err != nil {
+            return shareRecord{}, "", err
+        }
+        r, err := unmarshalShareRecord(data)
+        return r, "words", err
+    }
+
+    res_err := newError(ErrMalformedShare, "Share is not a valid share record in any known encoding.")
+    for _, enc := range(shareEncodings) {
+        data, err := enc.decode(s)
+        if err != nil {
+            continue
+        }
+        r, err := unmarshalShareRecord(data)
+        if err == nil {
+            return r, enc.name, nil
+        }
+        if len(data) > 0 && data[0] == SHARE_MAGIC {
+            res_err = err
+        }
+    }
+    return shareRecord{}, "", res_err
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+    "crypto/ed25519"
+    "errors"
+    "math/big"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestShareRecordBinaryEncodings(t *testing.T) {
+    record := shareRecord{index: big.NewInt(3), splitID: "0badc0ffee", n: 5, t: 3, payload: "6594609373809678819225323545135716699+0+4260017448664758059915243222653800210"}
+    for _, enc := range(shareEncodings) {
+        token, err := encodeShareRecord(record, enc)
+        if err != nil {
+            t.Fatal(err)
+        }
+        result, name, err := decodeShareRecord(token)
+        if err != nil {
+            t.Fatalf("%s: %s", enc.name, err)
+        }
+        if name != enc.name {
+            t.Errorf("Expected %s to be detected, got %s", enc.name, name)
+        }
+        if !reflect.DeepEqual(record, result) {
+            t.Errorf("%s: expecting %v, got: %v", enc.name, record, result)
+        }
+    }
+}
+
+// Share numbers too big for a uvarint field are written in the second
+// version of the layout; small ones still in the first.
+func TestShareRecordBigIndex(t *testing.T) {
+    x, _ := new(big.Int).SetString("123456789012345678901234567890123456789", 10)
+    record := shareRecord{index: x, splitID: "0badc0ffee", t: 3, payload: "23+100+19"}
+    enc, _ := lookupShareEncoding("hex")
+    for index, version := range(map[*big.Int]byte{x: SHARE_VERSION_BIG_INDEX, big.NewInt(MAX_SHARE_FIELD): SHARE_VERSION}) {
+        record.index = index
+        data, err := record.marshalBinary()
+        if err != nil || data[1] != version {
+            t.Errorf("Expecting version %d for share %s, got: %x (%v)", version, index, data, err)
+        }
+        result, _, err := decodeShareRecord(enc.encode(data))
+        if err != nil || !reflect.DeepEqual(record, result) {
+            t.Errorf("Expecting %v, got: %v (%v)", record, result, err)
+        }
+        if result, err := parseShareRecord(record.String()); err != nil || result.index.Cmp(index) != 0 {
+            t.Errorf("Expecting share %s, got: %v (%v)", index, result, err)
+        }
+    }
+}
+
+func TestSignedShareRecordSurvivesEncoding(t *testing.T) {
+    pub, priv, _ := ed25519.GenerateKey(nil)
+    record := shareRecord{index: big.NewInt(1), splitID: "ab12", n: 3, t: 2, payload: "23+100+19"}
+    record.sign(priv)
+    enc, _ := lookupShareEncoding("base64url")
+    token, _ := encodeShareRecord(record, enc)
+    result, err := parseShareToken(token)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := result.verify(pub); err != nil {
+        t.Errorf("Expected signature to verify after encoding, got: %s", err)
+    }
+}
+
+func TestCrockfordBase32(t *testing.T) {
+    data := []byte("Hello, World!")
+    s := encodeCrockford(data)
+    for _, c := range("ILOU") {
+        if strings.ContainsRune(s, c) {
+            t.Errorf("Expected %s not to contain %c", s, c)
+        }
+    }
+
+    // Lower case and look-alike letters must decode to the same bytes.
+    sloppy := strings.NewReplacer("0", "o", "1", "l", "-", "").Replace(strings.ToLower(s))
+    result, err := decodeCrockford(sloppy)
+    if err != nil || string(result) != string(data) {
+        t.Errorf("Expecting %s, got: %s (%v)", data, result, err)
+    }
+}
+
+func TestShareRecordChecksum(t *testing.T) {
+    record := shareRecord{index: big.NewInt(2), splitID: "ab12", n: 3, t: 2, payload: "345+99+50"}
+    enc, _ := lookupShareEncoding("hex")
+    token, _ := encodeShareRecord(record, enc)
+
+    // Flip one hex digit in the middle of the share.
+    i := len(token) / 2
+    c := byte('0')
+    if token[i] == '0' {
+        c = '1'
+    }
+    mistyped := token[:i] + string(c) + token[i+1:]
+    if _, _, err := decodeShareRecord(mistyped); !errors.Is(err, ErrMalformedShare) {
+        t.Errorf("Expected mistyped share to be rejected, got: %v", err)
+    }
+
+    if _, err := lookupShareEncoding("rot13"); !errors.Is(err, ErrInvalidParameters) {
+        t.Errorf("Expected unknown encoding to be rejected, got: %v", err)
+    }
+}
+
+// Shares split over a field other than the default name it, as the last part
+// of a text record and in the third version of the binary layout, and the
+// dealer signature covers it.
+func TestShareRecordField(t *testing.T) {
+    pub, priv, _ := ed25519.GenerateKey(nil)
+    record := shareRecord{index: big.NewInt(2), splitID: "0badc0ffee", field: "gf128", n: 3, t: 2, payload: "0+340282366920938463463374607431768211455"}
+    record.sign(priv)
+    if text := record.String(); !strings.HasSuffix(text, ":gf128") {
+        t.Errorf("Expecting the field at the end of %s", text)
+    }
+    if result, err := parseShareRecord(record.String()); err != nil || !reflect.DeepEqual(record, result) {
+        t.Errorf("Expecting %v, got: %v (%v)", record, result, err)
+    }
+    for _, enc := range(shareEncodings) {
+        token, err := encodeShareRecord(record, enc)
+        if err != nil {
+            t.Fatal(err)
+        }
+        result, _, err := decodeShareRecord(token)
+        if err != nil || !reflect.DeepEqual(record, result) {
+            t.Errorf("%s: expecting %v, got: %v (%v)", enc.name, record, result, err)
+        }
+        if err := result.verify(pub); err != nil {
+            t.Errorf("%s: expecting the signature to verify, got: %v", enc.name, err)
+        }
+    }
+    if data, _ := record.marshalBinary(); data[1] != SHARE_VERSION_FIELD {
+        t.Errorf("Expecting version %d, got: %d", SHARE_VERSION_FIELD, data[1])
+    }
+
+    moved := record
+    moved.field = "p521"
+    if err := moved.verify(pub); err == nil {
+        t.Errorf("Expected a share moved to another field to be rejected")
+    }
+    record.payload = "1+340282366920938463463374607431768211456"
+    if _, err := record.marshalBinary(); !errors.Is(err, ErrMalformedShare) {
+        t.Errorf("Expected a share too big for the field to be rejected, got: %v", err)
+    }
+    for _, s := range([]string{"shamir1:ab12:3:2:1:23+100::p128", "shamir1:ab12:3:2:1:23+100::p127"}) {
+        if _, err := parseShareRecord(s); !errors.Is(err, ErrMalformedShare) {
+            t.Errorf("Expected %s to be rejected, got: %v", s, err)
+        }
+    }
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+    "errors"
+)
+
+// Errors returned by the split and combine functions. Every error they return
+// wraps one of these, so callers can tell them apart with errors.Is.
+var (
+    // The secret, n or t are not acceptable for a split.
+    ErrInvalidParameters = errors.New("invalid parameters")
+
+    // A share could not be parsed, or does not fit with the other shares.
+    ErrMalformedShare = errors.New("malformed share")
+
+    // Two of the shares have the same share number.
+    ErrDuplicateIndex = errors.New("duplicate share index")
+
+    // Fewer shares were given than are needed to recover the secret.
+    ErrThresholdNotMet = errors.New("threshold not met")
+
+    // A share is unsigned, or its dealer signature does not verify.
+    ErrUntrustedShare = errors.New("untrusted share")
+
+    // Reading from the random number generator failed.
+    ErrRandomness = errors.New("randomness source failed")
+)
+
+// An error with a message meant for the user, wrapping one of the errors
+// above. The message is printed as is, so it stays readable on the command
+// line while errors.Is still matches the underlying kind.
+type shamirError struct {
+    kind error
+    msg  string
+}
+
+func (e *shamirError) Error() string {
+    return e.msg
+}
+
+func (e *shamirError) Unwrap() error {
+    return e.kind
+}
+
+func newError(kind error, msg string) error {
+    return &shamirError{kind, msg}
+}
+
+// The exit code the command line reports for err.
+func exitCode(err error) int {
+    switch {
+        case errors.Is(err, ErrInvalidParameters):
+            return EXIT_USAGE
+        case errors.Is(err, ErrMalformedShare), errors.Is(err, ErrDuplicateIndex), errors.Is(err, ErrThresholdNotMet):
+            return EXIT_INVALID_SHARES
+        case errors.Is(err, ErrUntrustedShare):
+            return EXIT_REJECTED
+    }
+    return EXIT_FAILURE
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+    err := newError(ErrDuplicateIndex, "Share 2 given more than once.")
+    if err.Error() != "Share 2 given more than once." {
+        t.Errorf("Expected message to be kept, got: %s", err)
+    }
+    if !errors.Is(err, ErrDuplicateIndex) || errors.Is(err, ErrMalformedShare) {
+        t.Errorf("Expected error to match only ErrDuplicateIndex")
+    }
+    wrapped := fmt.Errorf("combining: %w", err)
+    if !errors.Is(wrapped, ErrDuplicateIndex) {
+        t.Errorf("Expected wrapped error to still match ErrDuplicateIndex")
+    }
+}
+
+func TestExitCode(t *testing.T) {
+    tests := map[error]int{
+        ErrInvalidParameters:   EXIT_USAGE,
+        ErrMalformedShare:      EXIT_INVALID_SHARES,
+        ErrDuplicateIndex:      EXIT_INVALID_SHARES,
+        ErrThresholdNotMet:     EXIT_INVALID_SHARES,
+        ErrUntrustedShare:      EXIT_REJECTED,
+        ErrRandomness:          EXIT_FAILURE,
+        errors.New("unknown"):  EXIT_FAILURE,
+    }
+    for kind, expected := range(tests) {
+        if result := exitCode(newError(kind, "message")); result != expected {
+            t.Errorf("Expected exit code %d for %v, got %d", expected, kind, result)
+        }
+    }
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+    "crypto/elliptic"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "math/big"
+    "strings"
+)
+
+// The finite fields native secrets can be split over. A secret is cut into
+// chunks small enough to be elements of the field, and each chunk is shared
+// on its own, so a bigger field means fewer, longer subsecrets: a 64 byte key
+// fits in a single element of the 2^521 - 1 field.
+
+// An element of a field, as the field's arithmetic holds it: a fixed number
+// of 64-bit limbs, least significant first, in the field's own form (for
+// most prime fields, Montgomery form). Elements only get in and out through
+// the field, and only Parse, Format, Encode and Decode take secret values
+// in and out, all in constant time.
+type Element []uint64
+
+// Arithmetic in a finite field whose elements are the integers
+// 0..Size()-1. For prime fields these are the residues themselves; for
+// binary fields their bits are the coefficients of a polynomial over GF(2).
+// Everything but FromBig and ToBig runs in constant time, see consttime.go,
+// so secrets and shares stay as Elements from parsing through to output;
+// big.Ints are only for public values such as x-coordinates.
+type Field interface {
+    // The name split -field and share records know the field by.
+    Name() string
+    // The number of elements.
+    Size() *big.Int
+    // The number of bytes of secret in each element.
+    ChunkSize() int
+    Add(a, b Element) Element
+    Sub(a, b Element) Element
+    Mul(a, b Element) Element
+    // The multiplicative inverse of a, or nil if a is 0.
+    Inv(a Element) Element
+    One() Element
+    // An element drawn uniformly at random from random.
+    Random(random io.Reader) (Element, error)
+    // Writes an element as fixed length big-endian bytes, and reads it back,
+    // rejecting anything that is not an element.
+    Encode(a Element) []byte
+    Decode(b []byte) (Element, error)
+    // Reads an element written in decimal, rejecting anything that is not
+    // one, and writes it back without leading zeros.
+    Parse(s string) (Element, error)
+    Format(a Element) string
+    // Converts a public value to an element, taking it mod the size of the
+    // field, and back. These use math/big and must not be given secrets.
+    FromBig(x *big.Int) Element
+    ToBig(a Element) *big.Int
+    // Evaluates the polynomial with the given coefficients (constant term
+    // first) at each of xs, by Horner's method.
+    Evaluate(coefficients []Element, xs []Element) []Element
+    // The sum of ys[i] * weights[i], as in Lagrange interpolation.
+    Dot(ys, weights []Element) Element
+}
+
+// The field native shares are split over unless told otherwise, and which
+// shares from before there was a choice were split over.
+const DEFAULT_FIELD = "p127"
+
+// The order of the Ed25519 base point, 2^252 + 27742317777372353535851937790883648493.
+const ED25519_ORDER = "7237005577332262213973186563042994240857116359379907606001950938285454250989"
+
+// The fields split -field accepts, in the order they are listed.
+var fields = func() []Field {
+    p127, _ := new(big.Int).SetString(PRIME, 10)
+    p521 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 521), big.NewInt(1))
+    ed25519_order, _ := new(big.Int).SetString(ED25519_ORDER, 10)
+    return []Field{
+        newPrimeField(DEFAULT_FIELD, p127),
+        newPrimeField("p521", p521),
+        newPrimeField("p256-scalar", elliptic.P256().Params().N),
+        newPrimeField("ed25519-scalar", ed25519_order),
+        newBinaryField("gf256", 8),
+        newBinaryField("gf128", 128),
+    }
+}()
+
+func lookupField(name string) (Field, error) {
+    names := []string{}
+    for _, field := range(fields) {
+        if field.Name() == name {
+            return field, nil
+        }
+        names = append(names, field.Name())
+    }
+    return nil, newError(ErrInvalidParameters, fmt.Sprintf("Unknown field %q, expected one of: %s.", name, strings.Join(names, ", ")))
+}
+
+// The length of an encoded element of a field.
+func elementLength(field Field) int {
+    return (new(big.Int).Sub(field.Size(), big.NewInt(1)).BitLen() + 7) / 8
+}
+
+// The big-endian bytes of a without leading zeros, but at least length
+// bytes long as long as that is no longer than an encoded element. With a
+// length of 0 this is what big.Int.Bytes gives. Only the zeros that are
+// dropped, which the result shows anyway, decide how long this takes.
+func elementBytes(field Field, a Element, length int) []byte {
+    b := field.Encode(a)
+    start := 0
+    for start < len(b) - length && b[start] == 0 {
+        start++
+    }
+    return b[start:]
+}
+
+// A field whose arithmetic is done on limbs, in constant time.
+type limbField struct {
+    name  string
+    size  *big.Int
+    chunk int
+    arith limbArithmetic
+    // The size of the field as limbs, with one limb to spare, which Decode
+    // and Parse check values against.
+    sizeLimbs []uint64
+    // The number of decimal digits of the largest element.
+    digits int
+}
+
+func newLimbField(name string, size *big.Int, chunk int, arith limbArithmetic) *limbField {
+    f := &limbField{name, size, chunk, arith, make([]uint64, arith.limbs() + 1), len(new(big.Int).Sub(size, big.NewInt(1)).String())}
+    bigToLimbs(f.sizeLimbs, size)
+    return f
+}
+
+// The integers mod an odd prime p.
+func newPrimeField(name string, p *big.Int) *limbField {
+    chunk := (p.BitLen() - 1) / 8
+    if p.Cmp(mersenne127) == 0 {
+        return newLimbField(name, p, chunk, mersenne127Arithmetic{})
+    }
+    return newLimbField(name, p, chunk, newMontgomery(p))
+}
+
+// GF(2^degree), with the same polynomials as ssss: for GF(2^8) that is the
+// AES polynomial x^8 + x^4 + x^3 + x + 1, and for GF(2^128) the GCM
+// polynomial x^128 + x^7 + x^2 + x + 1.
+func newBinaryField(name string, degree int) *limbField {
+    return newLimbField(name, new(big.Int).Lsh(big.NewInt(1), uint(degree)), degree / 8, newGF2k(degree))
+}
+
+func (f *limbField) Name() string {
+    return f.name
+}
+
+func (f *limbField) Size() *big.Int {
+    return f.size
+}
+
+func (f *limbField) ChunkSize() int {
+    return f.chunk
+}
+
+func (f *limbField) zero() Element {
+    return make(Element, f.arith.limbs())
+}
+
+// Takes a, given as arith.limbs() + 1 plain limbs, into the field's form if
+// it is less than the size of the field. The comparison is by the borrow of
+// a - size, so it takes the same time for every value.
+func (f *limbField) fromPlain(a []uint64) (Element, bool) {
+    if lessLimbs(a, f.sizeLimbs) == 0 {
+        return nil, false
+    }
+    z := f.zero()
+    f.arith.fromLimbs(z, a[:len(z)])
+    return z, true
+}
+
+func (f *limbField) Add(a, b Element) Element {
+    z := f.zero()
+    f.arith.add(z, a, b)
+    return z
+}
+
+func (f *limbField) Sub(a, b Element) Element {
+    z := f.zero()
+    f.arith.sub(z, a, b)
+    return z
+}
+
+func (f *limbField) Mul(a, b Element) Element {
+    z := f.zero()
+    f.arith.mul(z, a, b)
+    return z
+}
+
+// a^(size - 2), which is 1/a both mod a prime and in GF(2^k). 0 is all zero
+// limbs in every form, and only whether a is 0 is looked at.
+func (f *limbField) Inv(a Element) Element {
+    var nonzero uint64
+    for _, limb := range(a) {
+        nonzero |= limb
+    }
+    if nonzero == 0 {
+        return nil
+    }
+    z := f.zero()
+    powLimbs(f.arith, z, a, new(big.Int).Sub(f.size, big.NewInt(2)))
+    return z
+}
+
+func (f *limbField) One() Element {
+    z := f.zero()
+    f.arith.setOne(z)
+    return z
+}
+
+func (f *limbField) Random(random io.Reader) (Element, error) {
+    return randomElement(f, random)
+}
+
+func (f *limbField) Encode(a Element) []byte {
+    var plain [MAX_LIMBS]uint64
+    n := f.arith.limbs()
+    f.arith.toLimbs(plain[:n], a)
+    length := elementLength(f)
+    full := make([]byte, 8 * n)
+    for i := 0; i < n; i++ {
+        binary.BigEndian.PutUint64(full[8 * (n - 1 - i):], plain[i])
+    }
+    return full[len(full) - length:]
+}
+
+func (f *limbField) Decode(b []byte) (Element, error) {
+    length := elementLength(f)
+    if len(b) != length {
+        return nil, newError(ErrMalformedShare, fmt.Sprintf("Elements of the %s field are %d bytes long.", f.name, length))
+    }
+    var plain [MAX_LIMBS + 1]uint64
+    for i := 0; i < length; i++ {
+        k := length - 1 - i
+        plain[k / 8] |= uint64(b[i]) << uint(8 * (k % 8))
+    }
+    z, ok := f.fromPlain(plain[:len(f.sizeLimbs)])
+    if !ok {
+        return nil, newError(ErrMalformedShare, fmt.Sprintf("Value too big for the %s field.", f.name))
+    }
+    return z, nil
+}
+
+// Reads the digits into limbs with a multiply by 10 and an add for each, so
+// the time taken depends only on how many digits there are. Leading zeros are
+// dropped first, and the rest must be no more than the largest element has,
+// which leaves room to spare in the extra limb.
+func (f *limbField) Parse(s string) (Element, error) {
+    if s == "" || strings.Trim(s, "0123456789") != "" {
+        return nil, newError(ErrMalformedShare, fmt.Sprintf("%q is not a decimal number.", s))
+    }
+    too_big := newError(ErrMalformedShare, fmt.Sprintf("Value too big for the %s field.", f.name))
+    digits := strings.TrimLeft(s, "0")
+    if len(digits) > f.digits {
+        return nil, too_big
+    }
+    var plain [MAX_LIMBS + 1]uint64
+    for i := 0; i < len(digits); i++ {
+        mulAddLimbs(plain[:len(f.sizeLimbs)], 10, uint64(digits[i] - '0'))
+    }
+    z, ok := f.fromPlain(plain[:len(f.sizeLimbs)])
+    if !ok {
+        return nil, too_big
+    }
+    return z, nil
+}
+
+// Writes as many digits as the largest element has, dividing by 10 for each,
+// and then drops the leading zeros, which the result shows anyway.
+func (f *limbField) Format(a Element) string {
+    var plain [MAX_LIMBS]uint64
+    n := f.arith.limbs()
+    f.arith.toLimbs(plain[:n], a)
+    b := make([]byte, f.digits)
+    for i := len(b) - 1; i >= 0; i-- {
+        b[i] = '0' + byte(divLimbs10(plain[:n]))
+    }
+    if s := strings.TrimLeft(string(b), "0"); s != "" {
+        return s
+    }
+    return "0"
+}
+
+func (f *limbField) FromBig(x *big.Int) Element {
+    if x.Sign() < 0 || x.Cmp(f.size) >= 0 {
+        x = new(big.Int).Mod(x, f.size)
+    }
+    z := f.zero()
+    bigToLimbs(z, x)
+    f.arith.fromLimbs(z, z)
+    return z
+}
+
+func (f *limbField) ToBig(a Element) *big.Int {
+    z := f.zero()
+    f.arith.toLimbs(z, a)
+    return limbsToBig(z)
+}
+
+func (f *limbField) Evaluate(coefficients []Element, xs []Element) []Element {
+    res := make([]Element, len(xs), len(xs))
+    for i, x := range(xs) {
+        res[i] = f.zero()
+        hornerLimbs(f.arith, res[i], coefficients, x)
+    }
+    return res
+}
+
+func (f *limbField) Dot(ys, weights []Element) Element {
+    z, term := f.zero(), f.zero()
+    dotLimbs(f.arith, z, term, ys, weights)
+    return z
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+    "bytes"
+    "crypto/rand"
+    "errors"
+    "math/big"
+    "strings"
+    "testing"
+)
+
+func TestFields(t *testing.T) {
+    for _, field := range(fields) {
+        if f, err := lookupField(field.Name()); err != nil || f != field {
+            t.Errorf("%s: expecting the field back by name, got: %v", field.Name(), err)
+        }
+        // A chunk of all ones is an element.
+        chunk := new(big.Int).SetBytes(bytes.Repeat([]byte{0xff}, field.ChunkSize()))
+        if chunk.Cmp(field.Size()) >= 0 {
+            t.Errorf("%s: chunks of %d bytes do not fit in the field", field.Name(), field.ChunkSize())
+        }
+        one := field.One()
+        if field.ToBig(one).Cmp(big.NewInt(1)) != 0 {
+            t.Errorf("%s: expecting 1, got: %s", field.Name(), field.ToBig(one))
+        }
+        if field.Inv(field.FromBig(big.NewInt(0))) != nil {
+            t.Errorf("%s: expecting 0 to have no inverse", field.Name())
+        }
+        for i := 0; i < 20; i++ {
+            a, _ := field.Random(rand.Reader)
+            b, _ := field.Random(rand.Reader)
+            x, y := field.ToBig(a), field.ToBig(b)
+            if x.Sign() < 0 || x.Cmp(field.Size()) >= 0 {
+                t.Fatalf("%s: random value %s is not an element", field.Name(), x)
+            }
+            if sum := field.Add(a, b); field.ToBig(field.Sub(sum, b)).Cmp(x) != 0 {
+                t.Errorf("%s: expecting (a + b) - b = a for a = %s, b = %s", field.Name(), x, y)
+            }
+            if field.ToBig(field.Mul(a, b)).Cmp(field.ToBig(field.Mul(b, a))) != 0 {
+                t.Errorf("%s: expecting ab = ba for a = %s, b = %s", field.Name(), x, y)
+            }
+            if x.Sign() != 0 && field.ToBig(field.Mul(a, field.Inv(a))).Cmp(big.NewInt(1)) != 0 {
+                t.Errorf("%s: expecting a / a = 1 for a = %s", field.Name(), x)
+            }
+            encoded := field.Encode(a)
+            if !bytes.Equal(encoded, x.FillBytes(make([]byte, elementLength(field)))) {
+                t.Errorf("%s: expecting %s to be encoded as big-endian bytes, got: %x", field.Name(), x, encoded)
+            }
+            if result, err := field.Decode(encoded); err != nil || field.ToBig(result).Cmp(x) != 0 {
+                t.Errorf("%s: expecting %s back from %x, got: %v (%v)", field.Name(), x, encoded, result, err)
+            }
+        }
+        // Decode takes exactly one element's worth of bytes, and only elements.
+        encoded := field.Encode(one)
+        if _, err := field.Decode(encoded[1:]); !errors.Is(err, ErrMalformedShare) {
+            t.Errorf("%s: expecting a short element to be rejected, got: %v", field.Name(), err)
+        }
+        if _, err := field.Decode(bytes.Repeat([]byte{0xff}, len(encoded))); field.Name() != "gf256" && field.Name() != "gf128" && !errors.Is(err, ErrMalformedShare) {
+            t.Errorf("%s: expecting a value beyond the field to be rejected, got: %v", field.Name(), err)
+        }
+    }
+    if _, err := lookupField("p128"); !errors.Is(err, ErrInvalidParameters) {
+        t.Errorf("Expected an unknown field to be rejected, got: %v", err)
+    }
+}
+
+// Multiplication in GF(2^8) agrees with the tables SLIP-39 and Vault use.
+func TestBinaryFieldGF256(t *testing.T) {
+    field, _ := lookupField("gf256")
+    for a := 0; a < 256; a += 7 {
+        for b := 0; b < 256; b += 5 {
+            if result := field.ToBig(field.Mul(field.FromBig(big.NewInt(int64(a))), field.FromBig(big.NewInt(int64(b))))); result.Int64() != int64(gf256Mul(byte(a), byte(b))) {
+                t.Errorf("Expecting %d * %d = %d, got: %s", a, b, gf256Mul(byte(a), byte(b)), result)
+            }
+        }
+    }
+}
+
+// Parse and Format agree with math/big on the values next to the limb
+// boundaries and the size of each field, and reject anything else.
+func TestParseFormat(t *testing.T) {
+    for _, field := range(fields) {
+        for _, x := range(limbTestValues(field)) {
+            a, err := field.Parse(x.String())
+            if err != nil || field.ToBig(a).Cmp(x) != 0 {
+                t.Errorf("%s: expecting %s to parse, got: %v (%v)", field.Name(), x, a, err)
+                continue
+            }
+            if s := field.Format(a); s != x.String() {
+                t.Errorf("%s: expecting %s, got: %s", field.Name(), x, s)
+            }
+            if a, err := field.Parse("000" + x.String()); err != nil || field.ToBig(a).Cmp(x) != 0 {
+                t.Errorf("%s: expecting leading zeros to be dropped from %s, got: %v (%v)", field.Name(), x, a, err)
+            }
+        }
+        too_big := new(big.Int).Mul(field.Size(), big.NewInt(10))
+        for _, s := range([]string{"", "-1", "+1", "1a", " 1", field.Size().String(), too_big.String(), too_big.String() + "0"}) {
+            if _, err := field.Parse(s); !errors.Is(err, ErrMalformedShare) {
+                t.Errorf("%s: expecting %q to be rejected, got: %v", field.Name(), s, err)
+            }
+        }
+    }
+}
+
+func TestSplitCombineFields(t *testing.T) {
+    secret := strings.Repeat("0123456789abcdef", 4)
+    for _, field := range(fields) {
+        shares, err := splitSecret(secret, 5, 3, field)
+        if err != nil {
+            t.Fatalf("%s: %s", field.Name(), err)
+        }
+        if subsecrets := len(strings.Split(shares[0], "+")); subsecrets != (len(secret) + field.ChunkSize() - 1) / field.ChunkSize() {
+            t.Errorf("%s: expecting chunks of %d bytes, got %d subsecrets", field.Name(), field.ChunkSize(), subsecrets)
+        }
+        result, err := combineShares([]string{"5", shares[4], "2", shares[1], "3", shares[2]}, field)
+        if err != nil || result != secret {
+            t.Errorf("%s: expecting %q, got: %q (%v)", field.Name(), secret, result, err)
+        }
+    }
+
+    // A 64 byte key is a single element of the 2^521 - 1 field.
+    field, _ := lookupField("p521")
+    if shares, _ := splitSecret(secret, 3, 2, field); strings.Contains(shares[0], "+") {
+        t.Errorf("Expecting a single subsecret, got: %s", shares[0])
+    }
+
+    // GF(2^8) has room for 255 shares, and its shares are bytes.
+    field, _ = lookupField("gf256")
+    if _, err := splitSecret(secret, 256, 2, field); !errors.Is(err, ErrInvalidParameters) {
+        t.Errorf("Expected 256 shares of GF(2^8) to be rejected, got: %v", err)
+    }
+    if _, err := combineShares([]string{"1", "256", "2", "3"}, field); !errors.Is(err, ErrMalformedShare) {
+        t.Errorf("Expected a share too big for GF(2^8) to be rejected, got: %v", err)
+    }
+}
+
+func TestShareField(t *testing.T) {
+    p127 := shareRecord{index: big.NewInt(1)}
+    p521 := shareRecord{index: big.NewInt(2), field: "p521"}
+    tests := []struct {
+        records  []shareRecord
+        name     string
+        expected string
+    }{
+        {nil, "", DEFAULT_FIELD},
+        {nil, "gf128", "gf128"},
+        {[]shareRecord{p127}, "", DEFAULT_FIELD},
+        {[]shareRecord{p521, p521}, "", "p521"},
+        {[]shareRecord{p521}, "p521", "p521"},
+    }
+    for _, test := range(tests) {
+        if field, err := shareField(test.records, test.name); err != nil || field.Name() != test.expected {
+            t.Errorf("Expecting the %s field for %v and %q, got: %v", test.expected, test.records, test.name, err)
+        }
+    }
+    for _, records := range([][]shareRecord{{p127, p521}, {p521, p127}}) {
+        if _, err := shareField(records, ""); !errors.Is(err, ErrMalformedShare) {
+            t.Errorf("Expected shares from different fields to be rejected, got: %v", err)
+        }
+    }
+    if _, err := shareField([]shareRecord{p521}, "gf256"); !errors.Is(err, ErrMalformedShare) {
+        t.Errorf("Expected a share from another field to be rejected, got: %v", err)
+    }
+}
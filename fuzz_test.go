@@ -0,0 +1,199 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "errors"
+    "math/big"
+    "math/bits"
+    mrand "math/rand"
+    "strings"
+    "testing"
+)
+
+// Property and fuzz tests of native split and combine. The fuzz targets run
+// their seed corpus as ordinary tests; go test -fuzz FuzzSplitCombine and
+// go test -fuzz FuzzParseShares search further.
+
+// Every error combine returns for bad input is one of the typed kinds.
+func isTypedError(err error) bool {
+    for _, kind := range([]error{ErrInvalidParameters, ErrMalformedShare, ErrDuplicateIndex, ErrThresholdNotMet, ErrUntrustedShare}) {
+        if errors.Is(err, kind) {
+            return true
+        }
+    }
+    return false
+}
+
+// Parsing and combining arbitrary arguments never panics, and any failure is
+// reported as a typed error.
+func FuzzParseShares(f *testing.F) {
+    f.Add("1 23+100 2 345+99")
+    f.Add("1 23+100 1 345+99")
+    f.Add("1 23+100 2 345")
+    f.Add("0 1 2 -3")
+    f.Add("1 170141183460469231731687303715884105727 2 5")
+    f.Add("shamir1:ab12:3:2:2:345+99:")
+    f.Add("shamir1:ab12:3:2:2:345+99::gf256 1 5")
+    f.Add("53010008dac4c0ab7365435000031103101daf6fc6e25672d4b12947ffdc17ac5d10")
+    f.Add("academic acid academic acid")
+    f.Fuzz(func(t *testing.T, s string) {
+        args := strings.Fields(s)
+        input, records, err := parseShareArgs(args)
+        if err != nil {
+            if !isTypedError(err) {
+                t.Fatalf("Expecting a typed error for %q, got: %v", s, err)
+            }
+            return
+        }
+        if _, err := combineNative(context.Background(), input, records, "", 1, nil); err != nil && !isTypedError(err) {
+            t.Fatalf("Expecting a typed error for %q, got: %v", s, err)
+        }
+    })
+}
+
+// The shares at the x-coordinates picked out by the bits of subset, adding
+// more until there are count of them.
+func pickShares(shares []string, subset uint64, count int) []string {
+    picked := []string{}
+    used := make([]bool, len(shares))
+    for i := 0; i < len(shares) && len(picked) < 2 * count; i++ {
+        if subset >> uint(i % 64) & 1 == 1 {
+            picked = append(picked, big.NewInt(int64(i + 1)).String(), shares[i])
+            used[i] = true
+        }
+    }
+    for i := 0; i < len(shares) && len(picked) < 2 * count; i++ {
+        if !used[i] {
+            picked = append(picked, big.NewInt(int64(i + 1)).String(), shares[i])
+        }
+    }
+    return picked
+}
+
+// Any t of the shares of any secret, in any field, give the secret back.
+func FuzzSplitCombine(f *testing.F) {
+    f.Add([]byte("Hello, World! This is my secret."), uint8(5), uint8(3), uint64(0x15), uint8(0))
+    f.Add([]byte("\x00\x00leading NULs"), uint8(3), uint8(2), uint64(0x6), uint8(0))
+    f.Add([]byte("a\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00b"), uint8(4), uint8(4), uint64(0), uint8(0))
+    f.Add([]byte("\x00"), uint8(2), uint8(2), uint64(0), uint8(4))
+    // Secrets whose last p127 subsecret starts with NUL.
+    f.Add([]byte("0123456789abcde\x00"), uint8(3), uint8(2), uint64(0x5), uint8(0))
+    f.Add([]byte("0123456789abcdef\x00"), uint8(3), uint8(2), uint64(0x3), uint8(0))
+    f.Add([]byte("0123456789abcde\x00\x00"), uint8(3), uint8(2), uint64(0x6), uint8(0))
+    f.Add([]byte(strings.Repeat("0123456789abcdef", 5)), uint8(9), uint8(2), uint64(0x180), uint8(1))
+    f.Add([]byte("x"), uint8(255), uint8(128), uint64(1 << 63 | 1), uint8(4))
+    f.Fuzz(func(t *testing.T, b []byte, n, threshold uint8, subset uint64, field_index uint8) {
+        for i := range(b) {
+            b[i] &= 0x7f
+        }
+        secret := string(b)
+        field := fields[int(field_index) % len(fields)]
+        xs := sequentialXCoordinates(int(n))
+        shares, err := splitSecretAt(context.Background(), secret, xs, int(threshold), field, 1, rand.Reader)
+        if err != nil {
+            if !errors.Is(err, ErrInvalidParameters) {
+                t.Fatalf("Expecting ErrInvalidParameters, got: %v", err)
+            }
+            return
+        }
+        recovered, err := combineShares(pickShares(shares, subset, int(threshold)), field)
+        if err != nil || recovered != secret {
+            t.Fatalf("%s: expecting %q back from %d of %d shares, got: %q, %v", field.Name(), secret, threshold, n, recovered, err)
+        }
+    })
+}
+
+// Secrets in which NUL bytes start subsecrets, the last one included, which
+// used to be dropped.
+func TestSplitCombineNULBytes(t *testing.T) {
+    for _, field := range(fields) {
+        chunk := field.ChunkSize()
+        for _, secret := range([]string{
+            "\x00" + strings.Repeat("a", chunk - 1) + "\x00\x00" + strings.Repeat("b", chunk) + "c",
+            strings.Repeat("a", chunk) + "\x00" + strings.Repeat("b", chunk - 1),
+            strings.Repeat("a", chunk) + "\x00",
+            strings.Repeat("\x00", 2 * chunk + 1),
+        }) {
+            shares, err := splitSecret(secret, 3, 2, field)
+            if err != nil {
+                t.Fatalf("%s: %v", field.Name(), err)
+            }
+            if recovered, err := combineShares([]string{"1", shares[0], "3", shares[2]}, field); err != nil || recovered != secret {
+                t.Errorf("%s: expecting %q back, got: %q, %v", field.Name(), secret, recovered, err)
+            }
+        }
+    }
+}
+
+func TestTrimLastChunk(t *testing.T) {
+    chunks, err := trimLastChunk([]string{"abc", "\x00\x00x", lengthSubsecret(2, 3)}, 3)
+    if err != nil || strings.Join(chunks, "") != "abc\x00x" {
+        t.Errorf("Expecting the last chunk cut to 2 bytes, got: %q, %v", chunks, err)
+    }
+    for _, bad := range([][]string{
+        {lengthSubsecret(2, 3)},
+        {"\x00\x00x", "\xfe\x04"},
+        {"\x00\x00x", "\xfe\x00"},
+        {"\x00yx", lengthSubsecret(1, 3)},
+        {"\x00\x00x", lengthSubsecret(1, 3)},
+        {"\x00\x00x", "\xfe\x02\x00"},
+    }) {
+        if _, err := trimLastChunk(bad, 3); !errors.Is(err, ErrMalformedShare) {
+            t.Errorf("Expecting ErrMalformedShare for %q, got: %v", bad, err)
+        }
+    }
+}
+
+// Every t-subset of the shares of random secrets reconstructs the secret.
+func TestAnyTSharesReconstruct(t *testing.T) {
+    r := mrand.New(mrand.NewSource(1))
+    for _, field := range(fields) {
+        for trial := 0; trial < 5; trial++ {
+            b := make([]byte, 1 + r.Intn(70))
+            for i := range(b) {
+                b[i] = byte(1 + r.Intn(127))
+            }
+            n := 2 + r.Intn(5)
+            threshold := 2 + r.Intn(n - 1)
+            shares, err := splitSecret(string(b), n, threshold, field)
+            if err != nil {
+                t.Fatalf("%s: %v", field.Name(), err)
+            }
+            for subset := uint64(0); subset < 1 << uint(n); subset++ {
+                if bits.OnesCount64(subset) != threshold {
+                    continue
+                }
+                recovered, err := combineShares(pickShares(shares, subset, threshold), field)
+                if err != nil || recovered != string(b) {
+                    t.Errorf("%s: expecting the secret back from shares %b of %d with t = %d, got: %q, %v", field.Name(), subset, n, threshold, recovered, err)
+                }
+            }
+        }
+    }
+}
+
+// Interpolating t - 1 shares at 0 gives a candidate for the secret that is
+// uniform over the field, whatever the secret: a chi-squared test over many
+// splits of the same secret in a small field.
+func TestSubThresholdCandidateUniform(t *testing.T) {
+    const TRIALS_PER_ELEMENT = 40
+    gf256, _ := lookupField("gf256")
+    for _, field := range([]Field{gf256, newPrimeField("251", big.NewInt(251))}) {
+        size := int(field.Size().Int64())
+        counts := make([]int, size)
+        secret := field.FromBig(big.NewInt(42))
+        xs := []*big.Int{big.NewInt(3), big.NewInt(7)}
+        weights := lagrangeWeights(xs, field)
+        for trial := 0; trial < TRIALS_PER_ELEMENT * size; trial++ {
+            ys, err := shamirSplitSecret(secret, field, xs, 3, rand.Reader)
+            if err != nil {
+                t.Fatal(err)
+            }
+            counts[field.ToBig(field.Dot(ys, weights)).Int64()]++
+        }
+        if chi2, limit := chiSquaredUniform(counts), chiSquaredLimit(size - 1); chi2 > limit {
+            t.Errorf("%s: candidates from 2 of 3 shares are not uniform, chi-squared %.0f > %.0f", field.Name(), chi2, limit)
+        }
+    }
+}
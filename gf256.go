@@ -0,0 +1,70 @@
+package main
+
+// Arithmetic in GF(2^8) with the Rijndael (AES) polynomial
+// x^8 + x^4 + x^3 + x + 1, as used by SLIP-39, SSKR and HashiCorp Vault.
+// Addition is XOR; multiplication and division go through log and exp tables
+// generated from the primitive element 3.
+
+const GF256_POLYNOMIAL = 0x11b
+
+var gf256Exp, gf256Log = func() ([255]byte, [256]byte) {
+    var exp [255]byte
+    var log [256]byte
+    p := 1
+    for i := 0; i < 255; i++ {
+        exp[i] = byte(p)
+        log[p] = byte(i)
+        // Multiply p by 3 = x + 1.
+        p ^= p << 1
+        if p & 0x100 != 0 {
+            p ^= GF256_POLYNOMIAL
+        }
+    }
+    return exp, log
+}()
+
+func gf256Mul(a, b byte) byte {
+    if a == 0 || b == 0 {
+        return 0
+    }
+    return gf256Exp[(int(gf256Log[a]) + int(gf256Log[b])) % 255]
+}
+
+// Divides a by b. b must not be zero.
+func gf256Div(a, b byte) byte {
+    if a == 0 {
+        return 0
+    }
+    return gf256Exp[(int(gf256Log[a]) - int(gf256Log[b]) + 255) % 255]
+}
+
+// Evaluates the polynomial with the given coefficients (constant term first)
+// at x using Horner's method.
+func gf256Evaluate(coefficients []byte, x byte) byte {
+    result := byte(0)
+    for i := len(coefficients) - 1; i >= 0; i-- {
+        result = gf256Mul(result, x) ^ coefficients[i]
+    }
+    return result
+}
+
+// Evaluates at x the polynomial through the points (xs[i], ys[i][k]) for
+// every byte position k, so ys holds one byte string per point. The xs must
+// be distinct.
+func gf256Interpolate(xs []byte, ys [][]byte, x byte) []byte {
+    result := make([]byte, len(ys[0]))
+    for i := range(xs) {
+        // The Lagrange basis polynomial for point i, evaluated at x.
+        basis := byte(1)
+        for j := range(xs) {
+            if i == j {
+                continue
+            }
+            basis = gf256Mul(basis, gf256Div(x ^ xs[j], xs[i] ^ xs[j]))
+        }
+        for k, y := range(ys[i]) {
+            result[k] ^= gf256Mul(y, basis)
+        }
+    }
+    return result
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+    "math/big"
+    "math/bits"
+)
+
+// Arithmetic mod the Mersenne prime 2^127 - 1 on two 64-bit limbs, for the
+// default field. Since 2^127 = 1 (mod p), a number is reduced by adding the
+// bits above bit 127 back onto the bits below it, with no division. Every
+// operation runs in the same time whatever the values, and none of them
+// allocate.
+
+// The low 63 bits: the top limb of 2^127 - 1.
+const FE127_HIGH_MASK = 1 << 63 - 1
+
+var mersenne127 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+
+// An element of the field, lo + hi * 2^64, always fully reduced: less than
+// 2^127 - 1.
+type fe127 struct {
+    lo uint64
+    hi uint64
+}
+
+// Reduces a value less than twice p by subtracting p if that does not go
+// below zero. The choice is made with a mask rather than a branch.
+func fe127Subtract(lo, hi uint64) fe127 {
+    d_lo, borrow := bits.Sub64(lo, ^uint64(0), 0)
+    d_hi, borrow := bits.Sub64(hi, FE127_HIGH_MASK, borrow)
+    // All ones if the subtraction did not borrow.
+    keep := borrow - 1
+    return fe127{(d_lo & keep) | (lo &^ keep), (d_hi & keep) | (hi &^ keep)}
+}
+
+// Reduces any 128-bit value: bit 127 is worth 1, so it is moved to bit 0.
+func fe127Reduce(lo, hi uint64) fe127 {
+    top := hi >> 63
+    lo, carry := bits.Add64(lo, top, 0)
+    return fe127Subtract(lo, (hi & FE127_HIGH_MASK) + carry)
+}
+
+func fe127Add(a, b fe127) fe127 {
+    lo, carry := bits.Add64(a.lo, b.lo, 0)
+    hi, _ := bits.Add64(a.hi, b.hi, carry)
+    return fe127Reduce(lo, hi)
+}
+
+// a - b is a + (p - b), which is less than 2p.
+func fe127Sub(a, b fe127) fe127 {
+    neg_lo, borrow := bits.Sub64(^uint64(0), b.lo, 0)
+    neg_hi, _ := bits.Sub64(FE127_HIGH_MASK, b.hi, borrow)
+    return fe127Add(a, fe127{neg_lo, neg_hi})
+}
+
+// Multiplies into four limbs p0..p3, then adds the top 127 bits of the
+// product onto the bottom 127 bits.
+func fe127Mul(a, b fe127) fe127 {
+    h00, p0 := bits.Mul64(a.lo, b.lo)
+    h01, l01 := bits.Mul64(a.lo, b.hi)
+    h10, l10 := bits.Mul64(a.hi, b.lo)
+    h11, l11 := bits.Mul64(a.hi, b.hi)
+
+    p1, c1 := bits.Add64(h00, l01, 0)
+    p1, c2 := bits.Add64(p1, l10, 0)
+    p2, c3 := bits.Add64(h01, h10, c1)
+    p2, c4 := bits.Add64(p2, l11, c2)
+    // Both factors are below 2^127, so the product is below 2^254 and this
+    // cannot overflow.
+    p3 := h11 + c3 + c4
+
+    // The product is low + high * 2^127 = low + high (mod p), and both halves
+    // are below 2^127.
+    high_lo := p1 >> 63 | p2 << 1
+    high_hi := p2 >> 63 | p3 << 1
+    lo, carry := bits.Add64(p0, high_lo, 0)
+    hi := (p1 & FE127_HIGH_MASK) + high_hi + carry
+    return fe127Reduce(lo, hi)
+}
+
+// The fe127 arithmetic as limbArithmetic, with limbs lo and hi.
+type mersenne127Arithmetic struct{}
+
+func (mersenne127Arithmetic) limbs() int {
+    return 2
+}
+
+// Elements are held as they are.
+func (mersenne127Arithmetic) fromLimbs(z, a []uint64) {
+    z[0], z[1] = a[0], a[1]
+}
+
+func (mersenne127Arithmetic) toLimbs(z, a []uint64) {
+    z[0], z[1] = a[0], a[1]
+}
+
+func (mersenne127Arithmetic) setOne(z []uint64) {
+    z[0], z[1] = 1, 0
+}
+
+func (mersenne127Arithmetic) add(z, a, b []uint64) {
+    r := fe127Add(fe127{a[0], a[1]}, fe127{b[0], b[1]})
+    z[0], z[1] = r.lo, r.hi
+}
+
+func (mersenne127Arithmetic) sub(z, a, b []uint64) {
+    r := fe127Sub(fe127{a[0], a[1]}, fe127{b[0], b[1]})
+    z[0], z[1] = r.lo, r.hi
+}
+
+func (mersenne127Arithmetic) mul(z, a, b []uint64) {
+    r := fe127Mul(fe127{a[0], a[1]}, fe127{b[0], b[1]})
+    z[0], z[1] = r.lo, r.hi
+}
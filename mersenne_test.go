@@ -0,0 +1,149 @@
+package main
+
+import (
+    "crypto/rand"
+    "encoding/binary"
+    "math/big"
+    "testing"
+)
+
+func fe127FromBig(x *big.Int) fe127 {
+    var b [16]byte
+    new(big.Int).Mod(x, mersenne127).FillBytes(b[:])
+    return fe127{binary.BigEndian.Uint64(b[8:]), binary.BigEndian.Uint64(b[:8])}
+}
+
+func (a fe127) big() *big.Int {
+    var b [16]byte
+    binary.BigEndian.PutUint64(b[:8], a.hi)
+    binary.BigEndian.PutUint64(b[8:], a.lo)
+    return new(big.Int).SetBytes(b[:])
+}
+
+// Values next to the limb boundaries and the modulus, and some random ones.
+func fe127TestValues() []*big.Int {
+    values := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2)}
+    for _, shift := range([]uint{63, 64, 126}) {
+        power := new(big.Int).Lsh(big.NewInt(1), shift)
+        values = append(values, power, new(big.Int).Sub(power, big.NewInt(1)), new(big.Int).Add(power, big.NewInt(1)))
+    }
+    values = append(values, new(big.Int).Sub(mersenne127, big.NewInt(1)), new(big.Int).Sub(mersenne127, big.NewInt(2)))
+    for i := 0; i < 20; i++ {
+        x, _ := rand.Int(rand.Reader, mersenne127)
+        values = append(values, x)
+    }
+    return values
+}
+
+func TestFe127Arithmetic(t *testing.T) {
+    p127, _ := lookupField(DEFAULT_FIELD)
+    values := fe127TestValues()
+    for _, x := range(values) {
+        a := fe127FromBig(x)
+        if a.big().Cmp(x) != 0 {
+            t.Errorf("Expecting %s back, got: %s", x, a.big())
+        }
+        for _, y := range(values) {
+            b := fe127FromBig(y)
+            sum := new(big.Int).Add(x, y)
+            difference := new(big.Int).Sub(x, y)
+            product := new(big.Int).Mul(x, y)
+            for name, expected := range(map[string]*big.Int{"sum": sum, "difference": difference, "product": product}) {
+                expected.Mod(expected, mersenne127)
+                result := map[string]fe127{"sum": fe127Add(a, b), "difference": fe127Sub(a, b), "product": fe127Mul(a, b)}[name]
+                if result.big().Cmp(expected) != 0 {
+                    t.Errorf("Expecting %s as the %s of %s and %s, got: %s", expected, name, x, y, result.big())
+                }
+            }
+        }
+        if x.Sign() != 0 {
+            inverse := p127.ToBig(p127.Inv(p127.FromBig(x)))
+            if expected := new(big.Int).ModInverse(x, mersenne127); inverse.Cmp(expected) != 0 {
+                t.Errorf("Expecting %s as the inverse of %s, got: %s", expected, x, inverse)
+            }
+        }
+    }
+
+    // The largest 128-bit value is 2p + 1.
+    if r := fe127Reduce(^uint64(0), ^uint64(0)); r != (fe127{1, 0}) {
+        t.Errorf("Expecting 1, got: %s", r.big())
+    }
+    // p itself reduces to 0.
+    if r := fe127Reduce(^uint64(0), FE127_HIGH_MASK); r != (fe127{}) {
+        t.Errorf("Expecting 0, got: %s", r.big())
+    }
+}
+
+// The field operations on the hot paths, with math/big and with fe127.
+func BenchmarkMul127(b *testing.B) {
+    x, _ := rand.Int(rand.Reader, mersenne127)
+    y, _ := rand.Int(rand.Reader, mersenne127)
+    b.Run("big", func(b *testing.B) {
+        b.ReportAllocs()
+        z := new(big.Int)
+        for i := 0; i < b.N; i++ {
+            z.Mul(x, y)
+            z.Mod(z, mersenne127)
+        }
+    })
+    b.Run("fe127", func(b *testing.B) {
+        b.ReportAllocs()
+        a, c := fe127FromBig(x), fe127FromBig(y)
+        for i := 0; i < b.N; i++ {
+            a = fe127Mul(a, c)
+        }
+    })
+}
+
+// Horner's method with math/big, as evaluatePolynomial did before the
+// arithmetic was done on limbs.
+func evaluateBig(coefficients []*big.Int, x, modulus *big.Int) *big.Int {
+    result := new(big.Int).Set(coefficients[len(coefficients)-1])
+    for i := len(coefficients) - 2; i >= 0; i-- {
+        result.Mul(result, x)
+        result.Add(result, coefficients[i])
+    }
+    return result.Mod(result, modulus)
+}
+
+func BenchmarkEvaluatePolynomial127(b *testing.B) {
+    p127, _ := lookupField(DEFAULT_FIELD)
+    poly, _ := generateRandomPolynomial(p127.FromBig(big.NewInt(1234)), p127, 9, rand.Reader)
+    b.Run("big", func(b *testing.B) {
+        b.ReportAllocs()
+        coefficients := make([]*big.Int, len(poly.coefficients))
+        for i, c := range(poly.coefficients) {
+            coefficients[i] = p127.ToBig(c)
+        }
+        for i := 0; i < b.N; i++ {
+            evaluateBig(coefficients, big.NewInt(17), mersenne127)
+        }
+    })
+    b.Run("fe127", func(b *testing.B) {
+        b.ReportAllocs()
+        z := make([]uint64, 2)
+        for i := 0; i < b.N; i++ {
+            hornerLimbs(mersenne127Arithmetic{}, z, poly.coefficients, []uint64{17, 0})
+        }
+    })
+}
+
+func BenchmarkInverse127(b *testing.B) {
+    x, _ := rand.Int(rand.Reader, mersenne127)
+    b.Run("big", func(b *testing.B) {
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+            new(big.Int).ModInverse(x, mersenne127)
+        }
+    })
+    b.Run("fe127", func(b *testing.B) {
+        b.ReportAllocs()
+        arith := mersenne127Arithmetic{}
+        a, z := make([]uint64, 2), make([]uint64, 2)
+        bigToLimbs(a, x)
+        e := new(big.Int).Sub(mersenne127, big.NewInt(2))
+        for i := 0; i < b.N; i++ {
+            powLimbs(arith, z, a, e)
+        }
+    })
+}
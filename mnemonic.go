@@ -0,0 +1,305 @@
+package main
+
+import (
+    "bytes"
+    "crypto/sha256"
+    _ "embed"
+    "encoding/binary"
+    "fmt"
+    "strings"
+    "unicode"
+)
+
+//go:embed wordlists/bip39_english.txt
+var bip39EnglishText string
+
+// The BIP-39 English word list. Each word stands for 11 bits, and no two
+// words share their first four letters.
+var bip39Words = strings.Fields(bip39EnglishText)
+
+// Maps every word, and the first four letters of every word, to its index.
+var bip39Index = func() map[string]int {
+    index := make(map[string]int)
+    for i, word := range(bip39Words) {
+        index[word] = i
+        if len(word) > 4 {
+            index[word[:4]] = i
+        }
+    }
+    return index
+}()
+
+// Number of words holding the checksum at the end of every mnemonic share.
+const MNEMONIC_CHECKSUM_WORDS = 2
+
+// Writes data as words from the BIP-39 list. The data is prefixed with its
+// length as a uvarint and zero padded to a whole number of words, then two
+// checksum words are added so typos can be caught before the share is used.
+func encodeWords(data []byte) string {
+    framed := make([]byte, binary.MaxVarintLen64)
+    framed = append(framed[:binary.PutUvarint(framed, uint64(len(data)))], data...)
+    indices := bytesToWordIndices(framed)
+    indices = append(indices, wordsChecksum(indices)...)
+
+    words := make([]string, len(indices))
+    for i, index := range(indices) {
+        words[i] = bip39Words[index]
+    }
+    return strings.Join(words, " ")
+}
+
+// Packs data into 11 bit word indices, most significant bit first.
+func bytesToWordIndices(data []byte) []int {
+    indices := []int{}
+    acc, bits := 0, 0
+    for _, b := range(data) {
+        acc = acc << 8 | int(b)
+        bits += 8
+        for bits >= 11 {
+            bits -= 11
+            indices = append(indices, (acc >> bits) & 0x7ff)
+        }
+        acc &= (1 << bits) - 1
+    }
+    if bits > 0 {
+        indices = append(indices, (acc << (11 - bits)) & 0x7ff)
+    }
+    return indices
+}
+
+// The inverse of bytesToWordIndices. Any bits left over at the end that do
+// not make up a whole byte are dropped.
+func wordIndicesToBytes(indices []int) []byte {
+    data := []byte{}
+    acc, bits := 0, 0
+    for _, index := range(indices) {
+        acc = acc << 11 | index
+        bits += 11
+        for bits >= 8 {
+            bits -= 8
+            data = append(data, byte(acc >> bits))
+        }
+        acc &= (1 << bits) - 1
+    }
+    return data
+}
+
+// The checksum words for a list of data word indices: the first 22 bits of
+// the SHA-256 of the indices, as two more words.
+func wordsChecksum(indices []int) []int {
+    buf := make([]byte, 2 * len(indices))
+    for i, index := range(indices) {
+        binary.BigEndian.PutUint16(buf[2*i:], uint16(index))
+    }
+    h := sha256.Sum256(buf)
+    v := int(h[0]) << 14 | int(h[1]) << 6 | int(h[2]) >> 2
+    return []int{v >> 11, v & 0x7ff}
+}
+
+// Checks the checksum words and length prefix and returns the data.
+func wordIndicesToData(indices []int) ([]byte, bool) {
+    if len(indices) <= MNEMONIC_CHECKSUM_WORDS {
+        return nil, false
+    }
+    body := indices[:len(indices)-MNEMONIC_CHECKSUM_WORDS]
+    sum := indices[len(indices)-MNEMONIC_CHECKSUM_WORDS:]
+    expected := wordsChecksum(body)
+    if sum[0] != expected[0] || sum[1] != expected[1] {
+        return nil, false
+    }
+    framed := wordIndicesToBytes(body)
+    length, n := binary.Uvarint(framed)
+    if n <= 0 || length > uint64(len(framed) - n) {
+        return nil, false
+    }
+    data, padding := framed[n:n+int(length)], framed[n+int(length):]
+    if len(bytes.Trim(padding, "\x00")) != 0 {
+        return nil, false
+    }
+    return data, true
+}
+
+// Splits a mnemonic into words, allowing spaces, dashes or commas between them.
+func splitWords(s string) []string {
+    return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+        return unicode.IsSpace(r) || r == '-' || r == ','
+    })
+}
+
+// Whether s reads as words rather than as one of the other share encodings.
+func looksLikeWords(s string) bool {
+    words := splitWords(s)
+    if len(words) <= MNEMONIC_CHECKSUM_WORDS {
+        return false
+    }
+    known := 0
+    for _, word := range(words) {
+        for _, r := range(word) {
+            if !unicode.IsLetter(r) {
+                return false
+            }
+        }
+        if _, ok := bip39Index[word]; ok {
+            known++
+        }
+    }
+    return 2 * known >= len(words)
+}
+
+// Reads words written by encodeWords. On failure, tries to point at the word
+// that is wrong: unknown words are matched against similar words in the list,
+// and if the checksum fails every single word substitution that would fix it
+// (and give a valid share record) is suggested.
+func decodeWords(s string) ([]byte, error) {
+    words := splitWords(s)
+    indices := make([]int, len(words))
+    unknown := -1
+    for i, word := range(words) {
+        index, ok := bip39Index[word]
+        if !ok {
+            if unknown >= 0 {
+                return nil, newError(ErrMalformedShare, fmt.Sprintf("Words %d (%q) and %d (%q) are not in the word list.", unknown+1, words[unknown], i+1, word))
+            }
+            unknown = i
+            index = -1
+        }
+        indices[i] = index
+    }
+
+    if unknown >= 0 {
+        msg := fmt.Sprintf("Word %d (%q) is not in the word list.", unknown+1, words[unknown])
+        return nil, newError(ErrMalformedShare, msg + suggestWords(indices, unknown, similarWords(words[unknown])))
+    }
+    if data, ok := wordIndicesToData(indices); ok {
+        return data, nil
+    }
+
+    // Try replacing each word in turn with every other word in the list.
+    fixes := []string{}
+    all := make([]int, len(bip39Words))
+    for i := range(all) {
+        all[i] = i
+    }
+    for i, word := range(words) {
+        for _, candidate := range(validReplacements(indices, i, all)) {
+            fixes = append(fixes, fmt.Sprintf("word %d %q -> %q", i+1, word, bip39Words[candidate]))
+        }
+    }
+    msg := "Share words checksum does not match, a word is wrong or missing."
+    if len(fixes) > 0 {
+        msg += " Possible corrections: " + strings.Join(fixes, ", ") + "."
+    }
+    return nil, newError(ErrMalformedShare, msg)
+}
+
+// Candidates from the list that, put at position i, make indices a valid
+// share record.
+func validReplacements(indices []int, i int, candidates []int) []int {
+    res := []int{}
+    trial := append([]int{}, indices...)
+    for _, candidate := range(candidates) {
+        if candidate == indices[i] {
+            continue
+        }
+        trial[i] = candidate
+        if data, ok := wordIndicesToData(trial); ok {
+            if _, err := unmarshalShareRecord(data); err == nil {
+                res = append(res, candidate)
+            }
+        }
+    }
+    return res
+}
+
+// Formats suggestions for the unknown word at position i, preferring the
+// similar words that make the share valid.
+func suggestWords(indices []int, i int, similar []int) string {
+    valid := validReplacements(indices, i, similar)
+    if len(valid) == 0 {
+        valid = similar
+    }
+    if len(valid) == 0 {
+        return ""
+    }
+    names := []string{}
+    for _, index := range(valid) {
+        names = append(names, fmt.Sprintf("%q", bip39Words[index]))
+    }
+    return " Did you mean " + strings.Join(names, " or ") + "?"
+}
+
+// The words in the list closest to word, within an edit distance of two.
+func similarWords(word string) []int {
+    best := 3
+    res := []int{}
+    for i, candidate := range(bip39Words) {
+        d := editDistance(word, candidate)
+        if d < best {
+            best = d
+            res = []int{}
+        }
+        if d == best {
+            res = append(res, i)
+        }
+    }
+    return res
+}
+
+// Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+    prev := make([]int, len(b)+1)
+    cur := make([]int, len(b)+1)
+    for j := range(prev) {
+        prev[j] = j
+    }
+    for i := 1; i <= len(a); i++ {
+        cur[0] = i
+        for j := 1; j <= len(b); j++ {
+            cost := 1
+            if a[i-1] == b[j-1] {
+                cost = 0
+            }
+            cur[j] = min3(prev[j] + 1, cur[j-1] + 1, prev[j-1] + cost)
+        }
+        prev, cur = cur, prev
+    }
+    return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+    if b < a {
+        a = b
+    }
+    if c < a {
+        a = c
+    }
+    return a
+}
+
+// Works out how many of the leading arguments make up one mnemonic share
+// when its words are passed to combine without quotes. The count is read
+// from the length prefix in the first few words. Returns 0 if args does not
+// start with a word from the list.
+func mnemonicArgCount(args []string) int {
+    indices := []int{}
+    for _, arg := range(args) {
+        index, ok := bip39Index[strings.ToLower(arg)]
+        if !ok || len(indices) == 3 {
+            break
+        }
+        indices = append(indices, index)
+    }
+    if len(indices) == 0 {
+        return 0
+    }
+    framed := wordIndicesToBytes(indices)
+    length, n := binary.Uvarint(framed)
+    if n <= 0 {
+        return len(args)
+    }
+    count := (8 * (n + int(length)) + 10) / 11 + MNEMONIC_CHECKSUM_WORDS
+    if count > len(args) {
+        return len(args)
+    }
+    return count
+}
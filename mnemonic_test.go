@@ -0,0 +1,91 @@
+package main
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "math/big"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestBip39WordList(t *testing.T) {
+    // SHA-256 of english.txt from the BIP-39 specification.
+    sum := sha256.Sum256([]byte(bip39EnglishText))
+    expected := "2f5eed53a4727b4bf8880d8f3f199efc90e58503646d9ff8eff3a2ed3b24dbda"
+    if hex.EncodeToString(sum[:]) != expected {
+        t.Errorf("Word list does not match the BIP-39 English list")
+    }
+    if len(bip39Words) != 2048 {
+        t.Errorf("Expected 2048 words, got %d", len(bip39Words))
+    }
+}
+
+func TestWordIndicesPacking(t *testing.T) {
+    for length := 0; length < 40; length++ {
+        data := bytes.Repeat([]byte{0xa5, 0x3c, 0xff}, length)[:length]
+        result := wordIndicesToBytes(bytesToWordIndices(data))
+        if !bytes.HasPrefix(result, data) || len(result) - len(data) > 1 {
+            t.Errorf("Expecting %x, got: %x", data, result)
+        }
+    }
+}
+
+func TestWordsShareRecord(t *testing.T) {
+    record := shareRecord{index: big.NewInt(2), splitID: "0badc0ffee", n: 3, t: 2, payload: "345+99+50"}
+    enc, _ := lookupShareEncoding("words")
+    token, err := encodeShareRecord(record, enc)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Words can be given with dashes, in upper case or by their first four letters.
+    words := strings.Fields(token)
+    for i, word := range(words) {
+        if len(word) > 4 && i % 2 == 0 {
+            words[i] = strings.ToUpper(word[:4])
+        }
+    }
+    result, name, err := decodeShareRecord(strings.Join(words, "-"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if name != "words" || !reflect.DeepEqual(record, result) {
+        t.Errorf("Expecting %v, got: %v (%s)", record, result, name)
+    }
+
+    // Unquoted words are grouped into shares by their length prefix.
+    args := append(strings.Fields(token), "1", "23+100+19")
+    pairs, _, err := parseShareArgs(args)
+    if err != nil {
+        t.Fatal(err)
+    }
+    expected := []string{"2", "345+99+50", "1", "23+100+19"}
+    if !reflect.DeepEqual(expected, pairs) {
+        t.Errorf("Expecting %s, got: %s", expected, pairs)
+    }
+}
+
+func TestWordsTypoSuggestions(t *testing.T) {
+    record := shareRecord{index: big.NewInt(1), splitID: "ab12", n: 3, t: 2, payload: "23+100+19"}
+    enc, _ := lookupShareEncoding("words")
+    token, _ := encodeShareRecord(record, enc)
+    words := strings.Fields(token)
+
+    // A misspelt word is matched to the closest word in the list.
+    misspelt := append([]string{}, words...)
+    misspelt[3] = misspelt[3][:len(misspelt[3])-1] + "q"
+    _, _, err := decodeShareRecord(strings.Join(misspelt, " "))
+    if err == nil || !strings.Contains(err.Error(), `"` + words[3] + `"`) {
+        t.Errorf("Expected suggestion %q, got: %v", words[3], err)
+    }
+
+    // A valid but wrong word is found through the checksum.
+    swapped := append([]string{}, words...)
+    swapped[5] = bip39Words[(bip39Index[words[5]] + 1) % 2048]
+    _, _, err = decodeShareRecord(strings.Join(swapped, " "))
+    if err == nil || !strings.Contains(err.Error(), `-> "` + words[5] + `"`) {
+        t.Errorf("Expected correction to %q, got: %v", words[5], err)
+    }
+}
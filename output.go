@@ -0,0 +1,195 @@
+package main
+
+import (
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "os"
+)
+
+// Exit codes returned by every subcommand. These are part of the documented
+// interface (see README.md), so scripts can rely on them.
+const (
+    EXIT_FAILURE        = 1 // Unexpected internal error.
+    EXIT_USAGE          = 2 // Bad command line arguments, as for the flag package.
+    EXIT_INVALID_SHARES = 3 // Shares are malformed or inconsistent.
+    EXIT_REJECTED       = 4 // A dealer signature or the audit log failed verification.
+    EXIT_IO             = 5 // A key or audit log file could not be read or written.
+)
+
+// The machine readable error code reported in JSON error objects for each
+// exit code.
+var errorCodes = map[int]string{
+    EXIT_FAILURE:        "internal_error",
+    EXIT_USAGE:          "invalid_arguments",
+    EXIT_INVALID_SHARES: "invalid_shares",
+    EXIT_REJECTED:       "verification_failed",
+    EXIT_IO:             "io_error",
+}
+
+// Version of the JSON output schema. Only bumped on incompatible changes;
+// new fields may be added without bumping it.
+const JSON_SCHEMA_VERSION = 1
+
+// Where results and errors of a subcommand are written, either as the
+// human readable text this tool has always printed or as JSON objects.
+type output struct {
+    json bool
+    // Where split writes the shares' QR codes and printable sheets, if
+    // anywhere, and the holder names for the sheets.
+    qrDir    string
+    printDir string
+    holders  []string
+    // The audit log, and the entry recorded in it as failed if the
+    // subcommand exits with an error before it has recorded the operation
+    // itself.
+    audit      *auditLog
+    auditEntry *auditRecord
+}
+
+func newOutput(format string) *output {
+    switch format {
+        case "text":
+            return &output{}
+        case "json":
+            return &output{json: true}
+    }
+    (&output{}).fail(EXIT_USAGE, "Format must be 'text' or 'json'.")
+    return nil
+}
+
+type jsonError struct {
+    Code     string `json:"code"`
+    Message  string `json:"message"`
+    ExitCode int    `json:"exit_code"`
+}
+
+type jsonShare struct {
+    Index   int      `json:"index"`
+    X       *big.Int `json:"x,omitempty"`
+    Group   int      `json:"group,omitempty"`
+    Payload string   `json:"payload"`
+    Record  string   `json:"record,omitempty"`
+}
+
+type jsonGroup struct {
+    Index int `json:"index"`
+    N     int `json:"n"`
+    T     int `json:"t"`
+}
+
+// For schemes with groups, N and T are the number of groups and the group
+// threshold, and each share carries its group number. Field is only set for
+// native splits.
+type jsonSplit struct {
+    Version  int         `json:"version"`
+    Scheme   string      `json:"scheme,omitempty"`
+    SplitID  string      `json:"split_id"`
+    Field    string      `json:"field,omitempty"`
+    // Set for splits made in -seed mode, whose shares are not secret.
+    Insecure bool        `json:"insecure,omitempty"`
+    N        int         `json:"n"`
+    T        int         `json:"t"`
+    Signed   bool        `json:"signed"`
+    Encoding string      `json:"encoding"`
+    Groups   []jsonGroup `json:"groups,omitempty"`
+    Shares   []jsonShare `json:"shares"`
+}
+
+type jsonCombine struct {
+    Version  int        `json:"version"`
+    SplitID  string     `json:"split_id,omitempty"`
+    Indices  []*big.Int `json:"indices"`
+    Encoding string     `json:"encoding"`
+    Secret   string     `json:"secret"`
+}
+
+type jsonVerifiedShare struct {
+    Index   *big.Int `json:"index,omitempty"`
+    SplitID string   `json:"split_id,omitempty"`
+    N       int      `json:"n,omitempty"`
+    T       int      `json:"t,omitempty"`
+    Valid   bool     `json:"valid"`
+    Error   string   `json:"error,omitempty"`
+}
+
+type jsonVerify struct {
+    Version int                 `json:"version"`
+    Valid   bool                `json:"valid"`
+    Shares  []jsonVerifiedShare `json:"shares"`
+}
+
+type jsonAuditVerify struct {
+    Version int  `json:"version"`
+    Valid   bool `json:"valid"`
+    Entries int  `json:"entries"`
+}
+
+// From here on, records entry in the audit log as failed if the subcommand
+// exits with an error, so that no failed attempt goes unrecorded. The entry is
+// read when the subcommand fails, so it has whatever has been filled in by
+// then.
+func (o *output) auditFailures(audit *auditLog, entry *auditRecord) {
+    o.audit, o.auditEntry = audit, entry
+}
+
+// Writes v as indented JSON to stdout.
+func (o *output) emit(v interface{}) {
+    enc := json.NewEncoder(os.Stdout)
+    enc.SetIndent("", "  ")
+    enc.Encode(v)
+}
+
+// Reports an error and exits with exit_code. In text mode, argument and share
+// errors are followed by a pointer to the usage examples. In JSON mode the
+// error is written to stdout as {"version": 1, "error": {...}} so that scripts
+// only need to read one stream.
+func (o *output) fail(exit_code int, message string) {
+    o.failAudited(exit_code, message, auditExitReason(exit_code))
+}
+
+// Reports err with the exit code matching its kind.
+func (o *output) failWith(err error) {
+    o.failAudited(exitCode(err), err.Error(), auditErrorMessage(err))
+}
+
+// Does the work of fail, first recording the operation in the audit log as
+// failed for reason, if there is one to record.
+func (o *output) failAudited(exit_code int, message, reason string) {
+    if o != nil && o.auditEntry != nil {
+        entry := *o.auditEntry
+        o.auditEntry = nil
+        entry.Success, entry.Error = false, reason
+        if err := o.audit.record(entry); err != nil {
+            message += "\nFailed to write audit log: " + err.Error()
+        }
+    }
+    if o != nil && o.json {
+        o.emit(struct {
+            Version int       `json:"version"`
+            Error   jsonError `json:"error"`
+        }{JSON_SCHEMA_VERSION, jsonError{errorCodes[exit_code], message, exit_code}})
+    } else {
+        if exit_code == EXIT_USAGE || exit_code == EXIT_INVALID_SHARES {
+            message += "\nSee README.md for example usage."
+        }
+        fmt.Println(message)
+    }
+    os.Exit(exit_code)
+}
+
+// Encodes a recovered secret for output. Text returns it unchanged, which is
+// only safe for secrets that were text to begin with.
+func encodeSecret(secret, encoding string) (string, error) {
+    switch encoding {
+        case "text":
+            return secret, nil
+        case "hex":
+            return hex.EncodeToString([]byte(secret)), nil
+        case "base64":
+            return base64.StdEncoding.EncodeToString([]byte(secret)), nil
+    }
+    return "", newError(ErrInvalidParameters, fmt.Sprintf("Unknown secret encoding %q, expected 'text', 'hex' or 'base64'.", encoding))
+}
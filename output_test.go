@@ -0,0 +1,75 @@
+package main
+
+import (
+    "encoding/json"
+    "math/big"
+    "testing"
+)
+
+// Flag errors are reported in the -format given anywhere on the command line,
+// even after the bad flag.
+func TestFormatArg(t *testing.T) {
+    tests := map[string][]string{
+        "json": {"-bogus", "-format", "json"},
+        "text": {"-format=json", "--format=text", "-n", "3"},
+        "":     {"-n", "3", "format", "json"},
+    }
+    for expected, args := range(tests) {
+        if result := formatArg(args); result != expected {
+            t.Errorf("Expecting %q for %v, got: %q", expected, args, result)
+        }
+    }
+    if result := formatArg([]string{"--format", "json", "-x"}); result != "json" {
+        t.Errorf("Expecting json for --format, got: %q", result)
+    }
+}
+
+func TestEncodeSecret(t *testing.T) {
+    tests := map[string]string{
+        "text":   "Hi!",
+        "hex":    "486921",
+        "base64": "SGkh",
+    }
+    for encoding, expected := range(tests) {
+        result, err := encodeSecret("Hi!", encoding)
+        if err != nil || result != expected {
+            t.Errorf("Expecting %s for %s, got: %s (%v)", expected, encoding, result, err)
+        }
+    }
+    if _, err := encodeSecret("Hi!", "rot13"); err == nil {
+        t.Errorf("Expected unknown encoding to be rejected")
+    }
+}
+
+// The JSON field names are a documented interface. This catches accidental
+// renames.
+func TestJSONSplitSchema(t *testing.T) {
+    res := jsonSplit{Version: JSON_SCHEMA_VERSION, SplitID: "ab12", Field: "p127", N: 3, T: 2, Encoding: "decimal", Shares: []jsonShare{{Index: 1, Payload: "23+100"}}}
+    data, _ := json.Marshal(res)
+    expected := `{"version":1,"split_id":"ab12","field":"p127","n":3,"t":2,"signed":false,"encoding":"decimal","shares":[{"index":1,"payload":"23+100"}]}`
+    if string(data) != expected {
+        t.Errorf("Expecting %s, got: %s", expected, data)
+    }
+
+    res = jsonSplit{JSON_SCHEMA_VERSION, "slip39", "1a2b", "", false, 2, 1, false, "mnemonic", []jsonGroup{{1, 3, 2}}, []jsonShare{{Index: 2, Group: 1, Payload: "academic acid"}}}
+    data, _ = json.Marshal(res)
+    expected = `{"version":1,"scheme":"slip39","split_id":"1a2b","n":2,"t":1,"signed":false,"encoding":"mnemonic","groups":[{"index":1,"n":3,"t":2}],"shares":[{"index":2,"group":1,"payload":"academic acid"}]}`
+    if string(data) != expected {
+        t.Errorf("Expecting %s, got: %s", expected, data)
+    }
+
+    // Shares at chosen x-coordinates give them as numbers of any size.
+    x, _ := new(big.Int).SetString("98765432109876543210", 10)
+    data, _ = json.Marshal(jsonShare{Index: 1, X: x, Payload: "23+100"})
+    expected = `{"index":1,"x":98765432109876543210,"payload":"23+100"}`
+    if string(data) != expected {
+        t.Errorf("Expecting %s, got: %s", expected, data)
+    }
+
+    combined := jsonCombine{JSON_SCHEMA_VERSION, "", []*big.Int{big.NewInt(1), big.NewInt(3)}, "hex", "486921"}
+    data, _ = json.Marshal(combined)
+    expected = `{"version":1,"indices":[1,3],"encoding":"hex","secret":"486921"}`
+    if string(data) != expected {
+        t.Errorf("Expecting %s, got: %s", expected, data)
+    }
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// Padding for native secrets, so that the length of a share does not give
+// away the length of the secret. A padded secret is:
+// marker (1 byte) | secret length (decimal) | ':' | secret | fill bytes
+// | first 8 bytes of the SHA-256 of everything before (16 hex digits)
+// Native secrets are ASCII, so the marker can never start an unpadded one,
+// and no byte of a padded secret is zero, so none are lost when a chunk is
+// turned into a number. The checksum is an integrity check, not
+// authentication: it has no key, so it catches wrong or corrupted shares
+// recovering a garbled secret, which is rejected rather than cut short, but
+// anyone who can choose the recovered secret can recompute it. The length is
+// only authenticated in signed splits, where the dealer signature covers all
+// of every share.
+
+const PAD_MARKER = 0xff
+const PAD_FILL = 0x80
+const PAD_CHECKSUM_LENGTH = 16
+
+// The smallest padded length of -pad bucket, and the largest padded length.
+const PAD_MIN_BUCKET = 32
+const PAD_MAX_LENGTH = 1 << 16
+
+// The bytes padding adds to a secret of length n, besides the fill.
+func padOverhead(n int) int {
+    return 1 + len(strconv.Itoa(n)) + 1 + PAD_CHECKSUM_LENGTH
+}
+
+// The length a secret of length n is padded to. mode is either a length in
+// bytes or "bucket" for the next power of two, so that secrets of similar
+// lengths give shares of the same length.
+func paddedLength(n int, mode string) (int, error) {
+    needed := n + padOverhead(n)
+    if mode == "bucket" {
+        length := PAD_MIN_BUCKET
+        for length < needed {
+            length *= 2
+        }
+        if length > PAD_MAX_LENGTH {
+            return 0, newError(ErrInvalidParameters, fmt.Sprintf("Secret too long to pad, padded secrets are at most %d bytes.", PAD_MAX_LENGTH))
+        }
+        return length, nil
+    }
+    length, err := strconv.Atoi(mode)
+    if err != nil || length <= 0 {
+        return 0, newError(ErrInvalidParameters, fmt.Sprintf("Bad padding %q, expected a length in bytes or 'bucket'.", mode))
+    }
+    if length > PAD_MAX_LENGTH {
+        return 0, newError(ErrInvalidParameters, fmt.Sprintf("Padded secrets are at most %d bytes.", PAD_MAX_LENGTH))
+    }
+    if length < needed {
+        return 0, newError(ErrInvalidParameters, fmt.Sprintf("Secret too long to pad to %d bytes, it needs at least %d.", length, needed))
+    }
+    return length, nil
+}
+
+// The checksum closing a padded secret.
+func padChecksum(s string) string {
+    sum := sha256.Sum256([]byte(s))
+    return hex.EncodeToString(sum[:PAD_CHECKSUM_LENGTH/2])
+}
+
+// Pads secret as described above, to the length mode asks for.
+// E.g. "Hi" padded to 24 bytes is "\xff2:Hi\x80\x80\x80" followed by 16 hex
+// digits.
+func padSecret(secret, mode string) (string, error) {
+    length, err := paddedLength(len(secret), mode)
+    if err != nil {
+        return "", err
+    }
+    header := string([]byte{PAD_MARKER}) + strconv.Itoa(len(secret)) + ":"
+    fill := length - len(header) - len(secret) - PAD_CHECKSUM_LENGTH
+    padded := header + secret + strings.Repeat(string([]byte{PAD_FILL}), fill)
+    return padded + padChecksum(padded), nil
+}
+
+// Strips the padding from a secret padded with padSecret. Secrets that are not
+// padded are returned as they are.
+func unpadSecret(s string) (string, error) {
+    if len(s) == 0 || s[0] != PAD_MARKER {
+        return s, nil
+    }
+    bad := newError(ErrMalformedShare, "The recovered secret's padding does not check out, the shares are wrong or corrupted.")
+    if len(s) < 1 + 2 + PAD_CHECKSUM_LENGTH {
+        return "", bad
+    }
+    body, checksum := s[:len(s)-PAD_CHECKSUM_LENGTH], s[len(s)-PAD_CHECKSUM_LENGTH:]
+    if padChecksum(body) != checksum {
+        return "", bad
+    }
+    colon := strings.IndexByte(body, ':')
+    if colon < 2 {
+        return "", bad
+    }
+    n, err := strconv.Atoi(body[1:colon])
+    if err != nil || n < 0 || colon + 1 + n > len(body) {
+        return "", bad
+    }
+    for i := colon + 1 + n; i < len(body); i++ {
+        if body[i] != PAD_FILL {
+            return "", bad
+        }
+    }
+    return body[colon+1:colon+1+n], nil
+}
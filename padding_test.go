@@ -0,0 +1,142 @@
+package main
+
+import (
+    "context"
+    "crypto/ed25519"
+    "crypto/rand"
+    "errors"
+    "strings"
+    "testing"
+)
+
+func TestPadSecret(t *testing.T) {
+    for _, secret := range([]string{"x", "hunter2", "correct horse battery staple", strings.Repeat("k", 700)}) {
+        for _, mode := range([]string{"bucket", "2048"}) {
+            padded, err := padSecret(secret, mode)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if length, _ := paddedLength(len(secret), mode); len(padded) != length || padded[0] != PAD_MARKER {
+                t.Errorf("Expecting %d bytes starting with the marker, got: %q", length, padded)
+            }
+            if strings.IndexByte(padded, 0) >= 0 {
+                t.Errorf("Padded secret contains a zero byte: %q", padded)
+            }
+            result, err := unpadSecret(padded)
+            if err != nil || result != secret {
+                t.Errorf("Expecting %q, got: %q (%v)", secret, result, err)
+            }
+        }
+    }
+
+    // Buckets are powers of two.
+    for n, expected := range(map[int]int{1: 32, 12: 32, 13: 64, 100: 128, 4000: 4096}) {
+        if length, _ := paddedLength(n, "bucket"); length != expected {
+            t.Errorf("Expecting %d for a %d byte secret, got: %d", expected, n, length)
+        }
+    }
+
+    if result, err := unpadSecret("not padded"); err != nil || result != "not padded" {
+        t.Errorf("Expecting an unpadded secret to be left alone, got: %q (%v)", result, err)
+    }
+    for _, mode := range([]string{"20", "lots", "100000", "0", "-64"}) {
+        if _, err := padSecret("hunter2", mode); !errors.Is(err, ErrInvalidParameters) {
+            t.Errorf("Expected padding %q to be rejected, got: %v", mode, err)
+        }
+    }
+    // Lengths that are not positive are bad padding, not too short for the
+    // secret.
+    for _, mode := range([]string{"0", "-64", "lots"}) {
+        if _, err := paddedLength(7, mode); err == nil || !strings.HasPrefix(err.Error(), "Bad padding") {
+            t.Errorf("Expected padding %q to be rejected as bad padding, got: %v", mode, err)
+        }
+    }
+}
+
+func TestUnpadSecretCorrupted(t *testing.T) {
+    padded, _ := padSecret("hunter2", "64")
+    for i := 1; i < len(padded); i++ {
+        corrupted := []byte(padded)
+        corrupted[i] ^= 0x01
+        if result, err := unpadSecret(string(corrupted)); !errors.Is(err, ErrMalformedShare) {
+            t.Errorf("Expected a change to byte %d to be caught, got: %q (%v)", i, result, err)
+        }
+    }
+    if _, err := unpadSecret(padded[:40]); !errors.Is(err, ErrMalformedShare) {
+        t.Errorf("Expected a truncated secret to be rejected, got: %v", err)
+    }
+}
+
+// A short password and a long key padded to the same length give shares of
+// the same length.
+func TestSplitCombinePadded(t *testing.T) {
+    field, _ := lookupField(DEFAULT_FIELD)
+    lengths := map[int]bool{}
+    for _, secret := range([]string{"hunter2hunter", strings.Repeat("MIIJKAIBAAKCAgEA", 40)}) {
+        padded, err := padSecret(secret, "1024")
+        if err != nil {
+            t.Fatal(err)
+        }
+        shares, err := splitSecret(padded, 3, 2, field)
+        if err != nil {
+            t.Fatal(err)
+        }
+        lengths[len(strings.Split(shares[0], "+"))] = true
+        result, err := combineShares([]string{"3", shares[2], "1", shares[0]}, field)
+        if err != nil || result != secret {
+            t.Errorf("Expecting %q, got: %q (%v)", secret, result, err)
+        }
+    }
+    if len(lengths) != 1 {
+        t.Errorf("Expecting shares of one length, got: %v", lengths)
+    }
+
+    // The secret inside the padding must still be ASCII.
+    padded, _ := padSecret("caf\xc3\xa9", "bucket")
+    if _, err := splitSecret(padded, 3, 2, field); !errors.Is(err, ErrInvalidParameters) || err.Error() != "Secret must be ASCII." {
+        t.Errorf("Expected ErrInvalidParameters, got: %v", err)
+    }
+
+    // Broken padding is reported as such, not as a secret that is not ASCII.
+    padded, _ = padSecret("hunter2", "bucket")
+    for _, bad := range([]string{padded[:len(padded)-1] + "x", "\xff7:hunter2", "\xff"}) {
+        if _, err := splitSecret(bad, 3, 2, field); !errors.Is(err, ErrInvalidParameters) || !strings.Contains(err.Error(), "padding") {
+            t.Errorf("Expected a padding error for %q, got: %v", bad, err)
+        }
+    }
+}
+
+// The checksum does not stop someone who can change the shares from changing
+// the length; in a signed split the dealer signatures do.
+func TestPaddedLengthSigned(t *testing.T) {
+    pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+    split := func(secret string) ([]string, []shareRecord) {
+        _, records, err := splitNative(context.Background(), "0badc0ffee", secret, 3, 2, DEFAULT_FIELD, 1, "", "64", rand.Reader)
+        if err != nil {
+            t.Fatal(err)
+        }
+        input := []string{}
+        for i := range(records) {
+            records[i].sign(priv)
+            input = append(input, records[i].index.String(), records[i].payload)
+        }
+        return input, records
+    }
+    input, records := split("hunter2")
+    // The same shares, but for the secret cut one byte short, under the
+    // original signatures.
+    forged, forged_records := split("hunter")
+    for i := range(forged_records) {
+        forged_records[i].signature = records[i].signature
+    }
+
+    if result, err := combineNative(context.Background(), input, records, "", 1, pub); err != nil || result != "hunter2" {
+        t.Errorf("Expecting hunter2, got: %q (%v)", result, err)
+    }
+    if result, err := combineNative(context.Background(), forged, forged_records, "", 1, nil); err != nil || result != "hunter" {
+        t.Errorf("Expecting unsigned padding to accept a new length, got: %q (%v)", result, err)
+    }
+    if _, err := combineNative(context.Background(), forged, forged_records, "", 1, pub); !errors.Is(err, ErrUntrustedShare) {
+        t.Errorf("Expecting a signed split to reject a changed length, got: %v", err)
+    }
+}
@@ -0,0 +1,636 @@
+package main
+
+import (
+    "fmt"
+    "image"
+    "image/color"
+    "image/png"
+    "io"
+    "strings"
+)
+
+// A QR code encoder (ISO/IEC 18004), so shares can be printed and scanned
+// back without any other tool. Text is written as a single numeric,
+// alphanumeric or byte segment, in the smallest version that fits, with the
+// highest error correction level that fits in that same version.
+
+// Error correction levels, in increasing strength.
+const (
+    QR_LEVEL_L = iota
+    QR_LEVEL_M
+    QR_LEVEL_Q
+    QR_LEVEL_H
+)
+
+var qrLevelNames = []string{"L", "M", "Q", "H"}
+
+// The two bits identifying each level in the format information.
+var qrLevelBits = []int{1, 0, 3, 2}
+
+const (
+    QR_MIN_VERSION = 1
+    QR_MAX_VERSION = 40
+
+    QR_MODE_NUMERIC = 0x1
+    QR_MODE_ALPHANUMERIC = 0x2
+    QR_MODE_BYTE = 0x4
+
+    // Modules of light border around the symbol, as the standard requires.
+    QR_QUIET_ZONE = 4
+    // The generator polynomial of GF(256) used by QR's Reed-Solomon codes,
+    // which differs from the AES one in gf256.go.
+    QR_GF_POLYNOMIAL = 0x11d
+)
+
+const QR_ALPHANUMERIC_CHARSET = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// Error correction codewords per block, by level and version.
+var qrEccCodewordsPerBlock = [4][41]int{
+    {-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+    {-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+    {-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+    {-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// Error correction blocks, by level and version.
+var qrEccBlocks = [4][41]int{
+    {-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+    {-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+    {-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+    {-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+type qrCode struct {
+    version int
+    level   int
+    mask    int
+    size    int
+    // modules[y][x] is true for a dark module.
+    modules    [][]bool
+    isFunction [][]bool
+}
+
+// Multiplies in QR's GF(256).
+func qrMul(x, y byte) byte {
+    z := 0
+    for i := 7; i >= 0; i-- {
+        z = (z << 1) ^ ((z >> 7) * QR_GF_POLYNOMIAL)
+        z ^= int((y >> uint(i)) & 1) * int(x)
+    }
+    return byte(z)
+}
+
+// The Reed-Solomon generator polynomial of the given degree, highest
+// coefficient first and the leading 1 left out.
+func qrReedSolomonDivisor(degree int) []byte {
+    res := make([]byte, degree)
+    res[degree-1] = 1
+    root := byte(1)
+    for i := 0; i < degree; i++ {
+        for j := range(res) {
+            res[j] = qrMul(res[j], root)
+            if j+1 < len(res) {
+                res[j] ^= res[j+1]
+            }
+        }
+        root = qrMul(root, 2)
+    }
+    return res
+}
+
+// The error correction codewords of data: the remainder of its division by
+// the generator polynomial.
+func qrReedSolomonRemainder(data, divisor []byte) []byte {
+    res := make([]byte, len(divisor))
+    for _, b := range(data) {
+        factor := b ^ res[0]
+        copy(res, res[1:])
+        res[len(res)-1] = 0
+        for i := range(res) {
+            res[i] ^= qrMul(divisor[i], factor)
+        }
+    }
+    return res
+}
+
+// The number of modules available for data and error correction, after
+// the function patterns.
+func qrRawDataModules(version int) int {
+    res := (16 * version + 128) * version + 64
+    if version >= 2 {
+        alignments := version / 7 + 2
+        res -= (25 * alignments - 10) * alignments - 55
+        if version >= 7 {
+            res -= 36
+        }
+    }
+    return res
+}
+
+// The number of data codewords a symbol of this version and level holds.
+func qrDataCodewords(version, level int) int {
+    return qrRawDataModules(version) / 8 - qrEccCodewordsPerBlock[level][version] * qrEccBlocks[level][version]
+}
+
+// The centre coordinates of the alignment patterns, in both directions.
+func qrAlignmentPositions(version int) []int {
+    if version == 1 {
+        return nil
+    }
+    alignments := version / 7 + 2
+    step := (version * 8 + alignments * 3 + 5) / (alignments * 4 - 4) * 2
+    res := make([]int, alignments)
+    res[0] = 6
+    for i, pos := alignments-1, version*4+10; i > 0; i, pos = i-1, pos-step {
+        res[i] = pos
+    }
+    return res
+}
+
+// A bit stream, most significant bit first.
+type qrBits []bool
+
+func (b *qrBits) append(value, length int) {
+    for i := length - 1; i >= 0; i-- {
+        *b = append(*b, (value >> uint(i)) & 1 == 1)
+    }
+}
+
+// Picks the most compact mode that can hold all of text.
+func qrMode(text string) int {
+    numeric, alphanumeric := true, true
+    for _, c := range([]byte(text)) {
+        numeric = numeric && c >= '0' && c <= '9'
+        alphanumeric = alphanumeric && strings.IndexByte(QR_ALPHANUMERIC_CHARSET, c) >= 0
+    }
+    switch {
+        case numeric:
+            return QR_MODE_NUMERIC
+        case alphanumeric:
+            return QR_MODE_ALPHANUMERIC
+    }
+    return QR_MODE_BYTE
+}
+
+// The width of the character count field for a mode, which grows with the
+// version.
+func qrCountBits(mode, version int) int {
+    sizes := map[int][3]int{
+        QR_MODE_NUMERIC: {10, 12, 14},
+        QR_MODE_ALPHANUMERIC: {9, 11, 13},
+        QR_MODE_BYTE: {8, 16, 16},
+    }[mode]
+    switch {
+        case version <= 9:
+            return sizes[0]
+        case version <= 26:
+            return sizes[1]
+    }
+    return sizes[2]
+}
+
+// Encodes text as one segment: mode, character count and data.
+func qrSegment(text string, mode, version int) qrBits {
+    bits := qrBits{}
+    bits.append(mode, 4)
+    bits.append(len(text), qrCountBits(mode, version))
+    switch mode {
+        case QR_MODE_NUMERIC:
+            for i := 0; i < len(text); i += 3 {
+                chunk := text[i:]
+                if len(chunk) > 3 {
+                    chunk = chunk[:3]
+                }
+                value := 0
+                for _, c := range([]byte(chunk)) {
+                    value = value * 10 + int(c - '0')
+                }
+                bits.append(value, len(chunk) * 3 + 1)
+            }
+        case QR_MODE_ALPHANUMERIC:
+            for i := 0; i < len(text); i += 2 {
+                value := strings.IndexByte(QR_ALPHANUMERIC_CHARSET, text[i])
+                if i+1 < len(text) {
+                    bits.append(value * 45 + strings.IndexByte(QR_ALPHANUMERIC_CHARSET, text[i+1]), 11)
+                } else {
+                    bits.append(value, 6)
+                }
+            }
+        default:
+            for _, c := range([]byte(text)) {
+                bits.append(int(c), 8)
+            }
+    }
+    return bits
+}
+
+// Encodes text in the smallest version that holds it, then raises the error
+// correction level as far as that version allows.
+func encodeQR(text string) (*qrCode, error) {
+    mode := qrMode(text)
+    version := QR_MIN_VERSION
+    var bits qrBits
+    for ; version <= QR_MAX_VERSION; version++ {
+        bits = qrSegment(text, mode, version)
+        if len(bits) <= qrDataCodewords(version, QR_LEVEL_L) * 8 {
+            break
+        }
+    }
+    if version > QR_MAX_VERSION {
+        return nil, newError(ErrInvalidParameters, fmt.Sprintf("%d characters are too many for a QR code.", len(text)))
+    }
+    level := QR_LEVEL_L
+    for level < QR_LEVEL_H && len(bits) <= qrDataCodewords(version, level+1) * 8 {
+        level++
+    }
+
+    // Terminator, padding to a whole byte, then alternating pad bytes.
+    capacity := qrDataCodewords(version, level) * 8
+    terminator := capacity - len(bits)
+    if terminator > 4 {
+        terminator = 4
+    }
+    bits.append(0, terminator)
+    bits.append(0, (8 - len(bits) % 8) % 8)
+    for pad := 0xec; len(bits) < capacity; pad ^= 0xec ^ 0x11 {
+        bits.append(pad, 8)
+    }
+    data := make([]byte, len(bits) / 8)
+    for i, bit := range(bits) {
+        if bit {
+            data[i/8] |= 1 << uint(7 - i%8)
+        }
+    }
+    return newQRCode(version, level, qrInterleave(data, version, level)), nil
+}
+
+// Splits data into blocks, adds the error correction codewords of each and
+// interleaves them into the final sequence of codewords.
+func qrInterleave(data []byte, version, level int) []byte {
+    blocks := qrEccBlocks[level][version]
+    ecc_length := qrEccCodewordsPerBlock[level][version]
+    raw_codewords := qrRawDataModules(version) / 8
+    short_blocks := blocks - raw_codewords % blocks
+    short_length := raw_codewords / blocks
+
+    divisor := qrReedSolomonDivisor(ecc_length)
+    data_blocks := [][]byte{}
+    ecc_blocks := [][]byte{}
+    for i, k := 0, 0; i < blocks; i++ {
+        length := short_length - ecc_length
+        if i >= short_blocks {
+            length++
+        }
+        data_blocks = append(data_blocks, data[k:k+length])
+        ecc_blocks = append(ecc_blocks, qrReedSolomonRemainder(data[k:k+length], divisor))
+        k += length
+    }
+    res := []byte{}
+    for i := 0; i <= short_length - ecc_length; i++ {
+        for _, block := range(data_blocks) {
+            if i < len(block) {
+                res = append(res, block[i])
+            }
+        }
+    }
+    for i := 0; i < ecc_length; i++ {
+        for _, block := range(ecc_blocks) {
+            res = append(res, block[i])
+        }
+    }
+    return res
+}
+
+// Lays out the codewords and applies the mask with the lowest penalty.
+func newQRCode(version, level int, codewords []byte) *qrCode {
+    size := version * 4 + 17
+    q := &qrCode{version: version, level: level, size: size}
+    q.modules = make([][]bool, size)
+    q.isFunction = make([][]bool, size)
+    for i := range(q.modules) {
+        q.modules[i] = make([]bool, size)
+        q.isFunction[i] = make([]bool, size)
+    }
+    q.drawFunctionPatterns()
+    q.drawCodewords(codewords)
+
+    best, best_penalty := 0, -1
+    for mask := 0; mask < 8; mask++ {
+        q.applyMask(mask)
+        q.drawFormatBits(mask)
+        if penalty := q.penalty(); best_penalty < 0 || penalty < best_penalty {
+            best, best_penalty = mask, penalty
+        }
+        q.applyMask(mask)
+    }
+    q.mask = best
+    q.applyMask(best)
+    q.drawFormatBits(best)
+    return q
+}
+
+func (q *qrCode) setFunction(x, y int, dark bool) {
+    q.modules[y][x] = dark
+    q.isFunction[y][x] = true
+}
+
+func (q *qrCode) drawFunctionPatterns() {
+    for i := 0; i < q.size; i++ {
+        q.setFunction(6, i, i % 2 == 0)
+        q.setFunction(i, 6, i % 2 == 0)
+    }
+    q.drawFinder(3, 3)
+    q.drawFinder(q.size-4, 3)
+    q.drawFinder(3, q.size-4)
+
+    positions := qrAlignmentPositions(q.version)
+    last := len(positions) - 1
+    for i, x := range(positions) {
+        for j, y := range(positions) {
+            // Skip the three that would overlap the finder patterns.
+            if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+                continue
+            }
+            for dy := -2; dy <= 2; dy++ {
+                for dx := -2; dx <= 2; dx++ {
+                    q.setFunction(x+dx, y+dy, qrMax(qrAbs(dx), qrAbs(dy)) != 1)
+                }
+            }
+        }
+    }
+    // Reserve the format areas with dummy bits until the mask is chosen.
+    q.drawFormatBits(0)
+    q.drawVersionBits()
+}
+
+// Draws a finder pattern with its separator, centred on (x, y).
+func (q *qrCode) drawFinder(x, y int) {
+    for dy := -4; dy <= 4; dy++ {
+        for dx := -4; dx <= 4; dx++ {
+            if x+dx < 0 || x+dx >= q.size || y+dy < 0 || y+dy >= q.size {
+                continue
+            }
+            distance := qrMax(qrAbs(dx), qrAbs(dy))
+            q.setFunction(x+dx, y+dy, distance != 2 && distance != 4)
+        }
+    }
+}
+
+// The 15 format bits: the level and mask with a BCH(15,5) code.
+func qrFormatBits(level, mask int) int {
+    data := qrLevelBits[level] << 3 | mask
+    rem := data
+    for i := 0; i < 10; i++ {
+        rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+    }
+    return (data << 10 | rem) ^ 0x5412
+}
+
+// The 18 version bits of versions 7 and up: the version with a BCH(18,6)
+// code.
+func qrVersionBits(version int) int {
+    rem := version
+    for i := 0; i < 12; i++ {
+        rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+    }
+    return version << 12 | rem
+}
+
+func (q *qrCode) drawFormatBits(mask int) {
+    bits := qrFormatBits(q.level, mask)
+    bit := func(i int) bool {
+        return (bits >> uint(i)) & 1 == 1
+    }
+    // Around the top left finder.
+    for i := 0; i <= 5; i++ {
+        q.setFunction(8, i, bit(i))
+    }
+    q.setFunction(8, 7, bit(6))
+    q.setFunction(8, 8, bit(7))
+    q.setFunction(7, 8, bit(8))
+    for i := 9; i < 15; i++ {
+        q.setFunction(14-i, 8, bit(i))
+    }
+    // Split between the other two finders.
+    for i := 0; i < 8; i++ {
+        q.setFunction(q.size-1-i, 8, bit(i))
+    }
+    for i := 8; i < 15; i++ {
+        q.setFunction(8, q.size-15+i, bit(i))
+    }
+    q.setFunction(8, q.size-8, true)
+}
+
+func (q *qrCode) drawVersionBits() {
+    if q.version < 7 {
+        return
+    }
+    bits := qrVersionBits(q.version)
+    for i := 0; i < 18; i++ {
+        dark := (bits >> uint(i)) & 1 == 1
+        a, b := q.size-11+i%3, i/3
+        q.setFunction(a, b, dark)
+        q.setFunction(b, a, dark)
+    }
+}
+
+// Places the codewords in the zigzag order of two module wide columns,
+// right to left, skipping the function patterns.
+func (q *qrCode) drawCodewords(codewords []byte) {
+    i := 0
+    for right := q.size - 1; right >= 1; right -= 2 {
+        if right == 6 {
+            right = 5
+        }
+        for vert := 0; vert < q.size; vert++ {
+            for j := 0; j < 2; j++ {
+                x := right - j
+                y := vert
+                if (right + 1) & 2 == 0 {
+                    y = q.size - 1 - vert
+                }
+                if !q.isFunction[y][x] && i < len(codewords) * 8 {
+                    q.modules[y][x] = (codewords[i>>3] >> uint(7 - i&7)) & 1 == 1
+                    i++
+                }
+            }
+        }
+    }
+}
+
+func qrMaskBit(mask, x, y int) bool {
+    switch mask {
+        case 0:
+            return (x + y) % 2 == 0
+        case 1:
+            return y % 2 == 0
+        case 2:
+            return x % 3 == 0
+        case 3:
+            return (x + y) % 3 == 0
+        case 4:
+            return (x / 3 + y / 2) % 2 == 0
+        case 5:
+            return x * y % 2 + x * y % 3 == 0
+        case 6:
+            return (x * y % 2 + x * y % 3) % 2 == 0
+    }
+    return ((x + y) % 2 + x * y % 3) % 2 == 0
+}
+
+// Flips the data modules under the mask; applying it twice undoes it.
+func (q *qrCode) applyMask(mask int) {
+    for y := 0; y < q.size; y++ {
+        for x := 0; x < q.size; x++ {
+            if !q.isFunction[y][x] && qrMaskBit(mask, x, y) {
+                q.modules[y][x] = !q.modules[y][x]
+            }
+        }
+    }
+}
+
+// Scores the symbol with the four penalty rules of the standard; the mask
+// with the lowest score is used.
+func (q *qrCode) penalty() int {
+    res := 0
+    finder := []bool{true, false, true, true, true, false, true}
+    for pass := 0; pass < 2; pass++ {
+        for a := 0; a < q.size; a++ {
+            line := make([]bool, q.size)
+            for b := range(line) {
+                if pass == 0 {
+                    line[b] = q.modules[a][b]
+                } else {
+                    line[b] = q.modules[b][a]
+                }
+            }
+            // Runs of five or more modules of one colour.
+            run := 1
+            for b := 1; b <= q.size; b++ {
+                if b < q.size && line[b] == line[b-1] {
+                    run++
+                    continue
+                }
+                if run >= 5 {
+                    res += 3 + run - 5
+                }
+                run = 1
+            }
+            // Patterns like a finder, with four light modules on one side.
+            for b := 0; b + len(finder) <= q.size; b++ {
+                matches := true
+                for k, dark := range(finder) {
+                    matches = matches && line[b+k] == dark
+                }
+                if matches && (qrLight(line, b-4, b) || qrLight(line, b+7, b+11)) {
+                    res += 40
+                }
+            }
+        }
+    }
+    // Two by two blocks of one colour.
+    dark := 0
+    for y := 0; y < q.size; y++ {
+        for x := 0; x < q.size; x++ {
+            if q.modules[y][x] {
+                dark++
+            }
+            if x+1 < q.size && y+1 < q.size {
+                c := q.modules[y][x]
+                if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+                    res += 3
+                }
+            }
+        }
+    }
+    // The proportion of dark modules, in steps of 5% away from half.
+    total := q.size * q.size
+    k := (qrAbs(dark * 20 - total * 10) + total - 1) / total - 1
+    return res + k * 10
+}
+
+// Whether line[from:to] is all light, counting modules outside the symbol
+// as light.
+func qrLight(line []bool, from, to int) bool {
+    for i := from; i < to; i++ {
+        if i >= 0 && i < len(line) && line[i] {
+            return false
+        }
+    }
+    return true
+}
+
+func qrAbs(x int) int {
+    if x < 0 {
+        return -x
+    }
+    return x
+}
+
+func qrMax(a, b int) int {
+    if a > b {
+        return a
+    }
+    return b
+}
+
+// Whether the module at (x, y) is dark, with the quiet zone around the
+// symbol light.
+func (q *qrCode) dark(x, y int) bool {
+    return x >= 0 && x < q.size && y >= 0 && y < q.size && q.modules[y][x]
+}
+
+// Writes the symbol as a black and white PNG, scale pixels to a module.
+func (q *qrCode) writePNG(w io.Writer, scale int) error {
+    width := (q.size + 2 * QR_QUIET_ZONE) * scale
+    img := image.NewPaletted(image.Rect(0, 0, width, width), color.Palette{color.White, color.Black})
+    for y := 0; y < width; y++ {
+        for x := 0; x < width; x++ {
+            if q.dark(x / scale - QR_QUIET_ZONE, y / scale - QR_QUIET_ZONE) {
+                img.SetColorIndex(x, y, 1)
+            }
+        }
+    }
+    return png.Encode(w, img)
+}
+
+// Returns the symbol as an SVG image, one unit to a module.
+func (q *qrCode) svg() string {
+    width := q.size + 2 * QR_QUIET_ZONE
+    var b strings.Builder
+    fmt.Fprintf(&b, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+    fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" version=\"1.1\" viewBox=\"0 0 %d %d\" stroke=\"none\">\n", width, width)
+    fmt.Fprintf(&b, "<rect width=\"100%%\" height=\"100%%\" fill=\"#FFFFFF\"/>\n<path d=\"")
+    for y := 0; y < q.size; y++ {
+        for x := 0; x < q.size; x++ {
+            if q.modules[y][x] {
+                fmt.Fprintf(&b, "M%d,%dh1v1h-1z", x + QR_QUIET_ZONE, y + QR_QUIET_ZONE)
+            }
+        }
+    }
+    fmt.Fprintf(&b, "\" fill=\"#000000\"/>\n</svg>\n")
+    return b.String()
+}
+
+// Returns the symbol drawn with half block characters, two rows to a line.
+// Like `qrencode -t UTF8` it is meant for light text on a dark terminal, so
+// light modules are drawn and dark ones left blank.
+func (q *qrCode) terminal() string {
+    const margin = 2
+    blocks := []string{" ", "▄", "▀", "█"}
+    var b strings.Builder
+    for y := -margin; y < q.size + margin; y += 2 {
+        for x := -margin; x < q.size + margin; x++ {
+            i := 0
+            if !q.dark(x, y) {
+                i |= 2
+            }
+            if !q.dark(x, y+1) {
+                i |= 1
+            }
+            b.WriteString(blocks[i])
+        }
+        b.WriteString("\n")
+    }
+    return b.String()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+    "bytes"
+    "image/png"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// The "HELLO WORLD" example of Thonky's QR code tutorial, whose error
+// correction codewords for version 1-M are 196 35 39 119 235 215 231 226 93
+// 23.
+func TestQRReedSolomon(t *testing.T) {
+    data := []byte{0x20, 0x5b, 0x0b, 0x78, 0xd1, 0x72, 0xdc, 0x4d, 0x43, 0x40, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11}
+    expected := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+    if ecc := qrReedSolomonRemainder(data, qrReedSolomonDivisor(10)); !bytes.Equal(ecc, expected) {
+        t.Errorf("Expecting %v, got: %v", expected, ecc)
+    }
+}
+
+// Values from the format and version information tables of the standard.
+func TestQRFormatAndVersionBits(t *testing.T) {
+    for _, test := range([][3]int{{QR_LEVEL_L, 0, 0x77c4}, {QR_LEVEL_M, 0, 0x5412}, {QR_LEVEL_Q, 0, 0x355f}, {QR_LEVEL_H, 0, 0x1689}, {QR_LEVEL_L, 7, 0x6976}, {QR_LEVEL_H, 7, 0x083b}}) {
+        if bits := qrFormatBits(test[0], test[1]); bits != test[2] {
+            t.Errorf("Expecting %015b for %s mask %d, got: %015b", test[2], qrLevelNames[test[0]], test[1], bits)
+        }
+    }
+    for version, expected := range(map[int]int{7: 0x07c94, 21: 0x15683, 40: 0x28c69}) {
+        if bits := qrVersionBits(version); bits != expected {
+            t.Errorf("Expecting %018b for version %d, got: %018b", expected, version, bits)
+        }
+    }
+}
+
+func TestQRTables(t *testing.T) {
+    for version, expected := range(map[int][]int{1: nil, 2: {6, 18}, 7: {6, 22, 38}, 32: {6, 34, 60, 86, 112, 138}, 40: {6, 30, 58, 86, 114, 142, 170}}) {
+        if positions := qrAlignmentPositions(version); !reflect.DeepEqual(positions, expected) {
+            t.Errorf("Expecting %v for version %d, got: %v", expected, version, positions)
+        }
+    }
+    // Data codewords for levels L, M, Q and H.
+    for version, expected := range(map[int][4]int{1: {19, 16, 13, 9}, 5: {108, 86, 62, 46}, 10: {274, 216, 154, 122}, 40: {2956, 2334, 1666, 1276}}) {
+        for level, codewords := range(expected) {
+            if qrDataCodewords(version, level) != codewords {
+                t.Errorf("Expecting %d codewords for %d-%s, got: %d", codewords, version, qrLevelNames[level], qrDataCodewords(version, level))
+            }
+        }
+    }
+}
+
+func TestEncodeQR(t *testing.T) {
+    // 74 bits of alphanumeric data fit version 1 up to level Q.
+    q, err := encodeQR("HELLO WORLD")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if q.version != 1 || q.level != QR_LEVEL_Q || q.size != 21 {
+        t.Errorf("Expecting 1-Q, got: %d-%s", q.version, qrLevelNames[q.level])
+    }
+    data := []byte{0x20, 0x5b, 0x0b, 0x78, 0xd1, 0x72, 0xdc, 0x4d, 0x43, 0x40, 0xec, 0x11, 0xec}
+    expected := append(data, qrReedSolomonRemainder(data, qrReedSolomonDivisor(13))...)
+    if codewords := q.readCodewords(); !bytes.Equal(codewords, expected) {
+        t.Errorf("Expecting %x, got: %x", expected, codewords)
+    }
+    // The finder in the top left corner and the dark module.
+    for i := 0; i < 7; i++ {
+        if !q.modules[0][i] || !q.modules[6][i] || !q.modules[i][0] || q.modules[1][i] != (i == 0 || i == 6) {
+            t.Errorf("Bad finder pattern in row %d", i)
+        }
+    }
+    if !q.modules[q.size-8][8] {
+        t.Errorf("Dark module is light")
+    }
+
+    // A share record of several blocks of both lengths.
+    record := "SHAMIR1:" + strings.Repeat("0123456789ABCDEF", 22)
+    q, err = encodeQR(record)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if q.version != 10 || q.level != QR_LEVEL_L || len(q.readCodewords()) != qrRawDataModules(10) / 8 {
+        t.Errorf("Expecting 10-L, got: %d-%s", q.version, qrLevelNames[q.level])
+    }
+    if _, err := encodeQR(strings.Repeat("x", 3000)); err == nil {
+        t.Errorf("Expecting an error for too much data")
+    }
+}
+
+func TestQRSegments(t *testing.T) {
+    tests := []struct {
+        text string
+        mode int
+        bits int
+    }{
+        {"01234567", QR_MODE_NUMERIC, 4 + 10 + 10 + 10 + 7},
+        {"AC-42", QR_MODE_ALPHANUMERIC, 4 + 9 + 11 + 11 + 6},
+        {"shamir", QR_MODE_BYTE, 4 + 8 + 6 * 8},
+    }
+    for _, test := range(tests) {
+        if mode := qrMode(test.text); mode != test.mode {
+            t.Errorf("Expecting mode %d for %q, got: %d", test.mode, test.text, mode)
+        }
+        if bits := qrSegment(test.text, test.mode, 1); len(bits) != test.bits {
+            t.Errorf("Expecting %d bits for %q, got: %d", test.bits, test.text, len(bits))
+        }
+    }
+}
+
+func TestQROutputs(t *testing.T) {
+    q, _ := encodeQR("HELLO WORLD")
+    var b bytes.Buffer
+    if err := q.writePNG(&b, 4); err != nil {
+        t.Fatal(err)
+    }
+    img, err := png.Decode(&b)
+    if err != nil || img.Bounds().Dx() != (21 + 2 * QR_QUIET_ZONE) * 4 {
+        t.Errorf("Bad PNG: %v", err)
+    }
+    if svg := q.svg(); !strings.Contains(svg, "viewBox=\"0 0 29 29\"") || !strings.Contains(svg, "M4,4h1v1h-1z") {
+        t.Errorf("Bad SVG: %s", svg)
+    }
+    if lines := strings.Split(strings.TrimSuffix(q.terminal(), "\n"), "\n"); len(lines) != 13 || len([]rune(lines[0])) != 25 {
+        t.Errorf("Bad terminal rendering: %q", lines)
+    }
+}
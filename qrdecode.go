@@ -0,0 +1,915 @@
+package main
+
+import (
+    "fmt"
+    "image"
+    _ "image/jpeg"
+    "math"
+    "sort"
+    "strings"
+)
+
+// A QR code reader for scanned or photographed shares, the inverse of qr.go.
+// The image is thresholded, the three finder patterns are located by their
+// 1:1:3:1:1 runs, the grid is sampled through a perspective transform
+// (refined with the bottom right alignment pattern when there is one), and
+// the codewords are corrected with Reed-Solomon decoding before the segments
+// are read.
+
+// A thresholded image; dark[y*w+x] is true for dark pixels.
+type qrBitmap struct {
+    w, h int
+    dark []bool
+}
+
+func (b *qrBitmap) at(x, y int) bool {
+    return x >= 0 && x < b.w && y >= 0 && y < b.h && b.dark[y*b.w+x]
+}
+
+func (b *qrBitmap) inside(x, y int) bool {
+    return x >= 0 && x < b.w && y >= 0 && y < b.h
+}
+
+func (b *qrBitmap) inverted() *qrBitmap {
+    res := &qrBitmap{b.w, b.h, make([]bool, len(b.dark))}
+    for i, d := range(b.dark) {
+        res.dark[i] = !d
+    }
+    return res
+}
+
+// The luminance of every pixel, 0 to 255.
+func qrLuminance(img image.Image) ([]int, int, int) {
+    bounds := img.Bounds()
+    w, h := bounds.Dx(), bounds.Dy()
+    lum := make([]int, w*h)
+    for y := 0; y < h; y++ {
+        for x := 0; x < w; x++ {
+            r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+            lum[y*w+x] = int(299 * r + 587 * g + 114 * b) / 1000 >> 8
+        }
+    }
+    return lum, w, h
+}
+
+// Thresholds at the level that best separates the two peaks of the
+// histogram (Otsu's method), which suits evenly lit scans.
+func qrGlobalThreshold(lum []int, w, h int) *qrBitmap {
+    histogram := make([]int, 256)
+    sum := 0
+    for _, l := range(lum) {
+        histogram[l]++
+        sum += l
+    }
+    best, best_variance := 0, -1.0
+    background, background_sum := 0, 0
+    for t := 0; t < 256; t++ {
+        background += histogram[t]
+        background_sum += t * histogram[t]
+        foreground := len(lum) - background
+        if background == 0 || foreground == 0 {
+            continue
+        }
+        mean_b := float64(background_sum) / float64(background)
+        mean_f := float64(sum - background_sum) / float64(foreground)
+        variance := float64(background) * float64(foreground) * (mean_b - mean_f) * (mean_b - mean_f)
+        if variance > best_variance {
+            best, best_variance = t, variance
+        }
+    }
+    b := &qrBitmap{w, h, make([]bool, len(lum))}
+    for i, l := range(lum) {
+        b.dark[i] = l <= best
+    }
+    return b
+}
+
+// Thresholds every pixel against the mean of its neighbourhood, which copes
+// with shadows and uneven lighting in photos.
+func qrLocalThreshold(lum []int, w, h int) *qrBitmap {
+    integral := make([]int, (w+1)*(h+1))
+    for y := 0; y < h; y++ {
+        row := 0
+        for x := 0; x < w; x++ {
+            row += lum[y*w+x]
+            integral[(y+1)*(w+1)+x+1] = integral[y*(w+1)+x+1] + row
+        }
+    }
+    radius := qrMax(8, qrMin(w, h) / 16)
+    b := &qrBitmap{w, h, make([]bool, len(lum))}
+    for y := 0; y < h; y++ {
+        y0, y1 := qrMax(0, y-radius), qrMin(h, y+radius+1)
+        for x := 0; x < w; x++ {
+            x0, x1 := qrMax(0, x-radius), qrMin(w, x+radius+1)
+            total := integral[y1*(w+1)+x1] - integral[y0*(w+1)+x1] - integral[y1*(w+1)+x0] + integral[y0*(w+1)+x0]
+            b.dark[y*w+x] = lum[y*w+x] * (x1-x0) * (y1-y0) * 100 < total * 85
+        }
+    }
+    return b
+}
+
+func qrMin(a, b int) int {
+    if a < b {
+        return a
+    }
+    return b
+}
+
+type qrPoint struct {
+    x, y float64
+}
+
+func qrDistance(a, b qrPoint) float64 {
+    return math.Hypot(a.x - b.x, a.y - b.y)
+}
+
+// A possible finder pattern, seen count times.
+type qrFinder struct {
+    qrPoint
+    moduleSize float64
+    count      int
+}
+
+// Whether runs of dark, light, dark, light and dark pixels are in the
+// proportions 1:1:3:1:1 of a finder pattern.
+func qrFinderRatio(counts [5]int) bool {
+    total := 0
+    for _, c := range(counts) {
+        if c == 0 {
+            return false
+        }
+        total += c
+    }
+    if total < 7 {
+        return false
+    }
+    module := float64(total) / 7
+    tolerance := module / 2
+    return math.Abs(module - float64(counts[0])) < tolerance &&
+        math.Abs(module - float64(counts[1])) < tolerance &&
+        math.Abs(3 * module - float64(counts[2])) < 3 * tolerance &&
+        math.Abs(module - float64(counts[3])) < tolerance &&
+        math.Abs(module - float64(counts[4])) < tolerance
+}
+
+// Measures the finder pattern runs through (x, y) along (dx, dy), returning
+// the offset of the centre of the middle run from (x, y) and the runs.
+func (b *qrBitmap) crossCheck(x, y, dx, dy, maxCount int) (float64, [5]int, bool) {
+    var counts [5]int
+    if !b.at(x, y) {
+        return 0, counts, false
+    }
+    k := 0
+    for ; b.inside(x+k*dx, y+k*dy) && b.at(x+k*dx, y+k*dy); k-- {
+        counts[2]++
+    }
+    for ; b.inside(x+k*dx, y+k*dy) && !b.at(x+k*dx, y+k*dy) && counts[1] <= maxCount; k-- {
+        counts[1]++
+    }
+    for ; b.inside(x+k*dx, y+k*dy) && b.at(x+k*dx, y+k*dy) && counts[0] <= maxCount; k-- {
+        counts[0]++
+    }
+    for k = 1; b.inside(x+k*dx, y+k*dy) && b.at(x+k*dx, y+k*dy); k++ {
+        counts[2]++
+    }
+    for ; b.inside(x+k*dx, y+k*dy) && !b.at(x+k*dx, y+k*dy) && counts[3] <= maxCount; k++ {
+        counts[3]++
+    }
+    for ; b.inside(x+k*dx, y+k*dy) && b.at(x+k*dx, y+k*dy) && counts[4] <= maxCount; k++ {
+        counts[4]++
+    }
+    if !qrFinderRatio(counts) {
+        return 0, counts, false
+    }
+    return float64(k - counts[4] - counts[3]) - float64(counts[2]) / 2, counts, true
+}
+
+func qrRunTotal(counts [5]int) int {
+    return counts[0] + counts[1] + counts[2] + counts[3] + counts[4]
+}
+
+// Confirms a finder pattern seen in a row by crossing it vertically and
+// then horizontally again, and returns its centre and module size.
+func (b *qrBitmap) confirmFinder(counts [5]int, end, y int) (qrFinder, bool) {
+    total := qrRunTotal(counts)
+    cx := float64(end - counts[4] - counts[3]) - float64(counts[2]) / 2
+    offset, vertical, ok := b.crossCheck(int(cx), y, 0, 1, counts[2])
+    if !ok || 5 * qrAbs(qrRunTotal(vertical) - total) >= 2 * total {
+        return qrFinder{}, false
+    }
+    cy := float64(y) + offset
+    offset, horizontal, ok := b.crossCheck(int(cx), int(cy), 1, 0, counts[2])
+    if !ok || 5 * qrAbs(qrRunTotal(horizontal) - total) >= 2 * total {
+        return qrFinder{}, false
+    }
+    cx = float64(int(cx)) + offset
+    size := float64(qrRunTotal(vertical) + qrRunTotal(horizontal)) / 14
+    return qrFinder{qrPoint{cx, cy}, size, 1}, true
+}
+
+// Scans every row for finder patterns, merging sightings of the same one.
+func (b *qrBitmap) findFinders() []qrFinder {
+    finders := []qrFinder{}
+    add := func(f qrFinder) {
+        for i, other := range(finders) {
+            if math.Abs(f.x - other.x) <= f.moduleSize && math.Abs(f.y - other.y) <= f.moduleSize && math.Abs(f.moduleSize - other.moduleSize) <= math.Max(1, f.moduleSize) {
+                n := float64(other.count)
+                finders[i] = qrFinder{
+                    qrPoint{(other.x * n + f.x) / (n + 1), (other.y * n + f.y) / (n + 1)},
+                    (other.moduleSize * n + f.moduleSize) / (n + 1),
+                    other.count + 1,
+                }
+                return
+            }
+        }
+        finders = append(finders, f)
+    }
+    for y := 0; y < b.h; y++ {
+        var counts [5]int
+        state := 0
+        for x := 0; x <= b.w; x++ {
+            if x < b.w && b.at(x, y) {
+                if state % 2 == 1 {
+                    state++
+                }
+                counts[state]++
+                continue
+            }
+            if state % 2 == 1 {
+                counts[state]++
+                continue
+            }
+            if state < 4 {
+                if counts[state] > 0 {
+                    state++
+                    counts[state]++
+                }
+                continue
+            }
+            if qrFinderRatio(counts) {
+                if f, ok := b.confirmFinder(counts, x, y); ok {
+                    add(f)
+                }
+            }
+            counts = [5]int{counts[2], counts[3], counts[4], 1, 0}
+            state = 3
+        }
+    }
+    sort.SliceStable(finders, func(i, j int) bool {
+        return finders[i].count > finders[j].count
+    })
+    return finders
+}
+
+// Three finder patterns as the top left, top right and bottom left corners.
+type qrCorners struct {
+    topLeft, topRight, bottomLeft qrFinder
+}
+
+// Picks the triples of finder patterns most like the corners of a QR code,
+// best first: two equal sides at a right angle, with similar module sizes.
+func qrFinderTriples(finders []qrFinder) []qrCorners {
+    if len(finders) > 10 {
+        finders = finders[:10]
+    }
+    type scored struct {
+        corners qrCorners
+        score   float64
+    }
+    candidates := []scored{}
+    for i := 0; i < len(finders); i++ {
+        for j := i+1; j < len(finders); j++ {
+            for k := j+1; k < len(finders); k++ {
+                a, b, c := finders[i], finders[j], finders[k]
+                sizes := []float64{a.moduleSize, b.moduleSize, c.moduleSize}
+                sort.Float64s(sizes)
+                if sizes[2] > 1.5 * sizes[0] {
+                    continue
+                }
+                // Make a the corner opposite the longest side.
+                ab, bc, ca := qrDistance(a.qrPoint, b.qrPoint), qrDistance(b.qrPoint, c.qrPoint), qrDistance(c.qrPoint, a.qrPoint)
+                if ab >= bc && ab >= ca {
+                    a, c = c, a
+                    ab, bc = bc, ab
+                } else if ca >= bc && ca >= ab {
+                    a, b = b, a
+                    bc, ca = ca, bc
+                }
+                if ab < 12 * sizes[0] || ca < 12 * sizes[0] {
+                    continue
+                }
+                score := math.Abs(bc * bc - ab * ab - ca * ca) / (bc * bc) + math.Abs(ab - ca) / math.Max(ab, ca)
+                if score > 0.5 {
+                    continue
+                }
+                // With y pointing down, top right to bottom left turns
+                // clockwise around the top left.
+                if (b.x - a.x) * (c.y - a.y) - (b.y - a.y) * (c.x - a.x) < 0 {
+                    b, c = c, b
+                }
+                candidates = append(candidates, scored{qrCorners{a, b, c}, score})
+            }
+        }
+    }
+    sort.SliceStable(candidates, func(i, j int) bool {
+        return candidates[i].score < candidates[j].score
+    })
+    res := []qrCorners{}
+    for _, c := range(candidates) {
+        res = append(res, c.corners)
+    }
+    return res
+}
+
+func (c qrCorners) moduleSize() float64 {
+    return (c.topLeft.moduleSize + c.topRight.moduleSize + c.bottomLeft.moduleSize) / 3
+}
+
+// Estimates the number of modules across from the distances between the
+// finder centres, rounded to a valid size.
+func (c qrCorners) dimension() int {
+    module := c.moduleSize()
+    across := int(math.Round(qrDistance(c.topLeft.qrPoint, c.topRight.qrPoint) / module))
+    down := int(math.Round(qrDistance(c.topLeft.qrPoint, c.bottomLeft.qrPoint) / module))
+    dimension := (across + down) / 2 + 7
+    switch dimension % 4 {
+        case 0:
+            dimension++
+        case 2:
+            dimension--
+        case 3:
+            dimension -= 2
+    }
+    return dimension
+}
+
+// A projective transform, mapping (u, v) to (x, y) in homogeneous
+// coordinates: [x*w, y*w, w] = m * [u, v, 1].
+type qrTransform [3][3]float64
+
+func (t qrTransform) apply(u, v float64) qrPoint {
+    w := t[2][0] * u + t[2][1] * v + t[2][2]
+    return qrPoint{(t[0][0] * u + t[0][1] * v + t[0][2]) / w, (t[1][0] * u + t[1][1] * v + t[1][2]) / w}
+}
+
+func (t qrTransform) times(o qrTransform) qrTransform {
+    var res qrTransform
+    for i := 0; i < 3; i++ {
+        for j := 0; j < 3; j++ {
+            for k := 0; k < 3; k++ {
+                res[i][j] += t[i][k] * o[k][j]
+            }
+        }
+    }
+    return res
+}
+
+// The adjugate, which inverts a projective transform up to scale.
+func (t qrTransform) adjugate() qrTransform {
+    var res qrTransform
+    for i := 0; i < 3; i++ {
+        for j := 0; j < 3; j++ {
+            r0, r1 := (j+1) % 3, (j+2) % 3
+            c0, c1 := (i+1) % 3, (i+2) % 3
+            res[i][j] = t[r0][c0] * t[r1][c1] - t[r0][c1] * t[r1][c0]
+        }
+    }
+    return res
+}
+
+// The transform taking the unit square's corners (0,0), (1,0), (1,1) and
+// (0,1) to p[0], p[1], p[2] and p[3].
+func qrSquareToQuad(p [4]qrPoint) qrTransform {
+    dx3 := p[0].x - p[1].x + p[2].x - p[3].x
+    dy3 := p[0].y - p[1].y + p[2].y - p[3].y
+    if dx3 == 0 && dy3 == 0 {
+        return qrTransform{
+            {p[1].x - p[0].x, p[2].x - p[1].x, p[0].x},
+            {p[1].y - p[0].y, p[2].y - p[1].y, p[0].y},
+            {0, 0, 1},
+        }
+    }
+    dx1, dx2 := p[1].x - p[2].x, p[3].x - p[2].x
+    dy1, dy2 := p[1].y - p[2].y, p[3].y - p[2].y
+    denominator := dx1 * dy2 - dx2 * dy1
+    g := (dx3 * dy2 - dx2 * dy3) / denominator
+    h := (dx1 * dy3 - dx3 * dy1) / denominator
+    return qrTransform{
+        {p[1].x - p[0].x + g * p[1].x, p[3].x - p[0].x + h * p[3].x, p[0].x},
+        {p[1].y - p[0].y + g * p[1].y, p[3].y - p[0].y + h * p[3].y, p[0].y},
+        {g, h, 1},
+    }
+}
+
+// The transform taking the quadrilateral from to the quadrilateral to.
+func qrQuadToQuad(from, to [4]qrPoint) qrTransform {
+    return qrSquareToQuad(to).times(qrSquareToQuad(from).adjugate())
+}
+
+// Looks for the 1:1:1 runs of an alignment pattern's centre through (x, y)
+// along (dx, dy), returning the offset of its centre.
+func (b *qrBitmap) alignmentCheck(x, y, dx, dy int, module float64) (float64, bool) {
+    var counts [5]int
+    k := 0
+    for ; b.at(x+k*dx, y+k*dy); k-- {
+        counts[2]++
+    }
+    for ; b.inside(x+k*dx, y+k*dy) && !b.at(x+k*dx, y+k*dy); k-- {
+        counts[1]++
+    }
+    for ; b.at(x+k*dx, y+k*dy) && float64(counts[0]) < module; k-- {
+        counts[0]++
+    }
+    for k = 1; b.at(x+k*dx, y+k*dy); k++ {
+        counts[2]++
+    }
+    for ; b.inside(x+k*dx, y+k*dy) && !b.at(x+k*dx, y+k*dy); k++ {
+        counts[3]++
+    }
+    for ; b.at(x+k*dx, y+k*dy) && float64(counts[4]) < module; k++ {
+        counts[4]++
+    }
+    for _, c := range(counts) {
+        if math.Abs(float64(c) - module) >= module / 2 {
+            return 0, false
+        }
+    }
+    return float64(k - counts[4] - counts[3]) - float64(counts[2]) / 2, true
+}
+
+// Finds the alignment pattern nearest to where it is expected.
+func (b *qrBitmap) findAlignment(expected qrPoint, module float64) (qrPoint, bool) {
+    for _, radius := range([]float64{4, 8, 16}) {
+        r := int(radius * module)
+        best, best_distance := qrPoint{}, math.Inf(1)
+        for y := int(expected.y) - r; y <= int(expected.y) + r; y++ {
+            for x := int(expected.x) - r; x <= int(expected.x) + r; x++ {
+                if !b.at(x, y) {
+                    continue
+                }
+                ox, ok := b.alignmentCheck(x, y, 1, 0, module)
+                if !ok {
+                    continue
+                }
+                oy, ok := b.alignmentCheck(int(float64(x) + ox), y, 0, 1, module)
+                if !ok {
+                    continue
+                }
+                p := qrPoint{float64(x) + ox, float64(y) + oy}
+                if d := qrDistance(p, expected); d < best_distance {
+                    best, best_distance = p, d
+                }
+            }
+        }
+        if best_distance < math.Inf(1) {
+            return best, true
+        }
+    }
+    return qrPoint{}, false
+}
+
+// Reads the module grid of the given dimension, mapping the finder centres
+// (and the alignment pattern, if found) onto their places in the grid.
+func (b *qrBitmap) sampleGrid(c qrCorners, dimension int, useAlignment bool) ([][]bool, bool) {
+    d := float64(dimension)
+    from := [4]qrPoint{{3.5, 3.5}, {d - 3.5, 3.5}, {d - 3.5, d - 3.5}, {3.5, d - 3.5}}
+    to := [4]qrPoint{c.topLeft.qrPoint, c.topRight.qrPoint, {c.topRight.x - c.topLeft.x + c.bottomLeft.x, c.topRight.y - c.topLeft.y + c.bottomLeft.y}, c.bottomLeft.qrPoint}
+    transform := qrQuadToQuad(from, to)
+    if useAlignment {
+        if dimension < 25 {
+            return nil, false
+        }
+        alignment, ok := b.findAlignment(transform.apply(d - 6.5, d - 6.5), c.moduleSize())
+        if !ok {
+            return nil, false
+        }
+        from[2], to[2] = qrPoint{d - 6.5, d - 6.5}, alignment
+        transform = qrQuadToQuad(from, to)
+    }
+
+    modules := make([][]bool, dimension)
+    for y := range(modules) {
+        modules[y] = make([]bool, dimension)
+        for x := range(modules[y]) {
+            p := transform.apply(float64(x) + 0.5, float64(y) + 0.5)
+            px, py := int(math.Floor(p.x)), int(math.Floor(p.y))
+            if !b.inside(px, py) {
+                return nil, false
+            }
+            modules[y][x] = b.at(px, py)
+        }
+    }
+    return modules, true
+}
+
+// Decodes the first QR code found in an image.
+func decodeQRImage(img image.Image) (string, error) {
+    lum, w, h := qrLuminance(img)
+    global := qrGlobalThreshold(lum, w, h)
+    local := qrLocalThreshold(lum, w, h)
+    var last error
+    for _, b := range([]*qrBitmap{global, local, global.inverted(), local.inverted()}) {
+        for _, corners := range(qrFinderTriples(b.findFinders())) {
+            dimension := corners.dimension()
+            for _, d := range([]int{dimension, dimension + 4, dimension - 4}) {
+                if d < 21 || d > 177 {
+                    continue
+                }
+                for _, useAlignment := range([]bool{true, false}) {
+                    modules, ok := b.sampleGrid(corners, d, useAlignment)
+                    if !ok {
+                        continue
+                    }
+                    text, version, err := decodeQRModules(modules)
+                    if err == nil {
+                        return text, nil
+                    }
+                    last = err
+                    // The version information knows the true size.
+                    if version > 0 && version * 4 + 17 != d {
+                        if modules, ok = b.sampleGrid(corners, version * 4 + 17, useAlignment); ok {
+                            if text, _, err = decodeQRModules(modules); err == nil {
+                                return text, nil
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    }
+    if last != nil {
+        return "", newError(ErrMalformedShare, "Found a QR code but could not read it: " + last.Error())
+    }
+    return "", newError(ErrMalformedShare, "No QR code found in the image.")
+}
+
+func qrHammingDistance(a, b int) int {
+    d := 0
+    for x := a ^ b; x != 0; x &= x - 1 {
+        d++
+    }
+    return d
+}
+
+// Reads the level and mask from whichever copy of the format information
+// is closest to a valid code, allowing up to three wrong bits.
+func qrReadFormat(modules [][]bool) (int, int, bool) {
+    size := len(modules)
+    first, second := 0, 0
+    bit := func(x, y, i int, bits *int) {
+        if modules[y][x] {
+            *bits |= 1 << uint(i)
+        }
+    }
+    for i := 0; i <= 5; i++ {
+        bit(8, i, i, &first)
+    }
+    bit(8, 7, 6, &first)
+    bit(8, 8, 7, &first)
+    bit(7, 8, 8, &first)
+    for i := 9; i < 15; i++ {
+        bit(14-i, 8, i, &first)
+    }
+    for i := 0; i < 8; i++ {
+        bit(size-1-i, 8, i, &second)
+    }
+    for i := 8; i < 15; i++ {
+        bit(8, size-15+i, i, &second)
+    }
+    best_level, best_mask, best_distance := 0, 0, 4
+    for level := QR_LEVEL_L; level <= QR_LEVEL_H; level++ {
+        for mask := 0; mask < 8; mask++ {
+            bits := qrFormatBits(level, mask)
+            for _, read := range([]int{first, second}) {
+                if d := qrHammingDistance(bits, read); d < best_distance {
+                    best_level, best_mask, best_distance = level, mask, d
+                }
+            }
+        }
+    }
+    return best_level, best_mask, best_distance <= 3
+}
+
+// Reads the version from either copy of the version information, or returns
+// 0 if neither is within three bits of a valid code.
+func qrReadVersion(modules [][]bool) int {
+    size := len(modules)
+    first, second := 0, 0
+    for i := 0; i < 18; i++ {
+        a, b := size-11+i%3, i/3
+        if modules[b][a] {
+            first |= 1 << uint(i)
+        }
+        if modules[a][b] {
+            second |= 1 << uint(i)
+        }
+    }
+    best, best_distance := 0, 4
+    for version := 7; version <= QR_MAX_VERSION; version++ {
+        for _, read := range([]int{first, second}) {
+            if d := qrHammingDistance(qrVersionBits(version), read); d < best_distance {
+                best, best_distance = version, d
+            }
+        }
+    }
+    return best
+}
+
+// Decodes a sampled module grid. Along with any error it returns the
+// version read from the version information, in case the grid was sampled at
+// the wrong size.
+func decodeQRModules(modules [][]bool) (string, int, error) {
+    size := len(modules)
+    version := (size - 17) / 4
+    if version >= 7 {
+        if read := qrReadVersion(modules); read != version {
+            return "", read, fmt.Errorf("version information does not match the size")
+        }
+    }
+    level, mask, ok := qrReadFormat(modules)
+    if !ok {
+        return "", 0, fmt.Errorf("unreadable format information")
+    }
+    q := &qrCode{version: version, level: level, mask: mask, size: size}
+    q.modules = make([][]bool, size)
+    q.isFunction = make([][]bool, size)
+    for i := range(q.modules) {
+        q.modules[i] = make([]bool, size)
+        q.isFunction[i] = make([]bool, size)
+    }
+    q.drawFunctionPatterns()
+    q.modules = modules
+
+    data, err := qrDeinterleave(q.readCodewords(), version, level)
+    if err != nil {
+        return "", 0, err
+    }
+    text, err := qrParseSegments(data, version)
+    return text, 0, err
+}
+
+// Reads the codewords back out of a symbol by undoing the mask and walking
+// the zigzag placement of drawCodewords.
+func (q *qrCode) readCodewords() []byte {
+    codewords := make([]byte, qrRawDataModules(q.version) / 8)
+    i := 0
+    for right := q.size - 1; right >= 1; right -= 2 {
+        if right == 6 {
+            right = 5
+        }
+        for vert := 0; vert < q.size; vert++ {
+            for j := 0; j < 2; j++ {
+                x, y := right - j, vert
+                if (right + 1) & 2 == 0 {
+                    y = q.size - 1 - vert
+                }
+                if !q.isFunction[y][x] && i < len(codewords) * 8 {
+                    if q.modules[y][x] != qrMaskBit(q.mask, x, y) {
+                        codewords[i>>3] |= 1 << uint(7 - i&7)
+                    }
+                    i++
+                }
+            }
+        }
+    }
+    return codewords
+}
+
+// Undoes qrInterleave, correcting each block, and returns the data
+// codewords.
+func qrDeinterleave(codewords []byte, version, level int) ([]byte, error) {
+    blocks := qrEccBlocks[level][version]
+    ecc_length := qrEccCodewordsPerBlock[level][version]
+    short_blocks := blocks - len(codewords) % blocks
+    short_length := len(codewords) / blocks
+
+    data_blocks := make([][]byte, blocks)
+    k := 0
+    for i := 0; i <= short_length - ecc_length; i++ {
+        for j := range(data_blocks) {
+            if i < short_length - ecc_length || j >= short_blocks {
+                data_blocks[j] = append(data_blocks[j], codewords[k])
+                k++
+            }
+        }
+    }
+    ecc_blocks := make([][]byte, blocks)
+    for i := 0; i < ecc_length; i++ {
+        for j := range(ecc_blocks) {
+            ecc_blocks[j] = append(ecc_blocks[j], codewords[k])
+            k++
+        }
+    }
+    res := []byte{}
+    for j, block := range(data_blocks) {
+        corrected, err := qrCorrectErrors(append(block, ecc_blocks[j]...), ecc_length)
+        if err != nil {
+            return nil, err
+        }
+        res = append(res, corrected[:len(block)]...)
+    }
+    return res, nil
+}
+
+// Exponent and logarithm tables of QR's GF(256), with generator 2.
+var qrExp, qrLog = func() ([512]byte, [256]int) {
+    var exp [512]byte
+    var log [256]int
+    x := 1
+    for i := 0; i < 255; i++ {
+        exp[i], exp[i+255] = byte(x), byte(x)
+        log[x] = i
+        x <<= 1
+        if x >= 256 {
+            x ^= QR_GF_POLYNOMIAL
+        }
+    }
+    return exp, log
+}()
+
+func qrDiv(a, b byte) byte {
+    if a == 0 {
+        return 0
+    }
+    return qrExp[qrLog[a] + 255 - qrLog[b]]
+}
+
+// Evaluates a polynomial with the constant coefficient first.
+func qrEvaluate(p []byte, x byte) byte {
+    y := byte(0)
+    for i := len(p) - 1; i >= 0; i-- {
+        y = qrMul(y, x) ^ p[i]
+    }
+    return y
+}
+
+// Corrects up to ecc_length/2 wrong bytes of a block, highest degree
+// coefficient first, with the Berlekamp-Massey algorithm, a Chien search
+// and Forney's formula.
+func qrCorrectErrors(block []byte, ecc_length int) ([]byte, error) {
+    n := len(block)
+    syndromes := make([]byte, ecc_length)
+    clean := true
+    for j := range(syndromes) {
+        // The block read as a polynomial at alpha^j.
+        for _, c := range(block) {
+            syndromes[j] = qrMul(syndromes[j], qrExp[j]) ^ c
+        }
+        clean = clean && syndromes[j] == 0
+    }
+    if clean {
+        return block, nil
+    }
+
+    // Berlekamp-Massey, finding the error locator.
+    locator, previous := []byte{1}, []byte{1}
+    errors, shift, last := 0, 1, byte(1)
+    for i := 0; i < ecc_length; i++ {
+        discrepancy := syndromes[i]
+        for j := 1; j <= errors && j < len(locator); j++ {
+            discrepancy ^= qrMul(locator[j], syndromes[i-j])
+        }
+        if discrepancy == 0 {
+            shift++
+            continue
+        }
+        factor := qrDiv(discrepancy, last)
+        next := append([]byte{}, locator...)
+        for len(next) < len(previous) + shift {
+            next = append(next, 0)
+        }
+        for j, c := range(previous) {
+            next[j+shift] ^= qrMul(factor, c)
+        }
+        if 2 * errors <= i {
+            errors, previous, last, shift = i + 1 - errors, locator, discrepancy, 1
+        } else {
+            shift++
+        }
+        locator = next
+    }
+    if 2 * errors > ecc_length {
+        return nil, fmt.Errorf("too many errors to correct")
+    }
+
+    // The error evaluator, syndromes times locator mod x^ecc_length, and the
+    // formal derivative of the locator.
+    evaluator := make([]byte, ecc_length)
+    for i := range(evaluator) {
+        for j := 0; j <= i && j < len(locator); j++ {
+            evaluator[i] ^= qrMul(locator[j], syndromes[i-j])
+        }
+    }
+    derivative := make([]byte, len(locator))
+    for i := 1; i < len(locator); i += 2 {
+        derivative[i-1] = locator[i]
+    }
+
+    res := append([]byte{}, block...)
+    found := 0
+    for i := 0; i < n; i++ {
+        power := n - 1 - i
+        inverse := qrExp[(255 - power % 255) % 255]
+        if qrEvaluate(locator, inverse) != 0 {
+            continue
+        }
+        res[i] ^= qrMul(qrExp[power % 255], qrDiv(qrEvaluate(evaluator, inverse), qrEvaluate(derivative, inverse)))
+        found++
+    }
+    if found != errors {
+        return nil, fmt.Errorf("too many errors to correct")
+    }
+    return res, nil
+}
+
+// A bit reader over the data codewords.
+type qrBitReader struct {
+    data []byte
+    pos  int
+}
+
+func (r *qrBitReader) available() int {
+    return len(r.data) * 8 - r.pos
+}
+
+func (r *qrBitReader) read(length int) int {
+    value := 0
+    for i := 0; i < length; i++ {
+        value = value << 1 | int(r.data[r.pos>>3] >> uint(7 - r.pos&7)) & 1
+        r.pos++
+    }
+    return value
+}
+
+// Reads the numeric, alphanumeric and byte segments of the data.
+func qrParseSegments(data []byte, version int) (string, error) {
+    r := &qrBitReader{data: data}
+    var text strings.Builder
+    for r.available() >= 4 {
+        mode := r.read(4)
+        switch mode {
+            case 0:
+                return text.String(), nil
+            case QR_MODE_NUMERIC, QR_MODE_ALPHANUMERIC, QR_MODE_BYTE:
+            case 0x7:
+                // An ECI designator; the bytes are passed through as is.
+                if r.available() < 8 {
+                    return "", fmt.Errorf("truncated segment")
+                }
+                first := r.read(8)
+                switch {
+                    case first & 0xc0 == 0x80:
+                        r.read(8)
+                    case first & 0xe0 == 0xc0:
+                        r.read(16)
+                }
+                continue
+            default:
+                return "", fmt.Errorf("unsupported segment mode %d", mode)
+        }
+        bits := qrCountBits(mode, version)
+        if r.available() < bits {
+            return "", fmt.Errorf("truncated segment")
+        }
+        count := r.read(bits)
+        switch mode {
+            case QR_MODE_NUMERIC:
+                for count > 0 {
+                    digits := qrMin(count, 3)
+                    if r.available() < digits * 3 + 1 {
+                        return "", fmt.Errorf("truncated segment")
+                    }
+                    fmt.Fprintf(&text, "%0*d", digits, r.read(digits * 3 + 1))
+                    count -= digits
+                }
+            case QR_MODE_ALPHANUMERIC:
+                for count > 0 {
+                    if count == 1 {
+                        if r.available() < 6 {
+                            return "", fmt.Errorf("truncated segment")
+                        }
+                        text.WriteByte(QR_ALPHANUMERIC_CHARSET[r.read(6) % 45])
+                        break
+                    }
+                    if r.available() < 11 {
+                        return "", fmt.Errorf("truncated segment")
+                    }
+                    value := r.read(11)
+                    if value >= 45 * 45 {
+                        return "", fmt.Errorf("bad alphanumeric data")
+                    }
+                    text.WriteByte(QR_ALPHANUMERIC_CHARSET[value / 45])
+                    text.WriteByte(QR_ALPHANUMERIC_CHARSET[value % 45])
+                    count -= 2
+                }
+            default:
+                if r.available() < count * 8 {
+                    return "", fmt.Errorf("truncated segment")
+                }
+                for ; count > 0; count-- {
+                    text.WriteByte(byte(r.read(8)))
+                }
+        }
+    }
+    return text.String(), nil
+}
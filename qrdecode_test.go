@@ -0,0 +1,161 @@
+package main
+
+import (
+    "bytes"
+    "errors"
+    "image"
+    "image/color"
+    "image/jpeg"
+    "math"
+    "strings"
+    "testing"
+)
+
+// Draws a symbol into a width by width image, taking each pixel's colour
+// from the module that project maps it to. Modules outside the symbol are
+// white.
+func qrTestImage(q *qrCode, width int, project func(x, y float64) (float64, float64)) *image.Gray {
+    img := image.NewGray(image.Rect(0, 0, width, width))
+    for y := 0; y < width; y++ {
+        for x := 0; x < width; x++ {
+            u, v := project(float64(x) + 0.5, float64(y) + 0.5)
+            c := color.Gray{235}
+            if q.dark(int(math.Floor(u)), int(math.Floor(v))) {
+                c = color.Gray{30}
+            }
+            img.SetGray(x, y, c)
+        }
+    }
+    return img
+}
+
+// Scales the symbol to fill most of the image, turned by angle radians
+// about the centre.
+func qrRotated(q *qrCode, width int, angle float64) *image.Gray {
+    scale := float64(q.size + 2 * QR_QUIET_ZONE) / float64(width)
+    c := float64(width) / 2
+    return qrTestImage(q, width, func(x, y float64) (float64, float64) {
+        dx, dy := x - c, y - c
+        u := (dx * math.Cos(angle) + dy * math.Sin(angle)) * scale
+        v := (-dx * math.Sin(angle) + dy * math.Cos(angle)) * scale
+        return u + float64(q.size) / 2, v + float64(q.size) / 2
+    })
+}
+
+func TestQRCorrectErrors(t *testing.T) {
+    data := []byte{0x20, 0x5b, 0x0b, 0x78, 0xd1, 0x72, 0xdc, 0x4d, 0x43, 0x40, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11}
+    block := append(append([]byte{}, data...), 196, 35, 39, 119, 235, 215, 231, 226, 93, 23)
+    for errors := 0; errors <= 5; errors++ {
+        damaged := append([]byte{}, block...)
+        for i := 0; i < errors; i++ {
+            damaged[i*5+1] ^= byte(0x5a + i)
+        }
+        corrected, err := qrCorrectErrors(damaged, 10)
+        if err != nil || !bytes.Equal(corrected, block) {
+            t.Errorf("Expecting %x with %d errors, got: %x (%v)", block, errors, corrected, err)
+        }
+    }
+    damaged := append([]byte{}, block...)
+    for i := 0; i < 6; i++ {
+        damaged[i*4] ^= 0xff
+    }
+    if corrected, err := qrCorrectErrors(damaged, 10); err == nil && bytes.Equal(corrected, block) {
+        t.Errorf("Six errors cannot be corrected with ten codewords")
+    }
+}
+
+func TestQRRoundTrip(t *testing.T) {
+    texts := []string{
+        "HELLO WORLD",
+        "31415926535897932384626433832795",
+        "shamir1:0123456789abcdef:5:3:2:108536187998490905038750291235303291658:",
+        // Version 7 and up carry version information and several alignment
+        // patterns.
+        "UR:SSKR/" + strings.Repeat("GOTDIEAEADAEZEUYPDVSJYVYMEJTGMZTHKHPNSGSURDWFWESCAPS", 4),
+        strings.Repeat("duckling enlarge academic academic agency result length solution ", 6),
+    }
+    for _, text := range(texts) {
+        q, err := encodeQR(text)
+        if err != nil {
+            t.Fatal(err)
+        }
+        for _, scale := range([]int{2, 3, 7}) {
+            width := (q.size + 2 * QR_QUIET_ZONE) * scale
+            decoded, err := decodeQRImage(qrRotated(q, width, 0))
+            if err != nil || decoded != text {
+                t.Errorf("Expecting %q from version %d at scale %d, got: %q (%v)", text, q.version, scale, decoded, err)
+            }
+        }
+    }
+}
+
+func TestQRDecodeDistorted(t *testing.T) {
+    text := "shamir1:9f2c4e11d0a7b355:5:3:4:155467380534751068895930468380382435415:"
+    q, _ := encodeQR(text)
+    images := map[string]image.Image{
+        "quarter turn": qrRotated(q, 300, math.Pi / 2),
+        "upside down": qrRotated(q, 300, math.Pi),
+        "tilted": qrRotated(q, 400, 0.3),
+        "odd scale": qrRotated(q, 173, 0),
+    }
+
+    // Viewed at an angle: the far edge is shorter than the near one.
+    width := 400
+    images["perspective"] = qrTestImage(q, width, func(x, y float64) (float64, float64) {
+        from := [4]qrPoint{{60, 40}, {350, 70}, {330, 330}, {40, 370}}
+        to := [4]qrPoint{{0, 0}, {float64(q.size), 0}, {float64(q.size), float64(q.size)}, {0, float64(q.size)}}
+        p := qrQuadToQuad(from, to).apply(x, y)
+        return p.x, p.y
+    })
+
+    var b bytes.Buffer
+    jpeg.Encode(&b, qrRotated(q, 250, 0.1), &jpeg.Options{Quality: 60})
+    images["jpeg"], _ = jpeg.Decode(&b)
+
+    // Light on dark, as drawn in a terminal.
+    inverted := qrRotated(q, 250, 0)
+    for i := range(inverted.Pix) {
+        inverted.Pix[i] = 255 - inverted.Pix[i]
+    }
+    images["inverted"] = inverted
+
+    for name, img := range(images) {
+        decoded, err := decodeQRImage(img)
+        if err != nil || decoded != text {
+            t.Errorf("Expecting %q from the %s image, got: %q (%v)", text, name, decoded, err)
+        }
+    }
+}
+
+func TestQRDecodeDamaged(t *testing.T) {
+    text := "UR:SSKR/GOTDIEAEADAEZEUYPDVSJYVYMEJTGMZTHKHPNSGSURDWFWESCAPS"
+    q, _ := encodeQR(text)
+    // Flip a stripe of data modules, as a crease or stain might.
+    for x := 10; x < 16; x++ {
+        for y := 11; y < 14; y++ {
+            if !q.isFunction[y][x] {
+                q.modules[y][x] = !q.modules[y][x]
+            }
+        }
+    }
+    decoded, err := decodeQRImage(qrRotated(q, 200, 0))
+    if err != nil || decoded != text {
+        t.Errorf("Expecting %q, got: %q (%v)", text, decoded, err)
+    }
+
+    blank := image.NewGray(image.Rect(0, 0, 100, 100))
+    if _, err := decodeQRImage(blank); !errors.Is(err, ErrMalformedShare) {
+        t.Errorf("Expecting malformed share error, got: %v", err)
+    }
+}
+
+func TestQRQuadToQuad(t *testing.T) {
+    from := [4]qrPoint{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+    to := [4]qrPoint{{5, 7}, {40, 3}, {44, 41}, {2, 30}}
+    transform := qrQuadToQuad(from, to)
+    for i := range(from) {
+        if p := transform.apply(from[i].x, from[i].y); qrDistance(p, to[i]) > 1e-9 {
+            t.Errorf("Expecting %v, got: %v", to[i], p)
+        }
+    }
+}
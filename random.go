@@ -0,0 +1,71 @@
+package main
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/sha256"
+    "fmt"
+    "io"
+    "math/big"
+)
+
+// Where split gets its randomness from. Everything random in a native split,
+// the polynomials, random x-coordinates and the split ID, is read from an
+// io.Reader: crypto/rand.Reader normally, or a stream derived from a seed in
+// split's insecure -seed mode, so that known-answer test vectors can be
+// generated and checked.
+
+// Marks the seeded stream, so that it can be changed without old test
+// vectors silently meaning something else.
+const SEED_DOMAIN = "shamir-insecure-seed-v1\n"
+
+// A deterministic stream of bytes derived from seed: the AES-256-CTR
+// keystream, with a zero IV, under the SHA-256 of the seed. Anyone who knows
+// or guesses the seed can recompute every share, so this is for test
+// vectors only, never for real secrets.
+func newSeededReader(seed string) io.Reader {
+    key := sha256.Sum256([]byte(SEED_DOMAIN + seed))
+    block, _ := aes.NewCipher(key[:])
+    return cipher.StreamReader{S: cipher.NewCTR(block, make([]byte, aes.BlockSize)), R: zeroReader{}}
+}
+
+// An endless stream of zero bytes, which CTR mode turns into its keystream.
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+    for i := range(b) {
+        b[i] = 0
+    }
+    return len(b), nil
+}
+
+// Reads n bytes from random.
+func readRandom(random io.Reader, n int) ([]byte, error) {
+    b := make([]byte, n)
+    if _, err := io.ReadFull(random, b); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrRandomness, err)
+    }
+    return b, nil
+}
+
+// Draws an element of field uniformly at random by rejection sampling: read
+// as many bytes as an element takes, clear the bits above the top bit of the
+// largest element, and try again if the result is still not an element. Each
+// try succeeds with probability over 1/2, and every element is equally
+// likely, whatever the size of the field. Unlike rand.Int, the bytes read
+// for a given result are fixed, so seeded splits stay reproducible. The
+// bytes go straight into limbs through Decode, which also makes the check.
+func randomElement(field Field, random io.Reader) (Element, error) {
+    length := elementLength(field)
+    excess := uint(8 * length - new(big.Int).Sub(field.Size(), big.NewInt(1)).BitLen())
+    for {
+        b, err := readRandom(random, length)
+        if err != nil {
+            return nil, err
+        }
+        b[0] &= byte(0xff >> excess)
+        if x, err := field.Decode(b); err == nil {
+            return x, nil
+        }
+    }
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "math/big"
+    "strings"
+    "testing"
+)
+
+func TestSeededReader(t *testing.T) {
+    // The AES-256-CTR keystream under SHA-256("shamir-insecure-seed-v1\ntest"),
+    // as computed by openssl enc -aes-256-ctr. Test vectors made with -seed
+    // depend on it staying the same.
+    b, _ := readRandom(newSeededReader("test"), 16)
+    if hex.EncodeToString(b) != "e46c42f37a943aef1684c49f53e83228" {
+        t.Errorf("Expecting the pinned stream for seed \"test\", got: %x", b)
+    }
+    // Reading in pieces gives the same stream.
+    r := newSeededReader("test")
+    first, _ := readRandom(r, 5)
+    rest, _ := readRandom(r, 11)
+    if !bytes.Equal(append(first, rest...), b) {
+        t.Errorf("Expecting %x read in pieces, got: %x%x", b, first, rest)
+    }
+    other, _ := readRandom(newSeededReader("test2"), 16)
+    if bytes.Equal(other, b) {
+        t.Error("Expecting different seeds to give different streams")
+    }
+}
+
+func TestRandomElement(t *testing.T) {
+    // 0xff and 0xfb are not elements mod 251 and are redrawn.
+    field := newPrimeField("251", big.NewInt(251))
+    x, err := randomElement(field, bytes.NewReader([]byte{0xff, 0xfb, 0x05, 0x07}))
+    if err != nil || field.ToBig(x).Int64() != 5 {
+        t.Errorf("Expecting 5, got: %v, %v", x, err)
+    }
+    // The top bit of 2^127 - 1 is cleared, leaving p itself, which is
+    // redrawn.
+    p127, _ := lookupField(DEFAULT_FIELD)
+    stream := append(bytes.Repeat([]byte{0xff}, 16), append([]byte{0x80}, make([]byte, 14)...)...)
+    x, err = randomElement(p127, bytes.NewReader(append(stream, 0x01)))
+    if err != nil || p127.ToBig(x).Int64() != 1 {
+        t.Errorf("Expecting 1, got: %v, %v", x, err)
+    }
+    if _, err := randomElement(p127, bytes.NewReader(make([]byte, 15))); !errors.Is(err, ErrRandomness) {
+        t.Errorf("Expecting ErrRandomness from a short source, got: %v", err)
+    }
+    if _, err := generateRandomPolynomial(p127.One(), p127, 2, bytes.NewReader(nil)); !errors.Is(err, ErrRandomness) {
+        t.Errorf("Expecting ErrRandomness from an empty source, got: %v", err)
+    }
+
+    // Mod 5, three bits are read and 5, 6 and 7 redrawn, so each element
+    // should come up about a fifth of the time.
+    field = newPrimeField("5", big.NewInt(5))
+    counts := make([]int, 5)
+    for i := 0; i < 10000; i++ {
+        x, err := field.Random(rand.Reader)
+        if err != nil {
+            t.Fatal(err)
+        }
+        counts[field.ToBig(x).Int64()]++
+    }
+    for x, count := range(counts) {
+        if count < 1700 || count > 2300 {
+            t.Errorf("Expecting %d about 2000 times in 10000, got: %d", x, count)
+        }
+    }
+}
+
+func TestSeededSplit(t *testing.T) {
+    secret := strings.Repeat("Hello, World! This is my secret.", 20)
+    for _, field := range(fields) {
+        shares, err := splitSecretAt(context.Background(), secret, sequentialXCoordinates(4), 3, field, 1, newSeededReader("vectors"))
+        if err != nil {
+            t.Fatalf("%s: %v", field.Name(), err)
+        }
+        again, _ := splitSecretAt(context.Background(), secret, sequentialXCoordinates(4), 3, field, 4, newSeededReader("vectors"))
+        if strings.Join(again, " ") != strings.Join(shares, " ") {
+            t.Errorf("%s: expecting the same seed to give the same shares with any number of workers", field.Name())
+        }
+        other, _ := splitSecretAt(context.Background(), secret, sequentialXCoordinates(4), 3, field, 1, newSeededReader("other"))
+        if strings.Join(other, " ") == strings.Join(shares, " ") {
+            t.Errorf("%s: expecting different seeds to give different shares", field.Name())
+        }
+        recovered, err := combineShares([]string{"1", shares[0], "3", shares[2], "4", shares[3]}, field)
+        if err != nil || recovered != secret {
+            t.Errorf("%s: expecting the secret back from seeded shares, got: %q, %v", field.Name(), recovered, err)
+        }
+    }
+}
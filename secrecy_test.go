@@ -0,0 +1,211 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "math/big"
+    "testing"
+)
+
+// Evidence that fewer than t shares say nothing about the secret, run with
+// go test -run Secrecy. In fields small enough to count in, the shares a
+// holder of t - 1 of them sees are tabulated for several secrets: exhaustively
+// over every polynomial, which checks the arithmetic, and by sampling
+// generateRandomPolynomial, which checks that it draws every polynomial
+// equally often. The same harness run on a generator that never gives a zero
+// leading coefficient, as generateRandomPolynomial once did, has to catch it.
+
+// Draws the random part of a polynomial with the given constant.
+type polynomialGenerator func(constant Element, field Field, degree int, random io.Reader) (polynomial, error)
+
+// generateRandomPolynomial as it was, redrawing a zero leading coefficient.
+func generateNonZeroLeadingPolynomial(constant Element, field Field, degree int, random io.Reader) (polynomial, error) {
+    for {
+        poly, err := generateRandomPolynomial(constant, field, degree, random)
+        if err != nil || field.ToBig(poly.coefficients[degree]).Sign() != 0 {
+            return poly, err
+        }
+    }
+}
+
+// A case of the harness: t - 1 shares at xs of a split with threshold t over
+// a small field.
+type secrecyCase struct {
+    field Field
+    t     int
+    xs    []*big.Int
+}
+
+func (c secrecyCase) String() string {
+    return fmt.Sprintf("%s, t = %d", c.field.Name(), c.t)
+}
+
+// The number of different sets of t - 1 shares.
+func (c secrecyCase) cells() int {
+    return int(math.Pow(float64(c.field.Size().Int64()), float64(len(c.xs))))
+}
+
+// Numbers the shares of a polynomial at xs from 0 to cells() - 1.
+func (c secrecyCase) cell(poly polynomial) int {
+    res := 0
+    for _, y := range(c.field.Evaluate(poly.coefficients, elements(c.field, c.xs))) {
+        res = res * int(c.field.Size().Int64()) + int(c.field.ToBig(y).Int64())
+    }
+    return res
+}
+
+// The secrets tabulated: 0, which a polynomial bug is most likely to treat
+// differently, 1 and the largest element.
+func (c secrecyCase) secrets() []*big.Int {
+    return []*big.Int{big.NewInt(0), big.NewInt(1), new(big.Int).Sub(c.field.Size(), big.NewInt(1))}
+}
+
+var secrecyCases = func() []secrecyCase {
+    gf256, _ := lookupField("gf256")
+    return []secrecyCase{
+        {gf256, 2, []*big.Int{big.NewInt(7)}},
+        {newPrimeField("7", big.NewInt(7)), 2, []*big.Int{big.NewInt(3)}},
+        {newPrimeField("7", big.NewInt(7)), 3, []*big.Int{big.NewInt(1), big.NewInt(5)}},
+        {newPrimeField("5", big.NewInt(5)), 4, []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(4)}},
+    }
+}()
+
+// Counts the shares of every polynomial with each secret as its constant:
+// each set of t - 1 shares has to come from exactly one polynomial of the
+// size^(t - 1) there are, whatever the secret.
+func TestSecrecyExhaustive(t *testing.T) {
+    for _, c := range(secrecyCases) {
+        size := c.field.Size().Int64()
+        for _, secret := range(c.secrets()) {
+            counts := make([]int, c.cells())
+            poly := polynomial{make([]Element, c.t)}
+            poly.coefficients[0] = c.field.FromBig(secret)
+            for i := 0; i < c.cells(); i++ {
+                // The digits of i in base size are the other coefficients.
+                for j, rest := 1, int64(i); j < c.t; j, rest = j + 1, rest / size {
+                    poly.coefficients[j] = c.field.FromBig(big.NewInt(rest % size))
+                }
+                counts[c.cell(poly)]++
+            }
+            for cell, count := range(counts) {
+                if count != 1 {
+                    t.Errorf("%s: shares %d come from %d polynomials with secret %s, expecting 1", c, cell, count, secret)
+                    break
+                }
+            }
+        }
+    }
+}
+
+// Tabulates the shares of samples splits of each secret, with the
+// polynomials drawn by generate from a seeded stream.
+func secrecyCounts(c secrecyCase, generate polynomialGenerator, samples int) ([][]int, error) {
+    random := newSeededReader("secrecy " + c.String())
+    counts := [][]int{}
+    for _, secret := range(c.secrets()) {
+        row := make([]int, c.cells())
+        for i := 0; i < samples; i++ {
+            poly, err := generate(c.field.FromBig(secret), c.field, c.t - 1, random)
+            if err != nil {
+                return nil, err
+            }
+            row[c.cell(poly)]++
+        }
+        counts = append(counts, row)
+    }
+    return counts, nil
+}
+
+// A bound far out in the upper tail of the chi-squared distribution with df
+// degrees of freedom, about six standard deviations above its mean, so that
+// a test against it all but never fails by chance.
+func chiSquaredLimit(df int) float64 {
+    return float64(df) + 6 * math.Sqrt(2 * float64(df))
+}
+
+// Pearson's chi-squared statistic for counts against the uniform
+// distribution.
+func chiSquaredUniform(counts []int) float64 {
+    total := 0
+    for _, count := range(counts) {
+        total += count
+    }
+    expected := float64(total) / float64(len(counts))
+    chi2 := 0.0
+    for _, count := range(counts) {
+        d := float64(count) - expected
+        chi2 += d * d / expected
+    }
+    return chi2
+}
+
+// The chi-squared statistic for rows of counts all coming from the same
+// distribution, with its degrees of freedom.
+func chiSquaredHomogeneity(rows [][]int) (float64, int) {
+    total := 0
+    column_totals := make([]int, len(rows[0]))
+    row_totals := make([]int, len(rows))
+    for i, row := range(rows) {
+        for j, count := range(row) {
+            row_totals[i] += count
+            column_totals[j] += count
+            total += count
+        }
+    }
+    chi2 := 0.0
+    for i, row := range(rows) {
+        for j, count := range(row) {
+            expected := float64(row_totals[i]) * float64(column_totals[j]) / float64(total)
+            if expected > 0 {
+                d := float64(count) - expected
+                chi2 += d * d / expected
+            }
+        }
+    }
+    return chi2, (len(rows) - 1) * (len(rows[0]) - 1)
+}
+
+// Checks tabulated shares: uniform for every secret, and distributed the
+// same for all of them. Returns a description of the first failure.
+func checkSecrecy(c secrecyCase, counts [][]int) error {
+    for i, row := range(counts) {
+        if chi2, limit := chiSquaredUniform(row), chiSquaredLimit(c.cells() - 1); chi2 > limit {
+            return fmt.Errorf("%s: shares of secret %s are not uniform, chi-squared %.0f > %.0f", c, c.secrets()[i], chi2, limit)
+        }
+    }
+    if chi2, df := chiSquaredHomogeneity(counts); chi2 > chiSquaredLimit(df) {
+        return fmt.Errorf("%s: shares depend on the secret, chi-squared %.0f > %.0f", c, chi2, chiSquaredLimit(df))
+    }
+    return nil
+}
+
+// About 400 samples for each set of shares of each secret.
+const SECRECY_SAMPLES_PER_CELL = 400
+
+func TestSecrecyStatistical(t *testing.T) {
+    for _, c := range(secrecyCases) {
+        counts, err := secrecyCounts(c, generateRandomPolynomial, SECRECY_SAMPLES_PER_CELL * c.cells())
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err := checkSecrecy(c, counts); err != nil {
+            t.Error(err)
+        }
+    }
+}
+
+// Never drawing a zero leading coefficient means t - 1 shares rule out some
+// values of the secret: with t = 2, a share at x is never the secret itself.
+// The harness has to notice.
+func TestSecrecyDetectsBiasedPolynomials(t *testing.T) {
+    for _, c := range(secrecyCases) {
+        counts, err := secrecyCounts(c, generateNonZeroLeadingPolynomial, SECRECY_SAMPLES_PER_CELL * c.cells())
+        if err != nil {
+            t.Fatal(err)
+        }
+        if checkSecrecy(c, counts) == nil {
+            t.Errorf("%s: expecting polynomials without a zero leading coefficient to be caught", c)
+        }
+    }
+}
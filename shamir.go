@@ -1,174 +1,326 @@
 package main
 
 import (
+    "bytes"
+    "context"
     "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "crypto/ed25519"
     "crypto/rand"
     "flag"
     "fmt"
+    "image"
+    "io"
     "math/big"
     "os"
+    "os/signal"
+    "path/filepath"
     "strconv"
     "strings"
     "regexp"
+    "time"
     "unicode"
 )
 
-// 2^127 - 1.
+// 2^127 - 1, the prime of the default field. Large secrets are split into
+// subsecrets of 15 bytes, the chunk size of this field, to avoid wrapping
+// around the modulus after encoding them as field elements.
 const PRIME = "170141183460469231731687303715884105727"
 
-// Split large secrets into smaller subsecrets to avoid wrapping around the
-// prime modulus after encoding them as bigInts. Given we only accept ASCII
-// secrets, splitting into chunks of size 15 is sufficent to never be larger
-// than the prime.
-const CHUNK_SIZE = 15
+// Names of the sharing schemes split and combine support. The native scheme
+// is this tool's own; the others produce and read shares other tools use.
+const (
+    NATIVE_SCHEME = "native"
+    SLIP39_SCHEME = "slip39"
+    VAULT_SCHEME = "vault"
+    SSSS_SCHEME = "ssss"
+    SSKR_SCHEME = "sskr"
+)
 
-// A polynomial is a slice of big.Ints. polynomial[i] is the x^i coefficient.
-// E.g. 7x^2 + 5 is [5, 0, 7].
-type polynomial struct {
-	coefficients []*big.Int
-}
+var schemeNames = []string{NATIVE_SCHEME, SLIP39_SCHEME, VAULT_SCHEME, SSSS_SCHEME, SSKR_SCHEME}
 
-// When we split up a secret into subsecrets, we parraleize the SSS computation
-// on each. Since we do not know what order they will finish, we need an id
-// identifying the subsecret that has been split up. As the goroutines finish,
-// we insert the shares into the final result in the correct order.
-type splitPair struct {
-    shares []*big.Int
-    id int
+// The split and combine flags that only some schemes take, and which.
+var schemeFlags = map[string][]string{
+    "dealer-key":         {NATIVE_SCHEME},
+    "trusted-key":        {NATIVE_SCHEME},
+    "x-coordinates":      {NATIVE_SCHEME},
+    "pad":                {NATIVE_SCHEME},
+    "seed":               {NATIVE_SCHEME},
+    "field":              {NATIVE_SCHEME},
+    "workers":            {NATIVE_SCHEME},
+    "encoding":           {NATIVE_SCHEME, VAULT_SCHEME, SSKR_SCHEME},
+    "secret-hex":         {SLIP39_SCHEME, VAULT_SCHEME, SSSS_SCHEME, SSKR_SCHEME},
+    "groups":             {SLIP39_SCHEME, SSKR_SCHEME},
+    "group-threshold":    {SLIP39_SCHEME, SSKR_SCHEME},
+    "iteration-exponent": {SLIP39_SCHEME},
+    "passphrase":         {SLIP39_SCHEME},
+    "security":           {SSSS_SCHEME},
+    "diffusion":          {SSSS_SCHEME},
+    "token":              {SSSS_SCHEME},
+    "threshold":          {SSSS_SCHEME},
 }
 
-// Same as splitPair, but for when we combine a subsecret back together.
-type combinePair struct {
-    subsecret string
-    id int
+// A polynomial is a slice of field elements. polynomial[i] is the x^i
+// coefficient. E.g. 7x^2 + 5 is [5, 0, 7].
+type polynomial struct {
+	coefficients []Element
 }
 
-// The (x, y) coordinates from our polynomials.
+// The (x, y) coordinates from our polynomials. The x-coordinate is public,
+// the y-coordinate is part of a share and stays an element.
 type xyPair struct {
-    x int
-    y *big.Int
+    x *big.Int
+    y Element
 }
 
-// Generates a random polynomial with specified constant, degree and modulus
+// Generates a random polynomial with specified constant, degree and field
 // For Shamir Secret Sharing:
 // constant = secret to split up
 // degree of polynomial = threshold - 1
-// modulus = PRIME for the galois field arithmetic
-func generateRandomPolynomial(constant, modulus *big.Int, degree int) polynomial {
+// field = the field the secret is split over, by default 2^127 - 1
+// random = where the coefficients are read from, normally crypto/rand.Reader
+func generateRandomPolynomial(constant Element, field Field, degree int, random io.Reader) (polynomial, error) {
 
     // Start with the (pre-selected) constant term of the polynomial
-    coefficients := []*big.Int{constant}
+    coefficients := []Element{constant}
 
-    // Randomly select all the middle terms
-	for i := 1; i < degree; i++ {
-        num, err := rand.Int(rand.Reader, modulus)
+    // Randomly select all the other terms. The final term may be zero: fewer
+    // than degree + 1 shares only reveal nothing about the constant if every
+    // coefficient is uniform, and redrawing a zero would take longer for some
+    // polynomials than for others.
+	for i := 1; i <= degree; i++ {
+        num, err := field.Random(random)
         if err != nil {
-            panic(err)
+            return polynomial{}, err
         }
         coefficients = append(coefficients, num)
     }
+    return polynomial{coefficients}, nil
+}
 
-    // Randomly select the final term and ensure it is non-zero so it is really
-    // a polynomial of the nth degree.
-    for {
-        num, err := rand.Int(rand.Reader, modulus)
+// Evaluates galois polynomial at x using Horner's method.
+func evaluatePolynomial(x *big.Int, field Field, p polynomial) Element {
+    return field.Evaluate(p.coefficients, []Element{field.FromBig(x)})[0]
+}
+
+// The x-coordinates 1..n that shares are given by default.
+func sequentialXCoordinates(n int) []*big.Int {
+    xs := make([]*big.Int, n, n)
+    for i := range(xs) {
+        xs[i] = big.NewInt(int64(i + 1))
+    }
+    return xs
+}
+
+// Draws n distinct non-zero x-coordinates uniformly at random from the field,
+// so that a share's x-coordinate says nothing about how many shares there
+// are.
+func randomFieldXCoordinates(n int, field Field, random io.Reader) ([]*big.Int, error) {
+    if err := checkShareCount(n, field); err != nil {
+        return nil, err
+    }
+    xs := []*big.Int{}
+    seen := make(map[string]bool)
+    for len(xs) < n {
+        element, err := field.Random(random)
         if err != nil {
-            panic(err)
+            return nil, err
+        }
+        x := field.ToBig(element)
+        if x.Sign() == 0 || seen[x.String()] {
+            continue
         }
+        seen[x.String()] = true
+        xs = append(xs, x)
+    }
+    return xs, nil
+}
 
-        if num.Cmp(big.NewInt(0)) != 0 {
-            coefficients = append(coefficients, num)
-            break
+// Parses the x-coordinates given to split -x-coordinates: either "random" or
+// n comma separated numbers, which must be distinct non-zero elements of the
+// field. Random x-coordinates are read from random.
+// E.g. "17,4242,99" for three shares.
+func parseXCoordinates(s string, n int, field Field, random io.Reader) ([]*big.Int, error) {
+    if s == "random" {
+        return randomFieldXCoordinates(n, field, random)
+    }
+    xs := []*big.Int{}
+    seen := make(map[string]bool)
+    for _, number := range(strings.Split(s, ",")) {
+        x, err := parseShareNumber(strings.TrimSpace(number), field.Size())
+        if err != nil {
+            return nil, newError(ErrInvalidParameters, fmt.Sprintf("Bad x-coordinate %q: %s", number, err))
+        }
+        if seen[x.String()] {
+            return nil, newError(ErrInvalidParameters, fmt.Sprintf("x-coordinate %s given more than once.", x))
         }
+        seen[x.String()] = true
+        xs = append(xs, x)
+    }
+    if len(xs) != n {
+        return nil, newError(ErrInvalidParameters, fmt.Sprintf("Expecting %d x-coordinates, one for each share, got %d.", n, len(xs)))
     }
-    return polynomial{coefficients}
+    return xs, nil
 }
 
-// Evaluates galois polynomial at x using Horner's method.
-func evaluatePolynomial(x, modulus *big.Int, p polynomial) *big.Int {
-    degree := len(p.coefficients) - 1
-    result := new(big.Int).Set(p.coefficients[degree])
-    for i := degree - 1; i >= 0; i-- {
-        result.Mul(result, x)
-        result.Add(result, p.coefficients[i])
+// Used for testing and in the call to shamirSplitSecret. Not secure to call
+// directly unless the polynomial is generated with generateRandomPolynomial.
+// Share i is the polynomial evaluated at xs[i].
+func _shamirSplitSecretWithFixedPolynomial(secret Element, field Field, poly polynomial, xs []*big.Int, t int) []Element {
+    points := make([]Element, len(xs), len(xs))
+    for i, x := range(xs) {
+        points[i] = field.FromBig(x)
     }
-    return result.Mod(result, modulus)
+    return field.Evaluate(poly.coefficients, points)
 }
 
-func evaluatePolynomialThroughChannel(c chan xyPair, chan_id int, modulus *big.Int, p polynomial) {
-    x := big.NewInt(int64(chan_id))
-    y := evaluatePolynomial(x, modulus, p)
-    c <- xyPair{chan_id, y}
+// Shamir Secret Sharing splitting secret into a share at each of xs with
+// threshold t to recover the secret, with the polynomial read from random.
+func shamirSplitSecret(secret Element, field Field, xs []*big.Int, t int, random io.Reader) ([]Element, error) {
+    poly, err := generateRandomPolynomial(secret, field, t - 1, random)
+    if err != nil {
+        return nil, err
+    }
+    return _shamirSplitSecretWithFixedPolynomial(secret, field, poly, xs, t), nil
 }
 
-// Used for testing and in the call to shamirSplitSecret. Not secure to call
-// directly unless the polynomial is generated with generateRandomPolynomial.
-func _shamirSplitSecretWithFixedPolynomial(secret, modulus *big.Int, poly polynomial, n, t int) []*big.Int {
-    shares := make([]*big.Int, n, n)
-    c := make(chan xyPair)
-    for x := 1; x <= n; x++ {
-        go evaluatePolynomialThroughChannel(c, x, modulus, poly)
+// Inverts every value in the field with a single Inv (Montgomery's trick):
+// the inverse of the product of all the values is multiplied back up by the
+// prefix products. Returns nil if any value is not invertible.
+// E.g. for [a, b, c]: inv = 1/abc, then 1/c = ab * inv, 1/b = a * (c * inv)
+// and 1/a = bc * inv.
+func batchInverse(values []Element, field Field) []Element {
+    if len(values) == 0 {
+        return []Element{}
+    }
+    // prefix[i] is the product of values[0..i).
+    prefix := make([]Element, len(values) + 1, len(values) + 1)
+    prefix[0] = field.One()
+    for i, v := range(values) {
+        prefix[i+1] = field.Mul(prefix[i], v)
+    }
+    inv := field.Inv(prefix[len(values)])
+    if inv == nil {
+        return nil
+    }
+    res := make([]Element, len(values), len(values))
+    for i := len(values) - 1; i >= 0; i-- {
+        res[i] = field.Mul(prefix[i], inv)
+        inv = field.Mul(inv, values[i])
     }
+    return res
+}
 
-    for count := 0; count < n; count++ {
-        output := <-c
-        shares[output.x - 1] = output.y
+// Calculates the Lagrange basis weights at zero for the x-coordinates xs:
+// weight i is the product over j != i of x_j / (x_j - x_i) in the field, so
+// that f(0) is the sum of weight i * y_i. The weights only depend on which
+// shares are combined, so they are worked out once and used for every
+// subsecret. Returns nil if two of the x-coordinates are the same point.
+func lagrangeWeights(xs []*big.Int, field Field) []Element {
+    points := make([]Element, len(xs), len(xs))
+    for i, x := range(xs) {
+        points[i] = field.FromBig(x)
+    }
+    numerators := make([]Element, len(xs), len(xs))
+    denominators := make([]Element, len(xs), len(xs))
+    for i, x := range(points) {
+        numerators[i] = field.One()
+        denominators[i] = field.One()
+        for j, m := range(points) {
+            if j == i {
+                continue
+            }
+            numerators[i] = field.Mul(numerators[i], m)
+            denominators[i] = field.Mul(denominators[i], field.Sub(m, x))
         }
-
-    return shares
+    }
+    inverses := batchInverse(denominators, field)
+    if inverses == nil {
+        return nil
+    }
+    for i := range(numerators) {
+        numerators[i] = field.Mul(numerators[i], inverses[i])
+    }
+    return numerators
 }
 
-// Shamir Secret Sharing splitting secret into n shares with threshold t to
-// recover the secret.
-func shamirSplitSecret(secret, modulus *big.Int, n, t int) []*big.Int {
-    poly := generateRandomPolynomial(secret, modulus, t - 1)
-    return _shamirSplitSecretWithFixedPolynomial(secret, modulus, poly, n, t)
+// Calculates f(0) from the y values of the points whose x-coordinates the
+// weights were made for, as the dot product of the two.
+func lagrangeWithWeights(ys []Element, weights []Element, field Field) Element {
+    return field.Dot(ys, weights)
 }
 
-// Calculates f(0) (mod m) given len(points) == threshhold
+// Calculates f(0) in the field given len(points) == threshhold
 // Points are the secret shares (x1, y1), (x2, y2), etc. on the polynomial.
-func lagrange(points map[int]big.Int, modulus *big.Int) *big.Int {
-    result := big.NewInt(0)
+func lagrange(points []xyPair, field Field) Element {
+    xs := make([]*big.Int, len(points), len(points))
+    ys := make([]Element, len(points), len(points))
+    for i, p := range(points) {
+        xs[i], ys[i] = p.x, p.y
+    }
+    return lagrangeWithWeights(ys, lagrangeWeights(xs, field), field)
+}
 
-    // This part is the outer sum of the Lagrange formula. At each iteration, it
-    // adds the y * product term. The product is calculated in the inner loop.
-    for x, y := range points {
+// Reversibly encodes a string of at most the field's chunk size into an
+// element, as big-endian bytes.
+func stringToElement(s string, field Field) Element {
+    b := make([]byte, elementLength(field))
+    copy(b[len(b) - len(s):], s)
+    a, _ := field.Decode(b)
+    return a
+}
 
-        // Calculate the product to multiply against the y term.
-        prod := big.NewInt(1)
-        for m, _ := range points {
-            if m == x {
-                continue
-            }
-            d := new(big.Int).Sub(big.NewInt(int64(m)), big.NewInt(int64(x)))
-            d.ModInverse(d, modulus)
-            d.Mul(big.NewInt(int64(m)), d)
-            prod.Mul(prod, d)
-            prod.Mod(prod, modulus)
-        }
+// Reversibly decodes an element into a string, without the NUL bytes it
+// starts with.
+func elementToString(a Element, field Field) string {
+    return string(elementBytes(field, a, 0))
+}
 
-        // Multiply the product by y and then add to the sum total.
-        // Repeat until the sum is complete.
-        term := new(big.Int).Mul(&y, prod)
-        result = result.Add(result, term)
-    }
-    return result.Mod(result, modulus)
+// Decodes a subsecret that was a whole chunk of length bytes, putting back
+// the NUL bytes it started with, which elementToString does not keep.
+func elementToChunk(a Element, length int, field Field) string {
+    return string(elementBytes(field, a, length))
 }
 
-// Reversibly encodes an arbitary string into a bigInt.
-func stringToBigInt(s string) *big.Int {
-    return new(big.Int).SetBytes([]byte(s))
+// The first byte of the length subsecret that follows a last subsecret
+// starting with NUL, whose length would otherwise be lost. Native secrets are
+// ASCII and padded ones never contain this byte, so no other last subsecret
+// starts with it.
+const LENGTH_MARKER = 0xfe
+
+// The length subsecret saying the subsecret before it is length bytes long:
+// the marker, then the length, which is left out for single byte chunks.
+func lengthSubsecret(length, chunk_size int) string {
+    if chunk_size == 1 {
+        return string([]byte{LENGTH_MARKER})
+    }
+    return string([]byte{LENGTH_MARKER, byte(length)})
 }
 
-// Reversibly decodes an arbitary bigInt into a string.
-func bigIntToString(i *big.Int) string {
-    data := i.Bytes()
-    s := base64.StdEncoding.EncodeToString(data)
-    res, err := base64.StdEncoding.DecodeString(s)
-    if err != nil {
-        panic("Decoding failed.")
+// Undoes lengthSubsecret on the decoded subsecrets of a secret, every one but
+// the last decoded as a whole chunk: if the last is a length subsecret, it is
+// dropped and the one before cut to its length.
+func trimLastChunk(chunks []string, chunk_size int) ([]string, error) {
+    last := chunks[len(chunks)-1]
+    if len(last) == 0 || last[0] != LENGTH_MARKER {
+        return chunks, nil
+    }
+    bad := newError(ErrMalformedShare, "The recovered secret's length subsecret does not check out, the shares are wrong or corrupted.")
+    length := chunk_size
+    if len(last) == 2 && chunk_size > 1 {
+        length = int(last[1])
+    } else if len(last) != 1 || chunk_size > 1 {
+        return nil, bad
     }
-    return string(res)
+    if len(chunks) < 2 || length < 1 || length > chunk_size {
+        return nil, bad
+    }
+    chunk := chunks[len(chunks)-2]
+    if len(chunk) != chunk_size || strings.Trim(chunk[:chunk_size-length], "\x00") != "" || chunk[chunk_size-length] != 0 {
+        return nil, bad
+    }
+    return append(chunks[:len(chunks)-2], chunk[chunk_size-length:]), nil
 }
 
 func isASCII(s string) bool {
@@ -180,28 +332,29 @@ func isASCII(s string) bool {
     return true
 }
 
-func validSplitParameters(secret *string, n, t *int) bool {
-    if *secret == "" {
-        fmt.Println("Empty secret.\nSee README.md for example usage.")
-        return false
+// The secret may have been padded with padSecret, in which case it is the
+// secret inside the padding that is checked.
+func validSplitParameters(secret *string, n, t *int) error {
+    plain, err := unpadSecret(*secret)
+    if err != nil {
+        return newError(ErrInvalidParameters, "Secret starts with the padding marker but its padding does not check out.")
+    }
+    if plain == "" {
+        return newError(ErrInvalidParameters, "Empty secret.")
     }
-    if !isASCII(*secret) {
-        fmt.Println("Secret must be ASCII.")
-        return false
+    if !isASCII(plain) {
+        return newError(ErrInvalidParameters, "Secret must be ASCII.")
     }
     if *n < 1 {
-        fmt.Println("Number of shares less than 1.\nSee README.md for example usage.")
-        return false
+        return newError(ErrInvalidParameters, "Number of shares less than 1.")
     }
     if *t < 2 {
-        fmt.Println("Threshold less than 2.\nSee README.md for example usage.")
-        return false
+        return newError(ErrInvalidParameters, "Threshold less than 2.")
     }
     if *n < *t {
-        fmt.Println("Number of shares is less than the threshold.")
-        return false
+        return newError(ErrInvalidParameters, "Number of shares is less than the threshold.")
     }
-    return true
+    return nil
 }
 
 // Splits a string into chunks, preserving order. Each substring will be size
@@ -240,37 +393,39 @@ func splitStringIntoChunks(s string, n int) []string {
 // Returns: [23+100+19, 345+99+50]
 // This means you only need to give person 1 the share (1, 23+100+19) and
 // person 2 the share (2, 345+99+50)
-func pairwiseJoinSlices(a [][]*big.Int) []string {
+func pairwiseJoinSlices(a [][]Element, field Field) ([]string, error) {
+    if len(a) == 0 {
+        return nil, newError(ErrInvalidParameters, "No subsecret shares to join.")
+    }
     inner_slice_len := len(a[0])
     for _, element := range(a) {
         if len(element) != inner_slice_len {
-            panic("Slices are not all the same length.")
+            return nil, newError(ErrInvalidParameters, "Slices are not all the same length.")
         }
     }
     res := []string{}
     // Build the jth string
     for j := 0; j <= inner_slice_len  - 1; j++ {
-        str := ""
+        var str strings.Builder
         // To build the jth string, take the jth element from each inner slice and
         // add a "+" at the end for each, except the last.
         for i := 0; i < len(a) - 1; i++ {
-            str += a[i][j].String() + "+"
+            str.WriteString(field.Format(a[i][j]))
+            str.WriteByte('+')
         }
-        str += a[len(a)-1][j].String()
+        str.WriteString(field.Format(a[len(a)-1][j]))
         // Add the jth string to result
-        res = append(res, str)
+        res = append(res, str.String())
     }
-    return res
+    return res, nil
 }
 
-func validCombineParameters(s []string) bool {
+func validCombineParameters(s []string) error {
     if len(s) < 4 {
-        fmt.Println("Must combine at least two shares.\nSee README.md for example usage.")
-        return false
+        return newError(ErrThresholdNotMet, "Must combine at least two shares.")
     }
     if len(s) % 2 != 0 {
-        fmt.Println("Combine command takes an even number of arguments.\nSee README.md for example usage.")
-        return false
+        return newError(ErrMalformedShare, "Combine command takes an even number of arguments.")
     }
 
     var digitCheck = regexp.MustCompile(`^[0-9]+$`)
@@ -280,153 +435,1278 @@ func validCombineParameters(s []string) bool {
     for i := 1; i <= len(s); i+=2 {
         share := strings.Split(s[i], "+")
         if len(share) != num_subsecrets {
-            fmt.Println("Each share must contain the same number of subsecrets (numbers separated by '+').\nSee README.md for example usage.")
-            return false
+            return newError(ErrMalformedShare, "Each share must contain the same number of subsecrets (numbers separated by '+').")
         }
         for _,subsecret := range(share) {
             if !digitCheck.MatchString(subsecret) {
-                fmt.Println("Shares must be of the form: 'int+int+int+..+int'.\nSee README.md for example usage.")
-                return false
+                return newError(ErrMalformedShare, "Shares must be of the form: 'int+int+int+..+int'.")
             }
         }
     }
 
     // Check the share number (the odd numbered parameters, i.e the x co-ordinates)
+    seen := make(map[string]bool)
     for i := 0; i < len(s); i+=2 {
-        _, err := strconv.Atoi(s[i])
+        x, err := parseShareNumber(s[i], nil)
         if err != nil {
-            fmt.Println("Share numbers must be 64-bit ints.\nSee README.md for example usage.")
+            return err
+        }
+        if seen[x.String()] {
+            return newError(ErrDuplicateIndex, fmt.Sprintf("Share %s given more than once.", x))
         }
+        seen[x.String()] = true
     }
-    return true
+    return nil
+}
+
+var shareNumberPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// Whether s is written as a share number rather than as a share record.
+func isShareNumber(s string) bool {
+    return shareNumberPattern.MatchString(s)
+}
+
+// Parses a share number, i.e. the x-coordinate of a share. It must be
+// positive and, if the size of the field is given, less than it so that no
+// two share numbers are the same point.
+func parseShareNumber(s string, size *big.Int) (*big.Int, error) {
+    x, success := new(big.Int).SetString(s, 10)
+    if !isShareNumber(s) || !success {
+        return nil, newError(ErrMalformedShare, "Share numbers must be integers.")
+    }
+    if x.Sign() < 1 {
+        return nil, newError(ErrMalformedShare, "Share numbers must be positive.")
+    }
+    if size != nil && x.Cmp(size) >= 0 {
+        return nil, newError(ErrMalformedShare, "Share numbers must be less than the size of the field.")
+    }
+    return x, nil
+}
+
+// Checks that the field has a non-zero x-coordinate for each of n shares.
+func checkShareCount(n int, field Field) error {
+    if big.NewInt(int64(n)).Cmp(field.Size()) >= 0 {
+        return newError(ErrInvalidParameters, fmt.Sprintf("The %s field has room for at most %s shares.", field.Name(), new(big.Int).Sub(field.Size(), big.NewInt(1))))
+    }
+    return nil
 }
 
 // Given an input like ./shamir combine 2 334343+23232 4 32312321+2312312, this
-// will create a slice of maps like:
-// [[2: 334343, 4: 32312321], [2: 23232, 4: 2312312]]
-// Each map in the slice is itself a subsecret puzzle to solve with Lagrange.
-func createSubsecretSliceMap(s []string) []map[int]big.Int {
+// will create a slice of point lists like:
+// [[(2, 334343), (4, 32312321)], [(2, 23232), (4, 2312312)]]
+// Each list in the slice is itself a subsecret puzzle to solve with Lagrange.
+func createSubsecretSlices(s []string, field Field) ([][]xyPair, error) {
+    if len(s) < 2 || len(s) % 2 != 0 {
+        return nil, newError(ErrMalformedShare, "Combine command takes an even number of arguments.")
+    }
     num_subsecrets := len(strings.Split(s[1], "+"))
-    res := []map[int]big.Int{}
-    for i := 0; i < num_subsecrets; i++ {
-        subsecret_map := make(map[int]big.Int)
-        for j := 0; j < len(s); j += 2 {
-            x, err := strconv.Atoi((s[j]))
+    res := make([][]xyPair, num_subsecrets, num_subsecrets)
+    seen := make(map[string]bool)
+    for j := 0; j < len(s); j += 2 {
+        x, err := parseShareNumber(s[j], field.Size())
+        if err != nil {
+            return nil, err
+        }
+        if seen[x.String()] {
+            return nil, newError(ErrDuplicateIndex, fmt.Sprintf("Share %s given more than once.", x))
+        }
+        seen[x.String()] = true
+
+        subsecrets := strings.Split(s[j+1], "+")
+        if len(subsecrets) != num_subsecrets {
+            return nil, newError(ErrMalformedShare, "Each share must contain the same number of subsecrets (numbers separated by '+').")
+        }
+        for i, subsecret := range(subsecrets) {
+            if !isShareNumber(subsecret) {
+                return nil, newError(ErrMalformedShare, "Shares must be of the form: 'int+int+int+..+int'.")
+            }
+            y, err := field.Parse(subsecret)
             if err != nil {
-                panic("String to int conversion failed.")
+                return nil, newError(ErrMalformedShare, fmt.Sprintf("Share %s is too big for the %s field.", x, field.Name()))
             }
+            res[i] = append(res[i], xyPair{x, y})
+        }
+    }
+    return res, nil
+}
 
-            y := new(big.Int)
-            y, success := y.SetString(strings.Split(s[j+1], "+")[i], 10)
-            if success == false {
-                panic("SetString failed.")
+// Splits secret into n shares with threshold t. Share i (counting from 0) is
+// the share at x = i + 1, in the '+'-joined form printed by the command line.
+func splitSecret(secret string, n, t int, field Field) ([]string, error) {
+    return splitSecretAt(context.Background(), secret, sequentialXCoordinates(n), t, field, 0, rand.Reader)
+}
+
+// Splits secret into a share at each of the x-coordinates xs, with threshold
+// t. Share i is the share at xs[i]. Every subsecret is split at the same xs.
+// The secret is cut into subsecrets of the field's chunk size, which are
+// split by the given number of workers, or GOMAXPROCS if it is 0, until ctx
+// is done. The polynomials are read from random in subsecret order before
+// the workers start, so a seeded source gives the same shares however many
+// workers there are.
+func splitSecretAt(ctx context.Context, secret string, xs []*big.Int, t int, field Field, workers int, random io.Reader) ([]string, error) {
+    n := len(xs)
+    if err := validSplitParameters(&secret, &n, &t); err != nil {
+        return nil, err
+    }
+    if err := checkShareCount(n, field); err != nil {
+        return nil, err
+    }
+    for _, x := range(xs) {
+        if x.Sign() < 1 || x.Cmp(field.Size()) >= 0 {
+            return nil, newError(ErrInvalidParameters, fmt.Sprintf("x-coordinate %s is not a non-zero element of the %s field.", x, field.Name()))
+        }
+    }
+    if field.ChunkSize() < 1 {
+        return nil, newError(ErrInvalidParameters, fmt.Sprintf("The %s field is too small to hold a byte of secret.", field.Name()))
+    }
+
+    secret_chunks := splitStringIntoChunks(secret, field.ChunkSize())
+    // Every subsecret but the last is a whole chunk, so combine can put back
+    // any NUL bytes it starts with. Nothing else says how long the last one
+    // is, so if it starts with NUL a length subsecret follows it.
+    if last := secret_chunks[len(secret_chunks)-1]; last[0] == 0 {
+        secret_chunks = append(secret_chunks, lengthSubsecret(len(last), field.ChunkSize()))
+    }
+    polys := make([]polynomial, len(secret_chunks), len(secret_chunks))
+    for i, chunk := range(secret_chunks) {
+        var err error
+        if polys[i], err = generateRandomPolynomial(stringToElement(chunk, field), field, t - 1, random); err != nil {
+            return nil, err
+        }
+    }
+    result := make([][]Element, len(secret_chunks), len(secret_chunks))
+    err := runBatches(ctx, workers, len(secret_chunks), WORK_BATCH_SIZE, func(i int) error {
+        result[i] = _shamirSplitSecretWithFixedPolynomial(polys[i].coefficients[0], field, polys[i], xs, t)
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return pairwiseJoinSlices(result, field)
+}
+
+// Recovers the secret from shares given as alternating share numbers and
+// '+'-joined shares, e.g. ["1", "23+100+19", "2", "345+99+50"]. A padded
+// secret is returned without its padding.
+func combineShares(input []string, field Field) (string, error) {
+    return combineSharesWith(context.Background(), input, field, 0)
+}
+
+// Same as combineShares, but with the subsecrets recovered by the given
+// number of workers, or GOMAXPROCS if it is 0, until ctx is done.
+func combineSharesWith(ctx context.Context, input []string, field Field, workers int) (string, error) {
+    if err := validCombineParameters(input); err != nil {
+        return "", err
+    }
+
+    m, err := createSubsecretSlices(input, field)
+    if err != nil {
+        return "", err
+    }
+    // Every subsecret is at the same x-coordinates, so the Lagrange weights
+    // are worked out once, from the first.
+    xs := make([]*big.Int, len(m[0]), len(m[0]))
+    for j, p := range(m[0]) {
+        xs[j] = p.x
+    }
+    weights := lagrangeWeights(xs, field)
+    if weights == nil {
+        return "", newError(ErrDuplicateIndex, "Two shares are at the same point.")
+    }
+
+    secret := make([]string, len(m), len(m))
+    err = runBatches(ctx, workers, len(m), WORK_BATCH_SIZE, func(i int) error {
+        ys := make([]Element, len(m[i]), len(m[i]))
+        for j, p := range(m[i]) {
+            ys[j] = p.y
+        }
+        value := lagrangeWithWeights(ys, weights, field)
+        if i < len(m) - 1 {
+            secret[i] = elementToChunk(value, field.ChunkSize(), field)
+        } else {
+            secret[i] = elementToString(value, field)
+        }
+        return nil
+    })
+    if err != nil {
+        return "", err
+    }
+    if secret, err = trimLastChunk(secret, field.ChunkSize()); err != nil {
+        return "", err
+    }
+
+    return unpadSecret(strings.Join(secret, ""))
+}
+
+// Reads n bytes from the system random number generator.
+func randomBytes(n int) ([]byte, error) {
+    return readRandom(rand.Reader, n)
+}
+
+// Generates a random identifier tying together the shares of one split,
+// reading it from random.
+func newSplitID(random io.Reader) (string, error) {
+    id, err := readRandom(random, 8)
+    if err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(id), nil
+}
+
+// The reason for a failure as recorded in the audit log. Only the kind of
+// error is recorded since messages may quote user input.
+func auditErrorMessage(err error) string {
+    for _, kind := range([]error{ErrInvalidParameters, ErrMalformedShare, ErrDuplicateIndex, ErrThresholdNotMet, ErrUntrustedShare, ErrRandomness}) {
+        if errors.Is(err, kind) {
+            return kind.Error()
+        }
+    }
+    if errors.Is(err, context.Canceled) {
+        return "interrupted"
+    }
+    return "internal error"
+}
+
+// The reason recorded in the audit log for a failure reported only by its
+// exit code.
+func auditExitReason(exit_code int) string {
+    switch exit_code {
+        case EXIT_USAGE:
+            return ErrInvalidParameters.Error()
+        case EXIT_INVALID_SHARES:
+            return ErrMalformedShare.Error()
+        case EXIT_REJECTED:
+            return ErrUntrustedShare.Error()
+        case EXIT_IO:
+            return "i/o error"
+    }
+    return "internal error"
+}
+
+// Writes an entry to the audit log, exiting if that fails so that no
+// operation goes unrecorded. Once written, the operation is not recorded again
+// as failed.
+func recordAudit(out *output, audit *auditLog, entry auditRecord) {
+    out.auditEntry = nil
+    if err := audit.record(entry); err != nil {
+        out.fail(EXIT_IO, "Failed to write audit log: " + err.Error())
+    }
+}
+
+// Does the work of a native split, reading everything random from random:
+// looks up the field, works out the x-coordinates, pads the secret and splits
+// it. Returns an unsigned record for each share, in share order. Shares at
+// chosen x-coordinates keep the number of shares to themselves, so their
+// records have n = 0.
+func splitNative(ctx context.Context, split_id, secret string, n, t int, field_name string, workers int, x_coordinates, pad string, random io.Reader) (Field, []shareRecord, error) {
+    field, err := lookupField(field_name)
+    if err != nil {
+        return nil, nil, err
+    }
+    xs := sequentialXCoordinates(n)
+    if x_coordinates != "" {
+        if xs, err = parseXCoordinates(x_coordinates, n, field, random); err != nil {
+            return nil, nil, err
+        }
+    }
+    if pad != "" {
+        if secret, err = padSecret(secret, pad); err != nil {
+            return nil, nil, err
+        }
+    }
+    shares, err := splitSecretAt(ctx, secret, xs, t, field, workers, random)
+    if err != nil {
+        return nil, nil, err
+    }
+    records := make([]shareRecord, len(shares), len(shares))
+    for i, share := range(shares) {
+        records[i] = shareRecord{index: xs[i], splitID: split_id, field: recordFieldName(field.Name()), n: n, t: t, payload: share}
+        if x_coordinates != "" {
+            records[i].n = 0
+        }
+    }
+    return field, records, nil
+}
+
+// The warning printed for split's -seed mode.
+const INSECURE_SEED_WARNING = "WARNING: INSECURE TEST MODE. Every share of this split can be recomputed from -seed; use it only for test vectors, never for real secrets."
+
+func splitCommand(ctx context.Context, secret *string, n, t *int, field_name string, workers int, x_coordinates, pad, seed string, encoding string, dealer_key ed25519.PrivateKey, audit *auditLog, out *output) {
+    random := rand.Reader
+    if seed != "" {
+        random = newSeededReader(seed)
+        fmt.Fprintln(os.Stderr, INSECURE_SEED_WARNING)
+    }
+    entry := auditRecord{Operation: "split", N: *n, T: *t}
+    out.auditFailures(audit, &entry)
+    var enc shareEncoding
+    if encoding != DECIMAL_ENCODING {
+        var err error
+        if enc, err = lookupShareEncoding(encoding); err != nil {
+            out.failWith(err)
+        }
+    }
+
+    split_id, err := newSplitID(random)
+    if err != nil {
+        out.failWith(err)
+    }
+    entry.SplitID = split_id
+    field, records, err := splitNative(ctx, split_id, *secret, *n, *t, field_name, workers, x_coordinates, pad, random)
+    if err != nil {
+        out.failWith(err)
+    }
+
+    res := jsonSplit{Version: JSON_SCHEMA_VERSION, SplitID: split_id, Field: field.Name(), Insecure: seed != "", N: *n, T: *t, Signed: dealer_key != nil, Encoding: encoding, Shares: []jsonShare{}}
+    for i, record := range(records) {
+        share := jsonShare{Index: i+1, Payload: record.payload}
+        if x_coordinates != "" {
+            share.X = record.index
+        }
+        if dealer_key != nil {
+            record.sign(dealer_key)
+            share.Record = record.String()
+        }
+        if encoding != DECIMAL_ENCODING {
+            if share.Record, err = encodeShareRecord(record, enc); err != nil {
+                out.failWith(err)
             }
-            subsecret_map[x] = *y
         }
-        res = append(res, subsecret_map)
+        res.Shares = append(res.Shares, share)
     }
-    return res
+    handOutShares(out, audit, entry, res, func() {
+        fmt.Println("Secret to split:", *secret )
+        fmt.Println("Split ID:", split_id)
+        // Bare shares do not say which field they are in, so combine has to
+        // be told.
+        if field.Name() != DEFAULT_FIELD {
+            fmt.Println("Field:", field.Name())
+        }
+        for _, share := range(res.Shares) {
+            if share.Record != "" {
+                fmt.Printf("Share %d: %s\n", share.Index, share.Record)
+            } else {
+                fmt.Printf("Share %d: (%d, %s)\n", share.Index, share.x(), share.Payload)
+            }
+        }
+    })
+}
+
+// Hands out the shares of a split: writes their files, records the split in
+// the audit log as successful, and only then prints the shares, with
+// print_shares in text mode. A split whose files cannot be written is
+// recorded as failed instead.
+func handOutShares(out *output, audit *auditLog, entry auditRecord, res jsonSplit, print_shares func()) {
+    drawings := writeShareFiles(out, res)
+    entry.Success = true
+    recordAudit(out, audit, entry)
+    if out.json {
+        out.emit(res)
+        return
+    }
+    print_shares()
+    fmt.Print(drawings)
+}
+
+// Prints the split ID and shares of a split of bytes, under a heading for
+// each group if it has groups.
+func printGroupShares(res jsonSplit) {
+    fmt.Println("Split ID:", res.SplitID)
+    for _, share := range(res.Shares) {
+        if share.Group > 0 && share.Index == 1 {
+            group := res.Groups[share.Group-1]
+            fmt.Printf("Group %d (%d of %d shares needed):\n", group.Index, group.T, group.N)
+        }
+        fmt.Printf("Share %d: %s\n", share.Index, share.Payload)
+    }
+}
+
+// The x-coordinate of a native share: its number, unless it was split at
+// chosen x-coordinates.
+func (share jsonShare) x() *big.Int {
+    if share.X != nil {
+        return share.X
+    }
+    return big.NewInt(int64(share.Index))
+}
+
+// The field to put in the share records of a native split: empty for the
+// default field, so that its records read the same as before there was a
+// choice.
+func (res jsonSplit) recordField() string {
+    return recordFieldName(res.Field)
+}
+
+// The same as recordField, for a field given by name.
+func recordFieldName(name string) string {
+    if name == DEFAULT_FIELD {
+        return ""
+    }
+    return name
+}
+
+// Works out the field native shares were split over: the one their records
+// name, which must agree with each other and with the one given to combine
+// -field, if any. Bare shares are taken to be in the field given, or the
+// default one.
+func shareField(records []shareRecord, name string) (Field, error) {
+    for _, r := range(records) {
+        if name == "" {
+            name = r.fieldName()
+        } else if r.fieldName() != name {
+            return nil, newError(ErrMalformedShare, fmt.Sprintf("Share %s was split over the %s field, not %s.", r.index, r.fieldName(), name))
+        }
+    }
+    if name == "" {
+        name = DEFAULT_FIELD
+    }
+    return lookupField(name)
+}
+
+// The share numbers given to combine, skipping any that do not parse.
+func shareIndices(input []string) []*big.Int {
+    indices := []*big.Int{}
+    for i := 0; i < len(input); i += 2 {
+        if x, err := parseShareNumber(input[i], nil); err == nil {
+            indices = append(indices, x)
+        }
+    }
+    return indices
+}
+
+// Does the work of a native combine on shares split up by parseShareArgs:
+// checks the records against the trusted dealer key, if any, and against
+// their threshold, works out the field and recovers the secret.
+func combineNative(ctx context.Context, input []string, records []shareRecord, field_name string, workers int, trusted_key ed25519.PublicKey) (string, error) {
+    if trusted_key != nil {
+        if err := verifyShareRecords(input, records, trusted_key); err != nil {
+            return "", err
+        }
+    }
+    if err := checkRecordThreshold(records, len(input) / 2); err != nil {
+        return "", err
+    }
+    field, err := shareField(records, field_name)
+    if err != nil {
+        return "", err
+    }
+    return combineSharesWith(ctx, input, field, workers)
+}
+
+func combineCommand(ctx context.Context, args []string, field_name string, workers int, split_id string, trusted_key ed25519.PublicKey, encoding string, audit *auditLog, out *output) {
+    entry := auditRecord{Operation: "combine", SplitID: split_id}
+    out.auditFailures(audit, &entry)
+    input, records, err := parseShareArgs(args)
+    if err != nil {
+        out.failWith(err)
+    }
+    if err := checkRecordSplitID(records, split_id); err != nil {
+        out.failWith(err)
+    }
+    if split_id == "" && len(records) > 0 {
+        split_id = records[0].splitID
+    }
+
+    entry.SplitID, entry.Indices = split_id, shareIndices(input)
+    secret, err := combineNative(ctx, input, records, field_name, workers, trusted_key)
+    if err != nil {
+        out.failWith(err)
+    }
+
+    encoded, err := encodeSecret(secret, encoding)
+    if err != nil {
+        out.failWith(err)
+    }
+    entry.Success = true
+    recordAudit(out, audit, entry)
+    if out.json {
+        out.emit(jsonCombine{JSON_SCHEMA_VERSION, split_id, entry.Indices, encoding, encoded})
+        return
+    }
+    fmt.Println(encoded)
 }
 
-// A goroutine to split each subsecret with SSS
-func splitSubsecret(c chan splitPair, chan_id int, subsecret string, n, t int, modulus *big.Int) {
-    subsecret_int := stringToBigInt(subsecret)
-    subsecret_shares := shamirSplitSecret(subsecret_int, modulus, n, t)
-    c <- splitPair{subsecret_shares, chan_id}
+// Parses a list of groups such as "2of3,1of1,3of5" into thresholds and counts.
+func parseGroups(s string) ([]slip39Group, error) {
+    groups := []slip39Group{}
+    for _, field := range(strings.Split(s, ",")) {
+        var group slip39Group
+        if _, err := fmt.Sscanf(strings.TrimSpace(field), "%dof%d", &group.threshold, &group.count); err != nil {
+            return nil, newError(ErrInvalidParameters, fmt.Sprintf("Bad group %q, groups must be given as e.g. '2of3,1of1'.", field))
+        }
+        groups = append(groups, group)
+    }
+    return groups, nil
 }
 
-// A goroutine to combine each subsecret with SSS
-func combineSubsecret(c chan combinePair, chan_id int, subsecretshares map[int]big.Int, modulus *big.Int) {
-    subsecret := lagrange(subsecretshares, modulus)
-    res := bigIntToString(subsecret)
-    c <- combinePair{res, chan_id}
+// Reads the secret for a scheme that splits bytes, given either as text or,
+// with -secret-hex, as hex.
+func secretBytes(out *output, secret, secret_hex string) []byte {
+    if secret_hex == "" {
+        return []byte(secret)
+    }
+    if secret != "" {
+        out.fail(EXIT_USAGE, "Give either -secret or -secret-hex, not both.")
+    }
+    b, err := hex.DecodeString(secret_hex)
+    if err != nil {
+        out.fail(EXIT_USAGE, "The -secret-hex value is not valid hex.")
+    }
+    return b
 }
 
-func split(secret *string, n, t *int, PRIME *big.Int) {
-    if !validSplitParameters(secret, n, t) {
-        os.Exit(1)
+// The pixels per module of the PNG QR codes written by split -qr.
+const QR_PNG_SCALE = 8
+
+// The text put in a share's QR code: everything combine needs to read the
+// share back. Bare native shares become unsigned records so the share number
+// comes along, and URs are upper-cased so they fit QR's compact alphanumeric
+// mode.
+func shareQRText(res jsonSplit, share jsonShare) string {
+    switch {
+        case share.Record != "":
+            return share.Record
+        case res.Scheme == "":
+            record := shareRecord{index: share.x(), splitID: res.SplitID, field: res.recordField(), n: res.N, t: res.T, payload: share.Payload}
+            if share.X != nil {
+                record.n = 0
+            }
+            return record.String()
+        case strings.HasPrefix(share.Payload, "ur:"):
+            return strings.ToUpper(share.Payload)
     }
+    return share.Payload
+}
 
-    fmt.Println("Secret to split:", *secret )
+// Reads the -holders names for the sheets, checking up front that there is
+// one for every share.
+func checkHolders(out *output, holders string, n int, groups string) {
+    if out.printDir == "" {
+        out.fail(EXIT_USAGE, "-holders is only used with -print-dir.")
+    }
+    for _, name := range(strings.Split(holders, ",")) {
+        out.holders = append(out.holders, strings.TrimSpace(name))
+    }
+    if groups != "" {
+        if layout, err := parseGroups(groups); err == nil {
+            n = 0
+            for _, group := range(layout) {
+                n += group.count
+            }
+        }
+    }
+    if len(out.holders) != n {
+        out.fail(EXIT_USAGE, fmt.Sprintf("Got %d holder names for %d shares.", len(out.holders), n))
+    }
+}
 
-    secret_chunks := splitStringIntoChunks(*secret, CHUNK_SIZE)
-    num_subsecrets := len(secret_chunks)
-    result := make([][]*big.Int, num_subsecrets, num_subsecrets)
-    c := make(chan splitPair)
+// The file name, without extension, for a share's QR code or sheet.
+func shareFileName(res jsonSplit, share jsonShare) string {
+    if share.Group > 0 {
+        return fmt.Sprintf("%s-group-%d-share-%d", res.SplitID, share.Group, share.Index)
+    }
+    return fmt.Sprintf("%s-share-%d", res.SplitID, share.Index)
+}
 
-    for i, subsecret := range secret_chunks {
-        go splitSubsecret(c, i, subsecret, *n, *t, PRIME)
+// Writes the files split was asked for: with -qr each share's QR code as PNG
+// and SVG, and with -print-dir each share's printable sheet. In text mode,
+// returns the QR codes drawn for the terminal.
+func writeShareFiles(out *output, res jsonSplit) string {
+    for _, dir := range([]string{out.qrDir, out.printDir}) {
+        if dir == "" {
+            continue
+        }
+        if err := os.MkdirAll(dir, 0700); err != nil {
+            out.fail(EXIT_IO, "Failed to create output directory: " + err.Error())
+        }
     }
+    created := time.Now()
+    drawings := ""
+    for i, share := range(res.Shares) {
+        if out.printDir != "" {
+            holder := ""
+            if len(out.holders) > 0 {
+                holder = out.holders[i]
+            }
+            sheet, err := shareSheetHTML(res, share, holder, created)
+            if err != nil {
+                out.failWith(err)
+            }
+            if err := os.WriteFile(filepath.Join(out.printDir, shareFileName(res, share) + ".html"), []byte(sheet), 0600); err != nil {
+                out.fail(EXIT_IO, "Failed to write share sheet: " + err.Error())
+            }
+        }
+        if out.qrDir == "" {
+            continue
+        }
+        q, err := encodeQR(shareQRText(res, share))
+        if err != nil {
+            out.failWith(err)
+        }
+        path := filepath.Join(out.qrDir, shareFileName(res, share))
+        var img bytes.Buffer
+        if err := q.writePNG(&img, QR_PNG_SCALE); err != nil {
+            out.failWith(err)
+        }
+        if err := os.WriteFile(path + ".png", img.Bytes(), 0600); err != nil {
+            out.fail(EXIT_IO, "Failed to write QR code: " + err.Error())
+        }
+        if err := os.WriteFile(path + ".svg", []byte(q.svg()), 0600); err != nil {
+            out.fail(EXIT_IO, "Failed to write QR code: " + err.Error())
+        }
+        if !out.json {
+            drawings += fmt.Sprintf("\n%s.png (version %d-%s):\n%s", path, q.version, qrLevelNames[q.level], q.terminal())
+        }
+    }
+    return drawings
+}
 
-    // We launched goroutines for each subsecret. Output to the channel
-    // is tagged with the index it should be inserted to. Once we have
-    // all the subsecret solutions, stop and print output.
-    for count := 0; count < num_subsecrets; count++ {
-        output := <-c
-        result[output.id] = output.shares
+// Reads the shares for combine -from-images from the QR code in each
+// image, split into arguments as if they had been typed.
+func readShareImages(out *output, paths []string) []string {
+    args := []string{}
+    for _, path := range(paths) {
+        f, err := os.Open(path)
+        if err != nil {
+            out.fail(EXIT_IO, "Failed to open image: " + err.Error())
+        }
+        img, _, err := image.Decode(f)
+        f.Close()
+        if err != nil {
+            out.failWith(newError(ErrMalformedShare, fmt.Sprintf("%s is not a PNG or JPEG image: %v", path, err)))
+        }
+        text, err := decodeQRImage(img)
+        if err != nil {
+            out.failWith(fmt.Errorf("%s: %w", path, err))
+        }
+        args = append(args, strings.Fields(text)...)
     }
+    return args
+}
 
-    shares := pairwiseJoinSlices(result)
-    for i, _ := range(shares) {
-        fmt.Printf("Share %d: (%d, %s)\n", i+1, i+1, shares[i])
+// Splits the secret into SLIP-39 mnemonic shares. Without groups, n and t
+// give a single group.
+func slip39SplitCommand(secret []byte, n, t int, groups string, group_threshold int, passphrase string, iteration_exponent int, audit *auditLog, out *output) {
+    entry := auditRecord{Operation: "split", N: n, T: t}
+    out.auditFailures(audit, &entry)
+    layout := []slip39Group{{t, n}}
+    if groups != "" {
+        var err error
+        if layout, err = parseGroups(groups); err != nil {
+            out.failWith(err)
+        }
+        entry.N, entry.T = len(layout), group_threshold
+    } else {
+        group_threshold = 1
     }
+    if len(layout) == 1 {
+        entry.N, entry.T = layout[0].count, layout[0].threshold
+    }
+    shares, err := slip39Split(secret, []byte(passphrase), group_threshold, layout, iteration_exponent, true, rand.Reader)
+    if err != nil {
+        out.failWith(err)
+    }
+    entry.SplitID = fmt.Sprintf("%04x", shares[0][0].identifier)
+
+    res := jsonSplit{Version: JSON_SCHEMA_VERSION, Scheme: "slip39", SplitID: entry.SplitID, N: entry.N, T: entry.T, Encoding: "mnemonic", Shares: []jsonShare{}}
+    for i, group := range(shares) {
+        if len(layout) > 1 {
+            res.Groups = append(res.Groups, jsonGroup{i+1, layout[i].count, layout[i].threshold})
+        }
+        for _, share := range(group) {
+            res.Shares = append(res.Shares, jsonShare{Index: share.memberIndex+1, Group: len(res.Groups), Payload: share.mnemonic()})
+        }
+    }
+    handOutShares(out, audit, entry, res, func() {
+        printGroupShares(res)
+    })
+}
+
+// Recovers a secret from SLIP-39 mnemonic shares.
+func slip39CombineCommand(args []string, passphrase, encoding string, audit *auditLog, out *output) {
+    entry := auditRecord{Operation: "combine", Indices: []*big.Int{}}
+    out.auditFailures(audit, &entry)
+    mnemonics, err := splitSlip39Args(args)
+    if err == nil {
+        for _, m := range(mnemonics) {
+            if share, err := parseSlip39Mnemonic(m); err == nil {
+                entry.SplitID = fmt.Sprintf("%04x", share.identifier)
+                entry.Indices = append(entry.Indices, big.NewInt(int64(share.memberIndex+1)))
+            }
+        }
+    }
+    var secret []byte
+    if err == nil {
+        secret, err = slip39Combine(mnemonics, []byte(passphrase))
+    }
+    if err != nil {
+        out.failWith(err)
+    }
+
+    encoded, err := encodeSecret(string(secret), encoding)
+    if err != nil {
+        out.failWith(err)
+    }
+    entry.Success = true
+    recordAudit(out, audit, entry)
+    if out.json {
+        out.emit(jsonCombine{JSON_SCHEMA_VERSION, entry.SplitID, entry.Indices, encoding, encoded})
+        return
+    }
+    fmt.Println(encoded)
+}
+
+// Splits the secret into shares in the layout of Vault's unseal keys, printed
+// as base64 like `vault operator init` does, or as hex.
+func vaultSplitCommand(secret []byte, n, t int, encoding string, audit *auditLog, out *output) {
+    entry := auditRecord{Operation: "split", N: n, T: t}
+    out.auditFailures(audit, &entry)
+    if encoding != "base64" && encoding != "hex" {
+        out.fail(EXIT_USAGE, "Vault shares can only be encoded as 'base64' or 'hex'.")
+    }
+    split_id, err := newSplitID(rand.Reader)
+    if err != nil {
+        out.failWith(err)
+    }
+    entry.SplitID = split_id
+    shares, err := vaultSplit(secret, n, t)
+    if err != nil {
+        out.failWith(err)
+    }
+
+    res := jsonSplit{Version: JSON_SCHEMA_VERSION, Scheme: VAULT_SCHEME, SplitID: split_id, N: n, T: t, Encoding: encoding, Shares: []jsonShare{}}
+    for i, share := range(shares) {
+        payload := base64.StdEncoding.EncodeToString(share)
+        if encoding == "hex" {
+            payload = hex.EncodeToString(share)
+        }
+        res.Shares = append(res.Shares, jsonShare{Index: i+1, Payload: payload})
+    }
+    handOutShares(out, audit, entry, res, func() {
+        printGroupShares(res)
+    })
+}
+
+// Recovers a secret from Vault shares, each given as hex or base64. The
+// indices recorded are the shares' x coordinates.
+func vaultCombineCommand(args []string, split_id, encoding string, audit *auditLog, out *output) {
+    entry := auditRecord{Operation: "combine", SplitID: split_id, Indices: []*big.Int{}}
+    out.auditFailures(audit, &entry)
+    shares := [][]byte{}
+    var err error
+    for _, arg := range(args) {
+        var share []byte
+        if share, err = decodeVaultShare(arg); err != nil {
+            break
+        }
+        shares = append(shares, share)
+        if len(share) > 0 {
+            entry.Indices = append(entry.Indices, big.NewInt(int64(share[len(share)-1])))
+        }
+    }
+    var secret []byte
+    if err == nil {
+        secret, err = vaultCombine(shares)
+    }
+    if err != nil {
+        out.failWith(err)
+    }
+
+    encoded, err := encodeSecret(string(secret), encoding)
+    if err != nil {
+        out.failWith(err)
+    }
+    entry.Success = true
+    recordAudit(out, audit, entry)
+    if out.json {
+        out.emit(jsonCombine{JSON_SCHEMA_VERSION, split_id, entry.Indices, encoding, encoded})
+        return
+    }
+    fmt.Println(encoded)
 }
 
-func combine(input []string, PRIME *big.Int) {
-    if !validCombineParameters(input) {
-        os.Exit(1)
+// Splits the secret into shares in the format of ssss-split, which
+// ssss-combine can read.
+func ssssSplitCommand(secret []byte, n, t, security int, diffusion bool, token string, audit *auditLog, out *output) {
+    entry := auditRecord{Operation: "split", N: n, T: t}
+    out.auditFailures(audit, &entry)
+    split_id, err := newSplitID(rand.Reader)
+    if err != nil {
+        out.failWith(err)
+    }
+    entry.SplitID = split_id
+    shares, err := ssssSplit(secret, n, t, security, diffusion, token)
+    if err != nil {
+        out.failWith(err)
     }
 
-    m := createSubsecretSliceMap(input)
-    num_subsecrets := len(m)
-    secret := make([]string, num_subsecrets, num_subsecrets)
-    c := make(chan combinePair)
-    for i, subsecretShares := range(m) {
-        go combineSubsecret(c, i, subsecretShares, PRIME)
+    width := len(strconv.Itoa(n))
+    res := jsonSplit{Version: JSON_SCHEMA_VERSION, Scheme: SSSS_SCHEME, SplitID: split_id, N: n, T: t, Encoding: "ssss", Shares: []jsonShare{}}
+    for _, share := range(shares) {
+        res.Shares = append(res.Shares, jsonShare{Index: share.index, Payload: share.format(width)})
     }
+    handOutShares(out, audit, entry, res, func() {
+        printGroupShares(res)
+    })
+}
 
-    for count := 0; count < num_subsecrets; count++ {
-        output := <-c
-        secret[output.id] = output.subsecret
+// Recovers a secret from shares written by ssss-split. Like ssss-combine it
+// needs the threshold, which defaults to the number of shares given.
+func ssssCombineCommand(args []string, threshold int, diffusion bool, split_id, encoding string, audit *auditLog, out *output) {
+    entry := auditRecord{Operation: "combine", SplitID: split_id, Indices: []*big.Int{}}
+    out.auditFailures(audit, &entry)
+    shares := []ssssShare{}
+    var err error
+    for _, arg := range(args) {
+        var share ssssShare
+        if share, err = parseSsssShare(arg); err != nil {
+            break
+        }
+        shares = append(shares, share)
+        entry.Indices = append(entry.Indices, big.NewInt(int64(share.index)))
+    }
+    if threshold == 0 {
+        threshold = len(shares)
+    }
+    var secret []byte
+    if err == nil {
+        secret, err = ssssCombine(shares, threshold, diffusion)
+    }
+    if err != nil {
+        out.failWith(err)
     }
 
-    fmt.Println(strings.Join(secret, ""))
+    // The secret comes back padded to the security level. A text secret
+    // shorter than that is printed without the padding, as ssss does.
+    if encoding == "text" {
+        secret = bytes.TrimLeft(secret, "\x00")
+    }
+    encoded, err := encodeSecret(string(secret), encoding)
+    if err != nil {
+        out.failWith(err)
+    }
+    entry.Success = true
+    recordAudit(out, audit, entry)
+    if out.json {
+        out.emit(jsonCombine{JSON_SCHEMA_VERSION, split_id, entry.Indices, encoding, encoded})
+        return
+    }
+    fmt.Println(encoded)
 }
 
-func parseArgs(PRIME *big.Int) {
-    splitCmd := flag.NewFlagSet("split", flag.ExitOnError)
+// Splits the secret into SSKR shares, printed as `ur:sskr/` URs or as
+// Bytewords. Without groups, n and t give a single group.
+func sskrSplitCommand(secret []byte, n, t int, groups string, group_threshold int, encoding string, audit *auditLog, out *output) {
+    entry := auditRecord{Operation: "split", N: n, T: t}
+    out.auditFailures(audit, &entry)
+    if encoding != "ur" && encoding != "bytewords" {
+        out.fail(EXIT_USAGE, "SSKR shares can only be encoded as 'ur' or 'bytewords'.")
+    }
+    layout := []slip39Group{{t, n}}
+    if groups != "" {
+        var err error
+        if layout, err = parseGroups(groups); err != nil {
+            out.failWith(err)
+        }
+        entry.N, entry.T = len(layout), group_threshold
+    } else {
+        group_threshold = 1
+    }
+    if len(layout) == 1 {
+        entry.N, entry.T = layout[0].count, layout[0].threshold
+    }
+    shares, err := sskrSplit(secret, group_threshold, layout, rand.Reader)
+    if err != nil {
+        out.failWith(err)
+    }
+    entry.SplitID = fmt.Sprintf("%04x", shares[0][0].identifier)
+
+    res := jsonSplit{Version: JSON_SCHEMA_VERSION, Scheme: SSKR_SCHEME, SplitID: entry.SplitID, N: entry.N, T: entry.T, Encoding: encoding, Shares: []jsonShare{}}
+    for i, group := range(shares) {
+        if len(layout) > 1 {
+            res.Groups = append(res.Groups, jsonGroup{i+1, layout[i].count, layout[i].threshold})
+        }
+        for _, share := range(group) {
+            payload := share.ur()
+            if encoding == "bytewords" {
+                payload = share.bytewords()
+            }
+            res.Shares = append(res.Shares, jsonShare{Index: share.memberIndex+1, Group: len(res.Groups), Payload: payload})
+        }
+    }
+    handOutShares(out, audit, entry, res, func() {
+        printGroupShares(res)
+    })
+}
+
+// Recovers a secret from SSKR shares given as URs or Bytewords.
+func sskrCombineCommand(args []string, encoding string, audit *auditLog, out *output) {
+    entry := auditRecord{Operation: "combine", Indices: []*big.Int{}}
+    out.auditFailures(audit, &entry)
+    shares := []sskrShare{}
+    tokens, err := splitSskrArgs(args)
+    if err == nil {
+        for _, token := range(tokens) {
+            var share sskrShare
+            if share, err = parseSskrShare(token); err != nil {
+                break
+            }
+            shares = append(shares, share)
+            entry.SplitID = fmt.Sprintf("%04x", share.identifier)
+            entry.Indices = append(entry.Indices, big.NewInt(int64(share.memberIndex+1)))
+        }
+    }
+    var secret []byte
+    if err == nil {
+        secret, err = sskrCombine(shares)
+    }
+    if err != nil {
+        out.failWith(err)
+    }
+
+    encoded, err := encodeSecret(string(secret), encoding)
+    if err != nil {
+        out.failWith(err)
+    }
+    entry.Success = true
+    recordAudit(out, audit, entry)
+    if out.json {
+        out.emit(jsonCombine{JSON_SCHEMA_VERSION, entry.SplitID, entry.Indices, encoding, encoded})
+        return
+    }
+    fmt.Println(encoded)
+}
+
+// Exits with a usage error if the scheme is unknown, or if a flag was given
+// that the scheme does not take.
+func checkSchemeFlags(out *output, cmd *flag.FlagSet, scheme string) {
+    known := false
+    for _, name := range(schemeNames) {
+        known = known || name == scheme
+    }
+    if !known {
+        out.fail(EXIT_USAGE, fmt.Sprintf("Unknown scheme %q, expected one of: %s.", scheme, strings.Join(schemeNames, ", ")))
+    }
+    cmd.Visit(func(f *flag.Flag) {
+        schemes, ok := schemeFlags[f.Name]
+        if !ok {
+            return
+        }
+        for _, name := range(schemes) {
+            if name == scheme {
+                return
+            }
+        }
+        out.fail(EXIT_USAGE, fmt.Sprintf("-%s is not supported with -scheme %s.", f.Name, scheme))
+    })
+}
+
+// Whether the flag called name was given on the command line.
+func flagWasSet(cmd *flag.FlagSet, name string) bool {
+    set := false
+    cmd.Visit(func(f *flag.Flag) {
+        if f.Name == name {
+            set = true
+        }
+    })
+    return set
+}
+
+// Adds the audit logging flags shared by split and combine.
+func addAuditFlags(cmd *flag.FlagSet) (logPath, keyPath, operator *string) {
+    logPath = cmd.String("audit-log", "", "Append a hash-chained audit entry to this file.")
+    keyPath = cmd.String("audit-key", "", "PEM Ed25519 private key used to sign audit entries.")
+    operator = cmd.String("operator", "", "Operator identity recorded in the audit log (default: current user).")
+    return
+}
+
+// Adds the -format flag every subcommand takes.
+func addFormatFlag(cmd *flag.FlagSet) *string {
+    return cmd.String("format", "text", "Output format: 'text' or 'json'.")
+}
+
+// Makes a subcommand's flag set. The flag package neither prints nor exits on
+// a bad flag; parseFlags reports it, so that with -format json it comes out
+// as a JSON error like any other.
+func newFlagSet(name string) *flag.FlagSet {
+    cmd := flag.NewFlagSet(name, flag.ContinueOnError)
+    cmd.SetOutput(io.Discard)
+    return cmd
+}
+
+// Parses args into cmd and returns the output its -format flag asks for. A
+// bad flag or -format value exits with EXIT_USAGE, in text mode after printing
+// the usage to stderr as the flag package would.
+func parseFlags(cmd *flag.FlagSet, args []string, format *string) *output {
+    err := cmd.Parse(args)
+    if err == nil {
+        return newOutput(*format)
+    }
+    out := &output{json: formatArg(args) == "json"}
+    if err == flag.ErrHelp || !out.json {
+        cmd.SetOutput(os.Stderr)
+        cmd.Usage()
+    }
+    if err == flag.ErrHelp {
+        os.Exit(0)
+    }
+    out.fail(EXIT_USAGE, fmt.Sprintf("Invalid arguments: %v.", err))
+    return nil
+}
+
+// The last -format given in args, read without the flag package, which stops
+// at the first bad flag.
+func formatArg(args []string) string {
+    format := ""
+    for i, arg := range(args) {
+        if arg == "--" || !strings.HasPrefix(arg, "-") {
+            continue
+        }
+        name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+        if value := strings.TrimPrefix(name, "format="); value != name {
+            format = value
+        } else if name == "format" && i + 1 < len(args) {
+            format = args[i + 1]
+        }
+    }
+    return format
+}
+
+func openAuditLog(out *output, logPath, keyPath, operator *string) *auditLog {
+    audit, err := newAuditLog(*logPath, *keyPath, *operator)
+    if err != nil {
+        out.fail(EXIT_IO, "Failed to open audit log: " + err.Error())
+    }
+    return audit
+}
+
+// Loads the private key at path, or returns nil if no path is given.
+func mustLoadPrivateKey(out *output, path string) ed25519.PrivateKey {
+    if path == "" {
+        return nil
+    }
+    key, err := loadPrivateKey(path)
+    if err != nil {
+        out.fail(EXIT_IO, "Failed to load private key: " + err.Error())
+    }
+    return key
+}
+
+// Loads the public key at path, or returns nil if no path is given.
+func mustLoadPublicKey(out *output, path string) ed25519.PublicKey {
+    if path == "" {
+        return nil
+    }
+    key, err := loadPublicKey(path)
+    if err != nil {
+        out.fail(EXIT_IO, "Failed to load public key: " + err.Error())
+    }
+    return key
+}
+
+// Checks the dealer signature on each share record without combining them.
+func verifyCommand(args []string, trusted_key ed25519.PublicKey, out *output) {
+    if trusted_key == nil {
+        out.fail(EXIT_USAGE, "No trusted dealer key given.")
+    }
+    if len(args) == 0 {
+        out.fail(EXIT_USAGE, "No shares given.")
+    }
+    res := jsonVerify{JSON_SCHEMA_VERSION, true, []jsonVerifiedShare{}}
+    for _, arg := range(args) {
+        record, err := parseShareToken(arg)
+        if err == nil {
+            err = record.verify(trusted_key)
+        }
+        share := jsonVerifiedShare{record.index, record.splitID, record.n, record.t, err == nil, ""}
+        if err != nil {
+            share.Error = err.Error()
+            res.Valid = false
+        }
+        res.Shares = append(res.Shares, share)
+    }
+
+    if out.json {
+        out.emit(res)
+    } else {
+        for _, share := range(res.Shares) {
+            if share.Valid && share.N == 0 {
+                fmt.Printf("Share %d: OK (split %s, %d needed)\n", share.Index, share.SplitID, share.T)
+            } else if share.Valid {
+                fmt.Printf("Share %d: OK (split %s, %d of %d)\n", share.Index, share.SplitID, share.T, share.N)
+            } else {
+                fmt.Println("REJECTED:", share.Error)
+            }
+        }
+    }
+    if !res.Valid {
+        os.Exit(EXIT_REJECTED)
+    }
+}
+
+func auditCommand(args []string) {
+    if len(args) < 1 || args[0] != "verify" {
+        fmt.Println("Expected 'audit verify' subcommand.\nSee README.md for example usage.")
+        os.Exit(EXIT_USAGE)
+    }
+    verifyCmd := newFlagSet("audit verify")
+    logPath := verifyCmd.String("log", "", "Audit log to verify, along with its head in the same file name ending in .head.")
+    pubPath := verifyCmd.String("pubkey", "", "PEM Ed25519 public key; if given every entry must be signed by it.")
+    format := addFormatFlag(verifyCmd)
+    out := parseFlags(verifyCmd, args[1:], format)
+
+    if *logPath == "" {
+        out.fail(EXIT_USAGE, "No audit log given.")
+    }
+    pub := mustLoadPublicKey(out, *pubPath)
+    count, err := verifyAuditLog(*logPath, pub)
+    if err != nil {
+        out.fail(EXIT_REJECTED, "Audit log verification failed: " + err.Error())
+    }
+    if out.json {
+        out.emit(jsonAuditVerify{JSON_SCHEMA_VERSION, true, count})
+        return
+    }
+    fmt.Printf("Audit log OK: %d entries verified.\n", count)
+}
+
+func parseArgs() {
+    splitCmd := newFlagSet("split")
     secret := splitCmd.String("secret", "", "Secret to split.")
     n := splitCmd.Int("n", 0, "Number of shares to split secret into.")
     t := splitCmd.Int("t", 0, "Threshold needed to repiece together secret.")
+    dealerKey := splitCmd.String("dealer-key", "", "PEM Ed25519 private key used to sign each share.")
+    shareEncoding := splitCmd.String("encoding", DECIMAL_ENCODING, "Share encoding: 'decimal', 'hex', 'base32', 'base64', 'base64url' or 'words'; 'ur' or 'bytewords' for sskr.")
+    splitScheme := splitCmd.String("scheme", NATIVE_SCHEME, "Sharing scheme: 'native', 'slip39', 'vault', 'ssss' or 'sskr'.")
+    secretHex := splitCmd.String("secret-hex", "", "Secret to split, as hex (not for the native scheme).")
+    groups := splitCmd.String("groups", "", "SLIP-39 or SSKR groups as thresholds of counts, e.g. '2of3,1of1'; replaces -n and -t.")
+    groupThreshold := splitCmd.Int("group-threshold", 1, "Number of SLIP-39 or SSKR groups needed to repiece together secret.")
+    iterationExponent := splitCmd.Int("iteration-exponent", 1, "SLIP-39 passphrase hardening: 10000 << e PBKDF2 iterations.")
+    splitPassphrase := splitCmd.String("passphrase", "", "SLIP-39 passphrase the secret is encrypted with.")
+    security := splitCmd.Int("security", 0, "ssss security level in bits, a multiple of 8 up to 1024 (default: the length of the secret).")
+    splitDiffusion := splitCmd.Bool("diffusion", true, "Run the secret through ssss's diffusion layer (ssss -D turns it off).")
+    token := splitCmd.String("token", "", "ssss token to prefix each share with.")
+    splitQR := splitCmd.String("qr", "", "Directory to write each share's QR code to, as PNG and SVG; also drawn in the terminal.")
+    printDir := splitCmd.String("print-dir", "", "Directory to write a printable HTML sheet for each share to.")
+    holders := splitCmd.String("holders", "", "Comma separated names of the share holders, in share order, for -print-dir.")
+    pad := splitCmd.String("pad", "", "Pad the secret so shares do not give away its length: a padded length in bytes, or 'bucket' for the next power of two. The padding's checksum has no key, so the length is only authenticated when the split is signed with -dealer-key.")
+    seed := splitCmd.String("seed", "", "INSECURE test mode: derive all of the split's randomness from this seed, to generate known-answer test vectors. Never use it for real secrets.")
+    xCoordinates := splitCmd.String("x-coordinates", "", "Comma separated x-coordinates to give the shares, or 'random', instead of 1..n; hides the number of shares.")
+    splitWorkers := splitCmd.Int("workers", 0, "Number of goroutines splitting the secret (default: GOMAXPROCS).")
+    splitField := splitCmd.String("field", DEFAULT_FIELD, "Field to split the secret over: 'p127', 'p521', 'p256-scalar', 'ed25519-scalar', 'gf256' or 'gf128'.")
+    splitFormat := addFormatFlag(splitCmd)
+    splitLog, splitKey, splitOperator := addAuditFlags(splitCmd)
+
+    combineCmd := newFlagSet("combine")
+    split_id := combineCmd.String("split-id", "", "Split ID printed by split, recorded in the audit log; share records from other splits are rejected.")
+    combineTrusted := combineCmd.String("trusted-key", "", "PEM Ed25519 public key of the dealer; unsigned or forged shares are rejected.")
+    secretEncoding := combineCmd.String("secret-encoding", "text", "Encoding of the recovered secret: 'text', 'hex' or 'base64'.")
+    combineScheme := combineCmd.String("scheme", NATIVE_SCHEME, "Sharing scheme: 'native', 'slip39', 'vault', 'ssss' or 'sskr'.")
+    combinePassphrase := combineCmd.String("passphrase", "", "SLIP-39 passphrase the secret was encrypted with.")
+    combineThreshold := combineCmd.Int("threshold", 0, "Threshold the ssss shares were split with (default: the number of shares given).")
+    combineDiffusion := combineCmd.Bool("diffusion", true, "Undo ssss's diffusion layer; pass -diffusion=false for shares made with ssss -D.")
+    fromImages := combineCmd.Bool("from-images", false, "Read the shares from QR codes in the PNG or JPEG images given instead.")
+    combineWorkers := combineCmd.Int("workers", 0, "Number of goroutines recovering the secret (default: GOMAXPROCS).")
+    combineField := combineCmd.String("field", "", "Field the shares were split over (default: the one their records name, or p127).")
+    combineFormat := addFormatFlag(combineCmd)
+    combineLog, combineKey, combineOperator := addAuditFlags(combineCmd)
 
-    combineCmd := flag.NewFlagSet("combine", flag.ExitOnError)
+    verifyCmd := newFlagSet("verify")
+    verifyTrusted := verifyCmd.String("trusted-key", "", "PEM Ed25519 public key of the dealer.")
+    verifyFormat := addFormatFlag(verifyCmd)
 
     if len(os.Args) < 2 {
-        fmt.Println("Expected 'split' or 'combine' subcommands.\nSee README.md for example usage.")
-        os.Exit(1)
+        fmt.Println("Expected 'split', 'combine', 'verify', 'audit', 'testvectors' or 'bench' subcommands.\nSee README.md for example usage.")
+        os.Exit(EXIT_USAGE)
     }
 
+    // An interrupted split or combine stops its workers and is recorded in
+    // the audit log as failed.
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
+
     switch os.Args[1] {
         case "split":
-            splitCmd.Parse(os.Args[2:])
-            split(secret, n, t, PRIME)
+            out := parseFlags(splitCmd, os.Args[2:], splitFormat)
+            out.qrDir, out.printDir = *splitQR, *printDir
+            // Every split attempt is audited, even one turned down before it
+            // gets going.
+            audit := openAuditLog(out, splitLog, splitKey, splitOperator)
+            out.auditFailures(audit, &auditRecord{Operation: "split", N: *n, T: *t})
+            if *holders != "" {
+                checkHolders(out, *holders, *n, *groups)
+            }
+            checkSchemeFlags(out, splitCmd, *splitScheme)
+            switch *splitScheme {
+                case NATIVE_SCHEME:
+                    splitCommand(ctx, secret, n, t, *splitField, *splitWorkers, *xCoordinates, *pad, *seed, *shareEncoding, mustLoadPrivateKey(out, *dealerKey), audit, out)
+                case SLIP39_SCHEME:
+                    slip39SplitCommand(secretBytes(out, *secret, *secretHex), *n, *t, *groups, *groupThreshold, *splitPassphrase, *iterationExponent, audit, out)
+                case VAULT_SCHEME:
+                    if !flagWasSet(splitCmd, "encoding") {
+                        *shareEncoding = "base64"
+                    }
+                    vaultSplitCommand(secretBytes(out, *secret, *secretHex), *n, *t, *shareEncoding, audit, out)
+                case SSSS_SCHEME:
+                    ssssSplitCommand(secretBytes(out, *secret, *secretHex), *n, *t, *security, *splitDiffusion, *token, audit, out)
+                case SSKR_SCHEME:
+                    if !flagWasSet(splitCmd, "encoding") {
+                        *shareEncoding = "ur"
+                    }
+                    sskrSplitCommand(secretBytes(out, *secret, *secretHex), *n, *t, *groups, *groupThreshold, *shareEncoding, audit, out)
+            }
 
         case "combine":
-            combineCmd.Parse(os.Args[2:])
+            out := parseFlags(combineCmd, os.Args[2:], combineFormat)
             input := combineCmd.Args()
-            combine(input, PRIME)
+            audit := openAuditLog(out, combineLog, combineKey, combineOperator)
+            out.auditFailures(audit, &auditRecord{Operation: "combine", SplitID: *split_id})
+            if *fromImages {
+                input = readShareImages(out, input)
+            }
+            // ur:sskr/ shares are recognised without -scheme.
+            if !flagWasSet(combineCmd, "scheme") && isSskrInput(input) {
+                *combineScheme = SSKR_SCHEME
+            }
+            checkSchemeFlags(out, combineCmd, *combineScheme)
+            // The other schemes' secrets are usually binary, so default to
+            // hex. ssss secrets are text unless split with ssss -x.
+            if *combineScheme != NATIVE_SCHEME && *combineScheme != SSSS_SCHEME && !flagWasSet(combineCmd, "secret-encoding") {
+                *secretEncoding = "hex"
+            }
+            switch *combineScheme {
+                case NATIVE_SCHEME:
+                    combineCommand(ctx, input, *combineField, *combineWorkers, *split_id, mustLoadPublicKey(out, *combineTrusted), *secretEncoding, audit, out)
+                case SLIP39_SCHEME:
+                    slip39CombineCommand(input, *combinePassphrase, *secretEncoding, audit, out)
+                case VAULT_SCHEME:
+                    vaultCombineCommand(input, *split_id, *secretEncoding, audit, out)
+                case SSSS_SCHEME:
+                    ssssCombineCommand(input, *combineThreshold, *combineDiffusion, *split_id, *secretEncoding, audit, out)
+                case SSKR_SCHEME:
+                    sskrCombineCommand(input, *secretEncoding, audit, out)
+            }
+
+        case "verify":
+            out := parseFlags(verifyCmd, os.Args[2:], verifyFormat)
+            verifyCommand(verifyCmd.Args(), mustLoadPublicKey(out, *verifyTrusted), out)
+
+        case "audit":
+            auditCommand(os.Args[2:])
+
+        case "testvectors":
+            testVectorsCommand(os.Args[2:])
+
+        case "bench":
+            benchCommand(ctx, os.Args[2:])
 
         default:
-            fmt.Println("Expected 'split' or 'combine' subcommands. See README.md for example usage.")
-            os.Exit(1)
+            fmt.Println("Expected 'split', 'combine', 'verify', 'audit', 'testvectors' or 'bench' subcommands. See README.md for example usage.")
+            os.Exit(EXIT_USAGE)
         }
 }
 
 func main() {
-    PRIME, success := new(big.Int).SetString(PRIME, 10)
-    if success == false {
-        panic("Failed to parse prime.")
-    }
-
-    parseArgs(PRIME)
+    parseArgs()
 }
@@ -1,48 +1,51 @@
 package main
 
 import (
+    "context"
+    "crypto/rand"
+    "errors"
     "testing"
     "math/big"
     "reflect"
 )
 
 func TestEvaluatePolynomial(t *testing.T) {
-    p := polynomial{[]*big.Int{big.NewInt(2), big.NewInt(4), big.NewInt(3), big.NewInt(0), big.NewInt(2)}}
+    field := newPrimeField("17", big.NewInt(17))
+    p := polynomial{elements(field, []*big.Int{big.NewInt(2), big.NewInt(4), big.NewInt(3), big.NewInt(0), big.NewInt(2)})}
     x := big.NewInt(3)
-    modulus := big.NewInt(17)
-    result := evaluatePolynomial(x, modulus, p)
+    result := field.ToBig(evaluatePolynomial(x, field, p))
     if result.Cmp(big.NewInt(16)) != 0 {
         t.Errorf("Expecting 3, got: %s", result.String())
     }
 
-    p = polynomial{[]*big.Int{big.NewInt(0), big.NewInt(12), big.NewInt(-9), big.NewInt(0), big.NewInt(0)}}
+    field = newPrimeField("17", big.NewInt(17))
+    p = polynomial{elements(field, []*big.Int{big.NewInt(0), big.NewInt(12), big.NewInt(-9), big.NewInt(0), big.NewInt(0)})}
     x = big.NewInt(4)
-    modulus = big.NewInt(17)
-    result = evaluatePolynomial(x, modulus, p)
+    result = field.ToBig(evaluatePolynomial(x, field, p))
     if result.Cmp(big.NewInt(6)) != 0 {
         t.Errorf("Expecting 6, got: %s", result.String())
     }
 
-    p = polynomial{[]*big.Int{big.NewInt(-3), big.NewInt(12), big.NewInt(-9), big.NewInt(0), big.NewInt(1)}}
+    field = newPrimeField("19", big.NewInt(19))
+    p = polynomial{elements(field, []*big.Int{big.NewInt(-3), big.NewInt(12), big.NewInt(-9), big.NewInt(0), big.NewInt(1)})}
     x = big.NewInt(-5)
-    modulus = big.NewInt(20)
-    result = evaluatePolynomial(x, modulus, p)
-    if result.Cmp(big.NewInt(17)) != 0 {
-        t.Errorf("Expecting 17, got: %s", result.String())
+    result = field.ToBig(evaluatePolynomial(x, field, p))
+    if result.Cmp(big.NewInt(14)) != 0 {
+        t.Errorf("Expecting 14, got: %s", result.String())
     }
 
     // Evaluate polynomial twice as different points to ensure it does not
     // change when messing around with pointers.
-    p = polynomial{[]*big.Int{big.NewInt(1234), big.NewInt(166), big.NewInt(94)}}
-    modulus = big.NewInt(1613)
+    field = newPrimeField("1613", big.NewInt(1613))
+    p = polynomial{elements(field, []*big.Int{big.NewInt(1234), big.NewInt(166), big.NewInt(94)})}
     x = big.NewInt(0)
-    result = evaluatePolynomial(x, modulus, p)
+    result = field.ToBig(evaluatePolynomial(x, field, p))
     if result.Cmp(big.NewInt(1234)) != 0 {
         t.Errorf("Expecting 1234, got: %s", result.String())
     }
 
     x = big.NewInt(1)
-    result = evaluatePolynomial(x, modulus, p)
+    result = field.ToBig(evaluatePolynomial(x, field, p))
     if result.Cmp(big.NewInt(1494)) != 0 {
         t.Errorf("Expecting 1494, got: %s", result.String())
     }
@@ -50,12 +53,12 @@ func TestEvaluatePolynomial(t *testing.T) {
 
 func Test_shamirSplitSecretWithFixedPolynomial(t *testing.T) {
     // Taken from https://en.wikipedia.org/wiki/Shamir%27s_Secret_Sharing
-    secret := big.NewInt(1234)
-    modulus := big.NewInt(1613)
+    field := newPrimeField("1613", big.NewInt(1613))
+    secret := field.FromBig(big.NewInt(1234))
     n := 6
     threshold := 3
-    poly := polynomial{[]*big.Int{secret, big.NewInt(166), big.NewInt(94)}}
-    result := _shamirSplitSecretWithFixedPolynomial(secret, modulus, poly, n, threshold)
+    poly := polynomial{[]Element{secret, field.FromBig(big.NewInt(166)), field.FromBig(big.NewInt(94))}}
+    result := _shamirSplitSecretWithFixedPolynomial(secret, field, poly, sequentialXCoordinates(n), threshold)
     expected := []*big.Int{big.NewInt(1494), big.NewInt(329), big.NewInt(965), big.NewInt(176), big.NewInt(1188), big.NewInt(775)}
 
     if len(expected) != len(result) {
@@ -63,36 +66,59 @@ func Test_shamirSplitSecretWithFixedPolynomial(t *testing.T) {
     }
 
     for i := 0; i < len(expected); i++ {
-        if result[i].Cmp(expected[i]) != 0 {
-            t.Errorf("Expecting %s at x=%d, got: %s", expected[i], i+1, result[i])
+        if field.ToBig(result[i]).Cmp(expected[i]) != 0 {
+            t.Errorf("Expecting %s at x=%d, got: %s", expected[i], i+1, field.ToBig(result[i]))
         }
     }
 }
 
 func TestLagrange(t *testing.T) {
     // Taken from https://en.wikipedia.org/wiki/Shamir%27s_Secret_Sharing
-    modulus := big.NewInt(1399)
-    points := map[int]big.Int{
-        2 : *big.NewInt(1942),
-        4 : *big.NewInt(3402),
-        5 : *big.NewInt(4414),
+    field := newPrimeField("1399", big.NewInt(1399))
+    points := []xyPair{
+        {big.NewInt(2), field.FromBig(big.NewInt(1942))},
+        {big.NewInt(4), field.FromBig(big.NewInt(3402))},
+        {big.NewInt(5), field.FromBig(big.NewInt(4414))},
     }
-    result := lagrange(points, modulus)
+    result := field.ToBig(lagrange(points, field))
     expected := big.NewInt(1234)
 
     if result.Cmp(expected) != 0 {
         t.Errorf("Expecting %s, got: %s", expected, result)
     }
+
+    // The same polynomial, 1234 + 166x + 94x^2 mod 1613, at x-coordinates
+    // bigger than the number of shares, and one bigger than any int.
+    field = newPrimeField("1613", big.NewInt(1613))
+    big_x, _ := new(big.Int).SetString("100000000000000000000", 10)
+    poly := polynomial{elements(field, []*big.Int{big.NewInt(1234), big.NewInt(166), big.NewInt(94)})}
+    points = []xyPair{}
+    for _, x := range([]*big.Int{big.NewInt(1000), big.NewInt(77), big_x}) {
+        points = append(points, xyPair{x, evaluatePolynomial(x, field, poly)})
+    }
+    if result := field.ToBig(lagrange(points, field)); result.Cmp(expected) != 0 {
+        t.Errorf("Expecting %s, got: %s", expected, result)
+    }
 }
 
-func TestBigIntStringEncodingDecoding(t *testing.T) {
+func TestElementStringEncodingDecoding(t *testing.T) {
+    field, _ := lookupField(DEFAULT_FIELD)
     str := "Hello, world!"
-    enc := stringToBigInt(str)
-    result := bigIntToString(enc)
+    enc := stringToElement(str, field)
+    if expected := new(big.Int).SetBytes([]byte(str)); field.ToBig(enc).Cmp(expected) != 0 {
+        t.Errorf("Expecting %s, got: %s", expected, field.ToBig(enc))
+    }
+    result := elementToString(enc, field)
 
     if result != str {
         t.Errorf("Expecting %s, got: %s", str, result)
     }
+
+    // Whole chunks keep the NUL bytes they start with.
+    str = "\x00\x00Hello, world!"
+    if result := elementToChunk(stringToElement(str, field), len(str), field); result != str {
+        t.Errorf("Expecting %q, got: %q", str, result)
+    }
 }
 
 func TestIsASCII(t *testing.T) {
@@ -119,21 +145,25 @@ func TestSplitStringIntoChunks(t *testing.T) {
 }
 
 func TestPairwiseJoinSlices(t *testing.T) {
-    s1 := []*big.Int{big.NewInt(23), big.NewInt(345)}
-    s2 := []*big.Int{big.NewInt(100), big.NewInt(99)}
-    s3 := []*big.Int{big.NewInt(19), big.NewInt(50)}
-    subsecret_shares := [][]*big.Int{s1, s2, s3}
-    result := pairwiseJoinSlices(subsecret_shares)
+    field, _ := lookupField(DEFAULT_FIELD)
+    s1 := elements(field, []*big.Int{big.NewInt(23), big.NewInt(345)})
+    s2 := elements(field, []*big.Int{big.NewInt(100), big.NewInt(99)})
+    s3 := elements(field, []*big.Int{big.NewInt(19), big.NewInt(50)})
+    subsecret_shares := [][]Element{s1, s2, s3}
+    result, err := pairwiseJoinSlices(subsecret_shares, field)
+    if err != nil {
+        t.Fatal(err)
+    }
     expected := []string{"23+100+19", "345+99+50"}
 
     if (result[0] != expected[0]) || (result[1] != expected[1])  {
         t.Errorf("Expecting %s, got: %s", expected, result)
     }
 
-    s1 = []*big.Int{big.NewInt(321), big.NewInt(701183), big.NewInt(15263), big.NewInt(2574), big.NewInt(417)}
-    s2 = []*big.Int{big.NewInt(117465), big.NewInt(599), big.NewInt(1207), big.NewInt(1752), big.NewInt(40624)}
-    subsecret_shares = [][]*big.Int{s1, s2}
-    result = pairwiseJoinSlices(subsecret_shares)
+    s1 = elements(field, []*big.Int{big.NewInt(321), big.NewInt(701183), big.NewInt(15263), big.NewInt(2574), big.NewInt(417)})
+    s2 = elements(field, []*big.Int{big.NewInt(117465), big.NewInt(599), big.NewInt(1207), big.NewInt(1752), big.NewInt(40624)})
+    subsecret_shares = [][]Element{s1, s2}
+    result, _ = pairwiseJoinSlices(subsecret_shares, field)
     expected = []string{"321+117465", "701183+588", "15263+1207", "2574+1752", "417+48624"}
 
     for i := 0; i < len(result); i++ {
@@ -143,14 +173,18 @@ func TestPairwiseJoinSlices(t *testing.T) {
     }
 }
 
-func TestCreateSubsecretSliceMap(t *testing.T) {
+func TestCreateSubsecretSlices(t *testing.T) {
+    field, _ := lookupField(DEFAULT_FIELD)
     s := []string{"2", "334343", "4", "32312321"}
-    result := createSubsecretSliceMap(s)
-    m1 := map[int]big.Int{
-        2 : *big.NewInt(334343),
-        4 : *big.NewInt(32312321),
+    result, err := createSubsecretSlices(s, field)
+    if err != nil {
+        t.Fatal(err)
     }
-    expected := []map[int]big.Int{m1}
+    m1 := []xyPair{
+        {big.NewInt(2), field.FromBig(big.NewInt(334343))},
+        {big.NewInt(4), field.FromBig(big.NewInt(32312321))},
+    }
+    expected := [][]xyPair{m1}
     if len(expected) != len(result) {
         t.Error("Expected slice length is %i, result length is %i", len(expected), len(result))
     }
@@ -161,12 +195,12 @@ func TestCreateSubsecretSliceMap(t *testing.T) {
     }
 
     s = []string{"2", "334343+23232", "4", "32312321+2312312"}
-    result = createSubsecretSliceMap(s)
-    m2 := map[int]big.Int{
-        2 : *big.NewInt(23232),
-        4 : *big.NewInt(2312312),
+    result, _ = createSubsecretSlices(s, field)
+    m2 := []xyPair{
+        {big.NewInt(2), field.FromBig(big.NewInt(23232))},
+        {big.NewInt(4), field.FromBig(big.NewInt(2312312))},
     }
-    expected = []map[int]big.Int{m1, m2}
+    expected = [][]xyPair{m1, m2}
     if len(expected) != len(result) {
         t.Error("Expected slice length is %i, result length is %i", len(expected), len(result))
     }
@@ -177,12 +211,12 @@ func TestCreateSubsecretSliceMap(t *testing.T) {
     }
 
     s = []string{"2", "334343+23232+0", "4", "32312321+2312312+234"}
-    result = createSubsecretSliceMap(s)
-    m3 := map[int]big.Int{
-        2 : *big.NewInt(0),
-        4 : *big.NewInt(234),
+    result, _ = createSubsecretSlices(s, field)
+    m3 := []xyPair{
+        {big.NewInt(2), field.FromBig(big.NewInt(0))},
+        {big.NewInt(4), field.FromBig(big.NewInt(234))},
     }
-    expected = []map[int]big.Int{m1, m2, m3}
+    expected = [][]xyPair{m1, m2, m3}
     if len(expected) != len(result) {
         t.Error("Expected slice length is %i, result length is %i", len(expected), len(result))
     }
@@ -192,3 +226,217 @@ func TestCreateSubsecretSliceMap(t *testing.T) {
         }
     }
 }
+
+func TestValidCombineParameters(t *testing.T) {
+    if err := validCombineParameters([]string{"2", "334343+23232", "4", "32312321+2312312"}); err != nil {
+        t.Errorf("Expected valid parameters, got: %s", err)
+    }
+
+    invalid := [][]string{
+        {"2", "334343"},
+        {"2", "334343", "4"},
+        {"2", "334343+23232", "4", "32312321"},
+        {"2", "334343", "4", "3231abc"},
+        {"two", "334343", "4", "32312321"},
+    }
+    for _, s := range(invalid) {
+        if err := validCombineParameters(s); err == nil {
+            t.Errorf("Expected %s to be rejected", s)
+        }
+    }
+}
+
+func TestPairwiseJoinSlicesUnequalLengths(t *testing.T) {
+    field, _ := lookupField(DEFAULT_FIELD)
+    s1 := elements(field, []*big.Int{big.NewInt(23), big.NewInt(345)})
+    s2 := elements(field, []*big.Int{big.NewInt(100)})
+    if _, err := pairwiseJoinSlices([][]Element{s1, s2}, field); !errors.Is(err, ErrInvalidParameters) {
+        t.Errorf("Expected ErrInvalidParameters, got: %v", err)
+    }
+}
+
+func TestCreateSubsecretSlicesErrors(t *testing.T) {
+    field, _ := lookupField(DEFAULT_FIELD)
+    tests := []struct {
+        input []string
+        expected error
+    }{
+        {[]string{"2", "334343", "2", "32312321"}, ErrDuplicateIndex},
+        {[]string{"two", "334343", "4", "32312321"}, ErrMalformedShare},
+        {[]string{"2", "334343+1", "4", "32312321"}, ErrMalformedShare},
+        {[]string{"2", "33x343", "4", "32312321"}, ErrMalformedShare},
+        {[]string{"0", "334343", "4", "32312321"}, ErrMalformedShare},
+        {[]string{PRIME, "334343", "4", "32312321"}, ErrMalformedShare},
+    }
+    for _, test := range(tests) {
+        if _, err := createSubsecretSlices(test.input, field); !errors.Is(err, test.expected) {
+            t.Errorf("Expected %v for %s, got: %v", test.expected, test.input, err)
+        }
+    }
+}
+
+func TestSplitCombine(t *testing.T) {
+    field, _ := lookupField(DEFAULT_FIELD)
+    secret := "Hello, World! This is my secret."
+    shares, err := splitSecret(secret, 5, 3, field)
+    if err != nil {
+        t.Fatal(err)
+    }
+    input := []string{"1", shares[0], "3", shares[2], "5", shares[4]}
+    result, err := combineShares(input, field)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if result != secret {
+        t.Errorf("Expecting %s, got: %s", secret, result)
+    }
+
+    if _, err := splitSecret(secret, 2, 3, field); !errors.Is(err, ErrInvalidParameters) {
+        t.Errorf("Expected ErrInvalidParameters, got: %v", err)
+    }
+    if _, err := combineShares([]string{"1", shares[0]}, field); !errors.Is(err, ErrThresholdNotMet) {
+        t.Errorf("Expected ErrThresholdNotMet, got: %v", err)
+    }
+    if _, err := combineShares([]string{"1", shares[0], "1", shares[0]}, field); !errors.Is(err, ErrDuplicateIndex) {
+        t.Errorf("Expected ErrDuplicateIndex, got: %v", err)
+    }
+}
+
+func TestSplitCombineAtXCoordinates(t *testing.T) {
+    field, _ := lookupField(DEFAULT_FIELD)
+    secret := "Hello, World! This is my secret."
+    xs, err := parseXCoordinates("random", 5, field, rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    seen := make(map[string]bool)
+    for _, x := range(xs) {
+        if x.Sign() < 1 || x.Cmp(field.Size()) >= 0 || seen[x.String()] {
+            t.Errorf("Bad random x-coordinate %s in %v", x, xs)
+        }
+        seen[x.String()] = true
+    }
+    shares, err := splitSecretAt(context.Background(), secret, xs, 3, field, 0, rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    input := []string{xs[4].String(), shares[4], xs[0].String(), shares[0], xs[2].String(), shares[2]}
+    if result, err := combineShares(input, field); err != nil || result != secret {
+        t.Errorf("Expecting %s, got: %s (%v)", secret, result, err)
+    }
+
+    xs, err = parseXCoordinates("17, 4242,99", 3, field, rand.Reader)
+    if err != nil || len(xs) != 3 || xs[1].Cmp(big.NewInt(4242)) != 0 {
+        t.Errorf("Expecting [17 4242 99], got: %v (%v)", xs, err)
+    }
+    for _, s := range([]string{"1,2", "1,2,3,4", "1,2,02", "0,1,2", "1,2,x", "1,2," + PRIME}) {
+        if _, err := parseXCoordinates(s, 3, field, rand.Reader); !errors.Is(err, ErrInvalidParameters) {
+            t.Errorf("Expected %q to be rejected, got: %v", s, err)
+        }
+    }
+}
+
+// The Lagrange formula with a ModInverse for every pair of points, as combine
+// worked it out for every subsecret before the weights were shared.
+func lagrangePerPoint(points []xyPair, field Field) *big.Int {
+    modulus := field.Size()
+    result := big.NewInt(0)
+    for i, p := range(points) {
+        prod := big.NewInt(1)
+        for j, m := range(points) {
+            if j == i {
+                continue
+            }
+            d := new(big.Int).Sub(m.x, p.x)
+            d.Mod(d, modulus)
+            d.ModInverse(d, modulus)
+            d.Mul(m.x, d)
+            prod.Mul(prod, d)
+            prod.Mod(prod, modulus)
+        }
+        result.Add(result, new(big.Int).Mul(field.ToBig(p.y), prod))
+    }
+    return result.Mod(result, modulus)
+}
+
+func TestBatchInverse(t *testing.T) {
+    field := newPrimeField("1613", big.NewInt(1613))
+    values := []*big.Int{big.NewInt(2), big.NewInt(1612), big.NewInt(1), big.NewInt(777), big.NewInt(1000)}
+    inverses := batchInverse(elements(field, values), field)
+    for i, v := range(values) {
+        if expected := new(big.Int).ModInverse(v, field.Size()); field.ToBig(inverses[i]).Cmp(expected) != 0 {
+            t.Errorf("Expecting %s as the inverse of %s, got: %s", expected, v, field.ToBig(inverses[i]))
+        }
+    }
+    if batchInverse(elements(field, []*big.Int{big.NewInt(5), big.NewInt(0)}), field) != nil {
+        t.Errorf("Expecting nil when a value is not invertible")
+    }
+}
+
+func TestLagrangeWeights(t *testing.T) {
+    field, _ := lookupField(DEFAULT_FIELD)
+    xs, _ := randomFieldXCoordinates(7, field, rand.Reader)
+    weights := lagrangeWeights(xs, field)
+    for trial := 0; trial < 5; trial++ {
+        points := []xyPair{}
+        ys := []Element{}
+        for _, x := range(xs) {
+            y, _ := field.Random(rand.Reader)
+            points = append(points, xyPair{x, y})
+            ys = append(ys, y)
+        }
+        expected := lagrangePerPoint(points, field)
+        if result := field.ToBig(lagrangeWithWeights(ys, weights, field)); result.Cmp(expected) != 0 {
+            t.Errorf("Expecting %s, got: %s", expected, result)
+        }
+    }
+    if lagrangeWeights([]*big.Int{big.NewInt(3), big.NewInt(3)}, field) != nil {
+        t.Errorf("Expecting nil weights for a repeated x-coordinate")
+    }
+}
+
+// Recovering 4096 subsecrets (about 60 KB) from 5 shares, working out the
+// Lagrange basis for every subsecret and once for all of them.
+func benchmarkSubsecrets(b *testing.B) ([][]xyPair, Field) {
+    field, _ := lookupField(DEFAULT_FIELD)
+    xs := sequentialXCoordinates(5)
+    subsecrets := [][]xyPair{}
+    for i := 0; i < 4096; i++ {
+        points := []xyPair{}
+        for _, x := range(xs) {
+            y, _ := field.Random(rand.Reader)
+            points = append(points, xyPair{x, y})
+        }
+        subsecrets = append(subsecrets, points)
+    }
+    b.ReportAllocs()
+    b.ResetTimer()
+    return subsecrets, field
+}
+
+func BenchmarkLagrangePerSubsecret(b *testing.B) {
+    subsecrets, field := benchmarkSubsecrets(b)
+    for i := 0; i < b.N; i++ {
+        for _, points := range(subsecrets) {
+            lagrangePerPoint(points, field)
+        }
+    }
+}
+
+func BenchmarkLagrangeSharedWeights(b *testing.B) {
+    subsecrets, field := benchmarkSubsecrets(b)
+    for i := 0; i < b.N; i++ {
+        xs := []*big.Int{}
+        for _, p := range(subsecrets[0]) {
+            xs = append(xs, p.x)
+        }
+        weights := lagrangeWeights(xs, field)
+        ys := make([]Element, len(xs))
+        for _, points := range(subsecrets) {
+            for j, p := range(points) {
+                ys[j] = p.y
+            }
+            lagrangeWithWeights(ys, weights, field)
+        }
+    }
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+    "crypto/ed25519"
+    "crypto/sha256"
+    "encoding/base64"
+    "fmt"
+    "math/big"
+    "strconv"
+    "strings"
+)
+
+// Prefix of a share written as a self-contained record rather than as a bare
+// "index payload" pair.
+const SHARE_RECORD_PREFIX = "shamir1"
+
+// A share together with the metadata needed to check where it came from.
+// Payload is the '+'-joined subsecret shares, exactly as in a bare share.
+// The index is the share's x-coordinate, and n is 0 for splits at chosen
+// x-coordinates, where the number of shares is not given away. The field is
+// the name of the field the secret was split over, empty for the default.
+type shareRecord struct {
+    index     *big.Int
+    splitID   string
+    field     string
+    n         int
+    t         int
+    payload   string
+    signature []byte
+}
+
+// Formats a record as a single token:
+// shamir1:<split-id>:<n>:<t>:<index>:<payload>:<signature>[:<field>]
+// The signature is unpadded URL-safe base64 and may be empty. The field is
+// left off for the default field.
+func (r shareRecord) String() string {
+    s := fmt.Sprintf("%s:%s:%d:%d:%d:%s:%s", SHARE_RECORD_PREFIX, r.splitID, r.n, r.t,
+        r.index, r.payload, base64.RawURLEncoding.EncodeToString(r.signature))
+    if r.field != "" {
+        s += ":" + r.field
+    }
+    return s
+}
+
+// The name of the field the record's secret was split over.
+func (r shareRecord) fieldName() string {
+    if r.field == "" {
+        return DEFAULT_FIELD
+    }
+    return r.field
+}
+
+func isShareRecord(s string) bool {
+    return strings.HasPrefix(s, SHARE_RECORD_PREFIX+":")
+}
+
+// Parses a record written by shareRecord.String.
+func parseShareRecord(s string) (shareRecord, error) {
+    fields := strings.Split(s, ":")
+    if (len(fields) != 7 && len(fields) != 8) || fields[0] != SHARE_RECORD_PREFIX {
+        return shareRecord{}, newError(ErrMalformedShare, "Share records must be of the form 'shamir1:split-id:n:t:index:payload:signature', optionally followed by ':field'.")
+    }
+    r := shareRecord{splitID: fields[1], payload: fields[5]}
+    if len(fields) == 8 {
+        if _, err := lookupField(fields[7]); err != nil || fields[7] == DEFAULT_FIELD {
+            return shareRecord{}, newError(ErrMalformedShare, fmt.Sprintf("Bad field %q in share record.", fields[7]))
+        }
+        r.field = fields[7]
+    }
+    var err error
+    if r.n, err = strconv.Atoi(fields[2]); err != nil {
+        return shareRecord{}, newError(ErrMalformedShare, fmt.Sprintf("Bad share count %q in share record.", fields[2]))
+    }
+    if r.t, err = strconv.Atoi(fields[3]); err != nil {
+        return shareRecord{}, newError(ErrMalformedShare, fmt.Sprintf("Bad threshold %q in share record.", fields[3]))
+    }
+    if r.index, err = parseShareNumber(fields[4], nil); err != nil {
+        return shareRecord{}, newError(ErrMalformedShare, fmt.Sprintf("Bad share number %q in share record.", fields[4]))
+    }
+    if r.signature, err = base64.RawURLEncoding.DecodeString(fields[6]); err != nil {
+        return shareRecord{}, newError(ErrMalformedShare, "Malformed signature in share record.")
+    }
+    return r, nil
+}
+
+// The bytes covered by the dealer signature: the share number, split ID,
+// n, t, a digest of the payload and, unless it is the default, the field.
+func (r shareRecord) signedMessage() []byte {
+    digest := sha256.Sum256([]byte(r.payload))
+    msg := fmt.Sprintf("shamir-share-v1\n%s\n%d\n%d\n%d\n%x", r.splitID, r.n, r.t, r.index, digest)
+    if r.field != "" {
+        msg += "\n" + r.field
+    }
+    return []byte(msg)
+}
+
+func (r *shareRecord) sign(key ed25519.PrivateKey) {
+    r.signature = ed25519.Sign(key, r.signedMessage())
+}
+
+func (r shareRecord) verify(key ed25519.PublicKey) error {
+    if len(r.signature) == 0 {
+        return newError(ErrUntrustedShare, fmt.Sprintf("Share %d is not signed.", r.index))
+    }
+    if !ed25519.Verify(key, r.signedMessage(), r.signature) {
+        return newError(ErrUntrustedShare, fmt.Sprintf("Share %d: dealer signature does not verify.", r.index))
+    }
+    return nil
+}
+
+// Parses a share given as a single token: either a shamir1 text record or a
+// binary record in one of the share encodings.
+func parseShareToken(s string) (shareRecord, error) {
+    if isShareRecord(s) {
+        return parseShareRecord(s)
+    }
+    r, _, err := decodeShareRecord(s)
+    return r, err
+}
+
+// Splits combine arguments into bare "index payload" pairs, converting any
+// share records into the same form so the rest of combine does not need to
+// know about them. The records found are returned alongside. Anything that is
+// not a share number is taken to be a record, and a run of unquoted mnemonic
+// words is taken to be as many records as their length prefixes say.
+// E.g. ["1", "23+100", "shamir1:ab12:3:2:2:345+99:sig"] returns
+// ["1", "23+100", "2", "345+99"] and the record for share 2.
+func parseShareArgs(args []string) ([]string, []shareRecord, error) {
+    pairs := []string{}
+    records := []shareRecord{}
+    for i := 0; i < len(args); i++ {
+        if !isShareNumber(args[i]) {
+            token := args[i]
+            if count := mnemonicArgCount(args[i:]); count > 0 {
+                token = strings.Join(args[i:i+count], " ")
+                i += count - 1
+            }
+            r, err := parseShareToken(token)
+            if err != nil {
+                return nil, nil, err
+            }
+            records = append(records, r)
+            pairs = append(pairs, r.index.String(), r.payload)
+            continue
+        }
+        if i+1 >= len(args) {
+            return nil, nil, newError(ErrMalformedShare, "Share number given without a share.")
+        }
+        pairs = append(pairs, args[i], args[i+1])
+        i++
+    }
+    return pairs, records, nil
+}
+
+// Checks that every share is a record signed by the trusted dealer key, and
+// that they all belong to the same split.
+func verifyShareRecords(pairs []string, records []shareRecord, key ed25519.PublicKey) error {
+    if len(records) != len(pairs)/2 {
+        return newError(ErrUntrustedShare, "All shares must be signed share records when a trusted dealer key is given.")
+    }
+    for _, r := range records {
+        if err := r.verify(key); err != nil {
+            return err
+        }
+        if r.splitID != records[0].splitID {
+            return newError(ErrUntrustedShare, "Shares come from different splits.")
+        }
+    }
+    return nil
+}
+
+// Checks that every share record is from the split with the given ID, if one
+// is given.
+func checkRecordSplitID(records []shareRecord, split_id string) error {
+    for _, r := range records {
+        if split_id != "" && r.splitID != split_id {
+            return newError(ErrMalformedShare, fmt.Sprintf("Share %s is from split %s, not %s.", r.index, r.splitID, split_id))
+        }
+    }
+    return nil
+}
+
+// Checks that at least as many shares were given as the threshold recorded in
+// any of the share records.
+func checkRecordThreshold(records []shareRecord, count int) error {
+    for _, r := range records {
+        if count < r.t {
+            return newError(ErrThresholdNotMet, fmt.Sprintf("Need %d shares to recover the secret, got %d.", r.t, count))
+        }
+    }
+    return nil
+}
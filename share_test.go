@@ -0,0 +1,89 @@
+package main
+
+import (
+    "crypto/ed25519"
+    "errors"
+    "math/big"
+    "reflect"
+    "testing"
+)
+
+func TestShareRecordEncodingDecoding(t *testing.T) {
+    record := shareRecord{index: big.NewInt(4), splitID: "0badc0ffee", n: 6, t: 4, payload: "23+100+19", signature: []byte{1, 2, 3}}
+    result, err := parseShareRecord(record.String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(record, result) {
+        t.Errorf("Expecting %v, got: %v", record, result)
+    }
+
+    if _, err := parseShareRecord("shamir1:0badc0ffee:6:4:x:23+100+19:"); err == nil {
+        t.Errorf("Expected a bad share number to be rejected")
+    }
+}
+
+func TestShareRecordSignature(t *testing.T) {
+    pub, priv, _ := ed25519.GenerateKey(nil)
+    record := shareRecord{index: big.NewInt(2), splitID: "0badc0ffee", n: 3, t: 2, payload: "345+99+50"}
+    if err := record.verify(pub); err == nil {
+        t.Errorf("Expected unsigned share to be rejected")
+    }
+    record.sign(priv)
+    if err := record.verify(pub); err != nil {
+        t.Errorf("Expected signed share to verify, got: %s", err)
+    }
+
+    // Substituting any signed field must invalidate the signature.
+    tampered := []shareRecord{record, record, record, record}
+    tampered[0].payload = "345+99+51"
+    tampered[1].index = big.NewInt(3)
+    tampered[2].splitID = "0badc0ffef"
+    tampered[3].t = 1
+    for _, r := range(tampered) {
+        if err := r.verify(pub); err == nil {
+            t.Errorf("Expected tampered share %v to be rejected", r)
+        }
+    }
+
+    otherPub, _, _ := ed25519.GenerateKey(nil)
+    if err := record.verify(otherPub); err == nil {
+        t.Errorf("Expected share signed by another dealer to be rejected")
+    }
+}
+
+func TestParseShareArgs(t *testing.T) {
+    record := shareRecord{index: big.NewInt(2), splitID: "ab12", n: 3, t: 2, payload: "345+99"}
+    args := []string{"1", "23+100", record.String()}
+    pairs, records, err := parseShareArgs(args)
+    if err != nil {
+        t.Fatal(err)
+    }
+    expected := []string{"1", "23+100", "2", "345+99"}
+    if !reflect.DeepEqual(expected, pairs) {
+        t.Errorf("Expecting %s, got: %s", expected, pairs)
+    }
+    if len(records) != 1 || records[0].splitID != "ab12" {
+        t.Errorf("Expecting one record from split ab12, got: %v", records)
+    }
+
+    _, priv, _ := ed25519.GenerateKey(nil)
+    record.sign(priv)
+    if err := verifyShareRecords(pairs, records, priv.Public().(ed25519.PublicKey)); err == nil {
+        t.Errorf("Expected bare share to be rejected when a dealer key is trusted")
+    }
+
+    if err := checkRecordSplitID(records, "ab12"); err != nil {
+        t.Errorf("Expected share from split ab12 to be accepted, got: %s", err)
+    }
+    if err := checkRecordSplitID(records, ""); err != nil {
+        t.Errorf("Expected any split to be accepted without a split ID, got: %s", err)
+    }
+    if err := checkRecordSplitID(records, "cd34"); !errors.Is(err, ErrMalformedShare) {
+        t.Errorf("Expected share from split ab12 to be rejected for split cd34, got: %v", err)
+    }
+
+    if _, _, err := parseShareArgs([]string{"1", "23+100", "2"}); err == nil {
+        t.Errorf("Expected share number without share to be rejected")
+    }
+}
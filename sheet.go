@@ -0,0 +1,172 @@
+package main
+
+import (
+    "crypto/sha256"
+    "fmt"
+    "html/template"
+    "strings"
+    "time"
+)
+
+// Printable share sheets: one self-contained HTML page per share, written
+// by split -print-dir, to be printed on an air-gapped machine and sealed in
+// an envelope for the holder. Each page has the share both as words, for
+// typing back, and as a QR code, for combine -from-images.
+
+type shareSheet struct {
+    Title        string
+    Holder       string
+    Scheme       string
+    SplitID      string
+    Threshold    string
+    Created      string
+    Checksum     string
+    Words        []string
+    Text         string
+    QR           template.HTML
+    Instructions []string
+}
+
+var shareSheetTemplate = template.Must(template.New("sheet").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+@page { size: auto; margin: 15mm; }
+body { font-family: sans-serif; max-width: 180mm; margin: 0 auto; color: #000; }
+h1 { font-size: 20pt; margin-bottom: 4mm; }
+table.meta td { padding: 1mm 4mm 1mm 0; vertical-align: top; }
+table.meta td:first-child { font-weight: bold; }
+.qr { float: right; width: 60mm; height: 60mm; margin-left: 6mm; }
+ol.words { columns: 4; font-family: monospace; font-size: 12pt; padding-left: 8mm; }
+.text { font-family: monospace; font-size: 10pt; word-break: break-all; border: 1px solid #000; padding: 2mm; }
+.notice { border-top: 1px solid #000; margin-top: 8mm; font-size: 10pt; clear: both; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="qr">{{.QR}}</div>
+<table class="meta">
+<tr><td>Holder</td><td>{{if .Holder}}{{.Holder}}{{else}}&nbsp;{{end}}</td></tr>
+<tr><td>Scheme</td><td>{{.Scheme}}</td></tr>
+<tr><td>Split ID</td><td>{{.SplitID}}</td></tr>
+<tr><td>Threshold</td><td>{{.Threshold}}</td></tr>
+<tr><td>Created</td><td>{{.Created}}</td></tr>
+<tr><td>Checksum</td><td>{{.Checksum}}</td></tr>
+</table>
+{{if .Words}}<h2>Share words</h2>
+<ol class="words">{{range .Words}}<li>{{.}}</li>{{end}}</ol>
+{{else}}<h2>Share</h2>
+<p class="text">{{.Text}}</p>
+{{end}}<div class="notice">
+<h2>Recovering the secret</h2>
+{{range .Instructions}}<p>{{.}}</p>
+{{end}}<p>The checksum is the first eight hex digits of the SHA-256 digest of
+the text in the QR code; it lets you check a typed-in share before use.</p>
+<p>Keep this sheet sealed and private. On its own it reveals nothing about
+the secret, but anyone who collects enough shares can recover it.</p>
+</div>
+</body>
+</html>
+`))
+
+// A short fingerprint of the share text, to check a transcription against.
+func shareChecksum(text string) string {
+    digest := sha256.Sum256([]byte(text))
+    return fmt.Sprintf("%x %x", digest[:2], digest[2:4])
+}
+
+// The share written as words: the record as BIP-39 words for native shares,
+// the mnemonic for SLIP-39 and Bytewords for SSKR. Vault and ssss shares have
+// no word form.
+func shareWords(res jsonSplit, share jsonShare) ([]string, error) {
+    switch res.Scheme {
+        case "":
+            record, err := parseShareToken(shareQRText(res, share))
+            if err != nil {
+                return nil, err
+            }
+            enc, _ := lookupShareEncoding("words")
+            words, err := encodeShareRecord(record, enc)
+            return strings.Fields(words), err
+        case SLIP39_SCHEME:
+            return strings.Fields(share.Payload), nil
+        case SSKR_SCHEME:
+            s, err := parseSskrShare(share.Payload)
+            return strings.Fields(s.bytewords()), err
+    }
+    return nil, nil
+}
+
+// How to recover the secret, for the scheme of the split.
+func recoveryInstructions(res jsonSplit) []string {
+    lines := []string{}
+    switch res.Scheme {
+        case "":
+            shares := fmt.Sprintf("%d of the %d shares", res.T, res.N)
+            if res.N == 0 {
+                shares = fmt.Sprintf("%d shares", res.T)
+            }
+            lines = append(lines, fmt.Sprintf("Bring together %s of split %s and scan their QR codes with `shamir combine -from-images <scans>`, or type their words with `shamir combine <words>`.", shares, res.SplitID))
+        case SLIP39_SCHEME:
+            lines = append(lines, "Enter the words of enough shares into a SLIP-39 wallet, or use `shamir combine -scheme slip39 <words>` (or `-from-images <scans>`). If a passphrase was set, it is needed too.")
+        case SSKR_SCHEME:
+            lines = append(lines, "Scan the QR codes of enough shares with an SSKR-compatible wallet, or use `shamir combine -from-images <scans>`, or type the words with `shamir combine -scheme sskr <words>`.")
+        case VAULT_SCHEME:
+            lines = append(lines, fmt.Sprintf("Give %d unseal keys to `vault operator unseal`, or use `shamir combine -scheme vault <keys>`.", res.T))
+        case SSSS_SCHEME:
+            lines = append(lines, fmt.Sprintf("Give %d shares to `ssss-combine -t %d`, or use `shamir combine -scheme ssss -threshold %d <shares>`.", res.T, res.T, res.T))
+    }
+    if len(res.Groups) > 0 {
+        lines = append(lines, fmt.Sprintf("The shares are in groups: enough shares from each of %d of the %d groups are needed.", res.T, res.N))
+    }
+    return lines
+}
+
+// Renders the printable sheet of one share. Shares at chosen x-coordinates
+// are not numbered and do not say how many shares there are.
+func shareSheetHTML(res jsonSplit, share jsonShare, holder string, created time.Time) (string, error) {
+    text := shareQRText(res, share)
+    q, err := encodeQR(text)
+    if err != nil {
+        return "", err
+    }
+    words, err := shareWords(res, share)
+    if err != nil {
+        return "", err
+    }
+    svg := q.svg()
+    scheme := res.Scheme
+    if scheme == "" {
+        scheme = NATIVE_SCHEME
+    }
+    sheet := shareSheet{
+        Title: fmt.Sprintf("Secret share %d", share.Index),
+        Holder: holder,
+        Scheme: scheme,
+        SplitID: res.SplitID,
+        Threshold: fmt.Sprintf("%d of %d shares needed", res.T, res.N),
+        Created: created.UTC().Format("2006-01-02"),
+        Checksum: shareChecksum(text),
+        Words: words,
+        Text: text,
+        QR: template.HTML(svg[strings.Index(svg, "<svg"):]),
+        Instructions: recoveryInstructions(res),
+    }
+    if share.X != nil {
+        res.N = 0
+        sheet.Title = "Secret share"
+        sheet.Threshold = fmt.Sprintf("%d shares needed", res.T)
+        sheet.Instructions = recoveryInstructions(res)
+    }
+    if share.Group > 0 {
+        group := res.Groups[share.Group-1]
+        sheet.Title = fmt.Sprintf("Secret share %d of group %d", share.Index, share.Group)
+        sheet.Threshold = fmt.Sprintf("%d of %d shares of this group; %d of %d groups", group.T, group.N, res.T, res.N)
+    }
+
+    var b strings.Builder
+    err = shareSheetTemplate.Execute(&b, sheet)
+    return b.String(), err
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+    "crypto/rand"
+    "math/big"
+    "regexp"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestShareSheet(t *testing.T) {
+    res := jsonSplit{SplitID: "0123456789abcdef", N: 5, T: 3, Encoding: DECIMAL_ENCODING, Shares: []jsonShare{{Index: 2, Payload: "1234567890+42"}}}
+    created := time.Date(2024, 3, 9, 23, 30, 0, 0, time.UTC)
+    sheet, err := shareSheetHTML(res, res.Shares[0], "Ann <Treasurer>", created)
+    if err != nil {
+        t.Fatal(err)
+    }
+    text := "shamir1:0123456789abcdef:5:3:2:1234567890+42:"
+    for _, expected := range([]string{
+        "<title>Secret share 2</title>",
+        "Ann &lt;Treasurer&gt;",
+        "<td>0123456789abcdef</td>",
+        "<td>3 of 5 shares needed</td>",
+        "<td>2024-03-09</td>",
+        "<td>" + shareChecksum(text) + "</td>",
+        "<svg xmlns=",
+    }) {
+        if !strings.Contains(sheet, expected) {
+            t.Errorf("Expecting %q in the sheet", expected)
+        }
+    }
+
+    // The words on the sheet read back as the share.
+    words := []string{}
+    for _, m := range(regexp.MustCompile(`<li>([a-z]+)</li>`).FindAllStringSubmatch(sheet, -1)) {
+        words = append(words, m[1])
+    }
+    record, err := parseShareToken(strings.Join(words, " "))
+    if err != nil || record.String() != text {
+        t.Errorf("Expecting %q, got: %q (%v)", text, record.String(), err)
+    }
+}
+
+func TestShareSheetSchemes(t *testing.T) {
+    shares, _ := sskrSplit(make([]byte, 16), 2, []slip39Group{{2, 3}, {1, 1}}, rand.Reader)
+    res := jsonSplit{Scheme: SSKR_SCHEME, SplitID: "abcd", N: 2, T: 2, Encoding: "ur", Groups: []jsonGroup{{1, 3, 2}, {2, 1, 1}}}
+    share := jsonShare{Index: 3, Group: 1, Payload: shares[0][2].ur()}
+    sheet, err := shareSheetHTML(res, share, "", time.Now())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.Contains(sheet, "Secret share 3 of group 1") || !strings.Contains(sheet, "2 of 3 shares of this group; 2 of 2 groups") || !strings.Contains(sheet, "<li>tuna</li>") {
+        t.Errorf("Bad SSKR sheet: %s", sheet)
+    }
+
+    // Vault shares have no words, so the share is printed as is.
+    res = jsonSplit{Scheme: VAULT_SCHEME, SplitID: "0011223344556677", N: 3, T: 2, Encoding: "base64"}
+    sheet, err = shareSheetHTML(res, jsonShare{Index: 1, Payload: "3q2+7wc="}, "", time.Now())
+    if err != nil || !strings.Contains(sheet, "<p class=\"text\">3q2&#43;7wc=</p>") || strings.Contains(sheet, "<li>") {
+        t.Errorf("Bad Vault sheet (%v): %s", err, sheet)
+    }
+}
+
+// A sheet for a share at a chosen x-coordinate does not give away how many
+// shares there are.
+func TestShareSheetHidesShareCount(t *testing.T) {
+    res := jsonSplit{SplitID: "0123456789abcdef", N: 7, T: 3, Encoding: DECIMAL_ENCODING, Shares: []jsonShare{{Index: 7, X: big.NewInt(90210), Payload: "1234567890+42"}}}
+    sheet, err := shareSheetHTML(res, res.Shares[0], "", time.Now())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if strings.Contains(sheet, "share 7") || strings.Contains(sheet, "of 7") || !strings.Contains(sheet, "<title>Secret share</title>") || !strings.Contains(sheet, "<td>3 shares needed</td>") {
+        t.Errorf("Expecting a sheet without the share count: %s", sheet)
+    }
+    if text := shareQRText(res, res.Shares[0]); text != "shamir1:0123456789abcdef:0:3:90210:1234567890+42:" {
+        t.Errorf("Expecting the record at x = 90210, got: %q", text)
+    }
+}
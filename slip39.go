@@ -0,0 +1,559 @@
+package main
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "crypto/sha256"
+    _ "embed"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "math/big"
+    "strings"
+)
+
+// SLIP-39 (https://github.com/satoshilabs/slips/blob/master/slip-0039.md)
+// splits a master secret of 16 bytes or more in two levels: into groups, of
+// which a group threshold must be recovered, and each group into member
+// shares. Shares are mnemonics over a 1024 word list, protected by an RS1024
+// checksum, and the master secret is encrypted with a passphrase first so
+// that the same shares give a different secret for every passphrase.
+
+//go:embed wordlists/slip39_english.txt
+var slip39EnglishText string
+
+// The SLIP-39 word list. Each word stands for 10 bits, and no two words share
+// their first four letters.
+var slip39Words = strings.Fields(slip39EnglishText)
+
+// Maps every word, and the first four letters of every word, to its index.
+var slip39Index = func() map[string]int {
+    index := make(map[string]int)
+    for i, word := range(slip39Words) {
+        index[word] = i
+        if len(word) > 4 {
+            index[word[:4]] = i
+        }
+    }
+    return index
+}()
+
+const (
+    SLIP39_RADIX_BITS = 10
+    SLIP39_CHECKSUM_WORDS = 3
+    SLIP39_HEADER_WORDS = 4
+    SLIP39_MIN_SECRET_BYTES = 16
+    SLIP39_MAX_SHARES = 16
+    SLIP39_DIGEST_LENGTH = 4
+    SLIP39_DIGEST_INDEX = 254
+    SLIP39_SECRET_INDEX = 255
+    SLIP39_ROUNDS = 4
+    SLIP39_BASE_ITERATIONS = 10000
+)
+
+// The fewest words a share can have: the header, a 128 bit value and the
+// checksum.
+const SLIP39_MIN_WORDS = SLIP39_HEADER_WORDS + (8 * SLIP39_MIN_SECRET_BYTES + SLIP39_RADIX_BITS - 1) / SLIP39_RADIX_BITS + SLIP39_CHECKSUM_WORDS
+
+// A member share of a secret split in two levels: into groups, a threshold
+// of which recover the secret, and each group into members. SLIP-39 and SSKR
+// both share secrets this way. Indices are zero based and thresholds and
+// counts one based, as they are used.
+type groupShare struct {
+    groupIndex      int
+    groupThreshold  int
+    groupCount      int
+    memberIndex     int
+    memberThreshold int
+    value           []byte
+}
+
+// One SLIP-39 share, as encoded in a mnemonic. The mnemonic stores
+// thresholds and counts minus one.
+type slip39Share struct {
+    identifier        int
+    extendable        bool
+    iterationExponent int
+    groupShare
+}
+
+// A group of member shares: threshold of the count shares recover the group.
+type slip39Group struct {
+    threshold int
+    count     int
+}
+
+var rs1024Generator = [10]int{0xE0E040, 0x1C1C080, 0x3838100, 0x7070200, 0xE0E0009, 0x1C0C2412, 0x38086C24, 0x3090FC48, 0x21B1F890, 0x3F3F120}
+
+func rs1024Polymod(values []int) int {
+    chk := 1
+    for _, v := range(values) {
+        b := chk >> 20
+        chk = (chk & 0xfffff) << 10 ^ v
+        for i := range(rs1024Generator) {
+            if (b >> i) & 1 != 0 {
+                chk ^= rs1024Generator[i]
+            }
+        }
+    }
+    return chk
+}
+
+// The customization string mixed into the checksum, which differs for
+// extendable backups so the two kinds of share cannot be confused.
+func rs1024Customization(extendable bool) []int {
+    s := "shamir"
+    if extendable {
+        s = "shamir_extendable"
+    }
+    values := make([]int, len(s))
+    for i := range(s) {
+        values[i] = int(s[i])
+    }
+    return values
+}
+
+// The three checksum words for the data words.
+func rs1024Checksum(extendable bool, data []int) []int {
+    values := append(rs1024Customization(extendable), data...)
+    p := rs1024Polymod(append(values, 0, 0, 0)) ^ 1
+    return []int{(p >> 20) & 0x3ff, (p >> 10) & 0x3ff, p & 0x3ff}
+}
+
+func rs1024Verify(extendable bool, words []int) bool {
+    return rs1024Polymod(append(rs1024Customization(extendable), words...)) == 1
+}
+
+// PBKDF2 with HMAC-SHA256 (RFC 8018).
+func pbkdf2SHA256(password, salt []byte, iterations, length int) []byte {
+    prf := hmac.New(sha256.New, password)
+    key := []byte{}
+    for block := uint32(1); len(key) < length; block++ {
+        counter := make([]byte, 4)
+        binary.BigEndian.PutUint32(counter, block)
+        prf.Reset()
+        prf.Write(salt)
+        prf.Write(counter)
+        u := prf.Sum(nil)
+        t := append([]byte{}, u...)
+        for i := 1; i < iterations; i++ {
+            prf.Reset()
+            prf.Write(u)
+            u = prf.Sum(u[:0])
+            for j := range(t) {
+                t[j] ^= u[j]
+            }
+        }
+        key = append(key, t...)
+    }
+    return key[:length]
+}
+
+// Runs the four round Feistel network SLIP-39 uses to encrypt the master
+// secret with the passphrase, forwards to encrypt and backwards to decrypt.
+// Each round function is PBKDF2 keyed by the round number and passphrase and
+// salted with the right half, and for non-extendable backups the identifier.
+func slip39Feistel(data, passphrase []byte, iterationExponent, identifier int, extendable, encrypt bool) []byte {
+    half := len(data) / 2
+    l := append([]byte{}, data[:half]...)
+    r := append([]byte{}, data[half:]...)
+    salt := []byte{}
+    if !extendable {
+        salt = []byte{'s', 'h', 'a', 'm', 'i', 'r', byte(identifier >> 8), byte(identifier)}
+    }
+    iterations := (SLIP39_BASE_ITERATIONS << iterationExponent) / SLIP39_ROUNDS
+    for round := 0; round < SLIP39_ROUNDS; round++ {
+        i := round
+        if !encrypt {
+            i = SLIP39_ROUNDS - 1 - round
+        }
+        password := append([]byte{byte(i)}, passphrase...)
+        f := pbkdf2SHA256(password, append(append([]byte{}, salt...), r...), iterations, len(r))
+        for j := range(f) {
+            f[j] ^= l[j]
+        }
+        l, r = r, f
+    }
+    return append(r, l...)
+}
+
+// Splits secret into n shares of which t recover it, returned in order of
+// their x coordinate 0..n-1. For t > 1 the polynomial is fixed by t-2 random
+// shares, a digest of the secret at x = 254 and the secret itself at x = 255,
+// so combining shares that do not belong together is detected.
+func slip39SplitValue(secret []byte, n, t int, random io.Reader) ([][]byte, error) {
+    shares := make([][]byte, n)
+    if t == 1 {
+        for i := range(shares) {
+            shares[i] = append([]byte{}, secret...)
+        }
+        return shares, nil
+    }
+
+    xs := []byte{}
+    ys := [][]byte{}
+    for i := 0; i < t-2; i++ {
+        share, err := readRandom(random, len(secret))
+        if err != nil {
+            return nil, err
+        }
+        shares[i] = share
+        xs = append(xs, byte(i))
+        ys = append(ys, share)
+    }
+    random_part, err := readRandom(random, len(secret) - SLIP39_DIGEST_LENGTH)
+    if err != nil {
+        return nil, err
+    }
+    digest := slip39Digest(random_part, secret)
+    xs = append(xs, SLIP39_DIGEST_INDEX, SLIP39_SECRET_INDEX)
+    ys = append(ys, append(digest, random_part...), secret)
+    for i := t-2; i < n; i++ {
+        shares[i] = gf256Interpolate(xs, ys, byte(i))
+    }
+    return shares, nil
+}
+
+func slip39Digest(random_part, secret []byte) []byte {
+    mac := hmac.New(sha256.New, random_part)
+    mac.Write(secret)
+    return mac.Sum(nil)[:SLIP39_DIGEST_LENGTH]
+}
+
+// Recovers the value split by slip39SplitValue from t of its shares,
+// checking the digest.
+func slip39RecoverValue(xs []byte, ys [][]byte, t int) ([]byte, error) {
+    if t == 1 {
+        return ys[0], nil
+    }
+    secret := gf256Interpolate(xs, ys, SLIP39_SECRET_INDEX)
+    digest := gf256Interpolate(xs, ys, SLIP39_DIGEST_INDEX)
+    if !hmac.Equal(digest[:SLIP39_DIGEST_LENGTH], slip39Digest(digest[SLIP39_DIGEST_LENGTH:], secret)) {
+        return nil, newError(ErrMalformedShare, "Digest of the recovered secret does not match, the shares do not belong together.")
+    }
+    return secret, nil
+}
+
+// Checks a passphrase, which SLIP-39 limits to printable ASCII.
+func validSlip39Passphrase(passphrase []byte) error {
+    for _, c := range(passphrase) {
+        if c < 32 || c > 126 {
+            return newError(ErrInvalidParameters, "The passphrase must contain only printable ASCII characters.")
+        }
+    }
+    return nil
+}
+
+// Checks the master secret and group layout for a split.
+func validSlip39Parameters(secret []byte, groupThreshold int, groups []slip39Group, iterationExponent int) error {
+    if len(secret) < SLIP39_MIN_SECRET_BYTES || len(secret) % 2 != 0 {
+        return newError(ErrInvalidParameters, fmt.Sprintf("The master secret must be an even number of bytes, at least %d.", SLIP39_MIN_SECRET_BYTES))
+    }
+    if len(groups) < 1 || len(groups) > SLIP39_MAX_SHARES {
+        return newError(ErrInvalidParameters, fmt.Sprintf("There must be between 1 and %d groups.", SLIP39_MAX_SHARES))
+    }
+    if groupThreshold < 1 || groupThreshold > len(groups) {
+        return newError(ErrInvalidParameters, "The group threshold must be between 1 and the number of groups.")
+    }
+    for i, group := range(groups) {
+        if group.count < 1 || group.count > SLIP39_MAX_SHARES || group.threshold < 1 || group.threshold > group.count {
+            return newError(ErrInvalidParameters, fmt.Sprintf("Group %d: need 1 <= t <= n <= %d.", i+1, SLIP39_MAX_SHARES))
+        }
+        if group.threshold == 1 && group.count > 1 {
+            return newError(ErrInvalidParameters, fmt.Sprintf("Group %d: a threshold of 1 with more than one share is not allowed, use 1of1 instead.", i+1))
+        }
+    }
+    if iterationExponent < 0 || iterationExponent > 15 {
+        return newError(ErrInvalidParameters, "The iteration exponent must be between 0 and 15.")
+    }
+    return nil
+}
+
+// Splits a master secret into SLIP-39 shares, returned per group. The secret
+// is first encrypted with the passphrase, using 10000 << iterationExponent
+// PBKDF2 iterations in total. The identifier and shares are drawn from random.
+func slip39Split(secret, passphrase []byte, groupThreshold int, groups []slip39Group, iterationExponent int, extendable bool, random io.Reader) ([][]slip39Share, error) {
+    if err := validSlip39Parameters(secret, groupThreshold, groups, iterationExponent); err != nil {
+        return nil, err
+    }
+    if err := validSlip39Passphrase(passphrase); err != nil {
+        return nil, err
+    }
+    id, err := readRandom(random, 2)
+    if err != nil {
+        return nil, err
+    }
+    identifier := int(binary.BigEndian.Uint16(id)) >> 1
+
+    encrypted := slip39Feistel(secret, passphrase, iterationExponent, identifier, extendable, true)
+    shares, err := splitGroups(encrypted, groupThreshold, groups, random)
+    if err != nil {
+        return nil, err
+    }
+    res := make([][]slip39Share, len(shares))
+    for i, group := range(shares) {
+        for _, share := range(group) {
+            res[i] = append(res[i], slip39Share{identifier, extendable, iterationExponent, share})
+        }
+    }
+    return res, nil
+}
+
+// Splits secret into groups of which groupThreshold recover it, and each
+// group into its member shares, returned per group.
+func splitGroups(secret []byte, groupThreshold int, groups []slip39Group, random io.Reader) ([][]groupShare, error) {
+    group_values, err := slip39SplitValue(secret, len(groups), groupThreshold, random)
+    if err != nil {
+        return nil, err
+    }
+    res := make([][]groupShare, len(groups))
+    for i, group := range(groups) {
+        member_values, err := slip39SplitValue(group_values[i], group.count, group.threshold, random)
+        if err != nil {
+            return nil, err
+        }
+        for j, value := range(member_values) {
+            res[i] = append(res[i], groupShare{i, groupThreshold, len(groups), j, group.threshold, value})
+        }
+    }
+    return res, nil
+}
+
+// Writes the share as a mnemonic: the identifier, extendable flag and
+// iteration exponent, then the group and member fields, ten bits to a word,
+// followed by the value padded at the front to whole words and the checksum.
+func (s slip39Share) mnemonic() string {
+    header := s.identifier << 5 | s.iterationExponent
+    if s.extendable {
+        header |= 1 << 4
+    }
+    members := s.groupIndex << 16 | (s.groupThreshold - 1) << 12 | (s.groupCount - 1) << 8 | s.memberIndex << 4 | (s.memberThreshold - 1)
+    indices := []int{header >> 10, header & 0x3ff, members >> 10, members & 0x3ff}
+
+    value_words := make([]int, (8 * len(s.value) + SLIP39_RADIX_BITS - 1) / SLIP39_RADIX_BITS)
+    v := new(big.Int).SetBytes(s.value)
+    mask := big.NewInt(0x3ff)
+    for i := len(value_words) - 1; i >= 0; i-- {
+        value_words[i] = int(new(big.Int).And(v, mask).Int64())
+        v.Rsh(v, SLIP39_RADIX_BITS)
+    }
+    indices = append(indices, value_words...)
+    indices = append(indices, rs1024Checksum(s.extendable, indices)...)
+
+    words := make([]string, len(indices))
+    for i, index := range(indices) {
+        words[i] = slip39Words[index]
+    }
+    return strings.Join(words, " ")
+}
+
+// Looks up the words of a mnemonic in the SLIP-39 list.
+func slip39WordIndices(words []string) ([]int, error) {
+    indices := make([]int, len(words))
+    for i, word := range(words) {
+        index, ok := slip39Index[word]
+        if !ok {
+            msg := fmt.Sprintf("Word %d (%q) is not in the SLIP-39 word list.", i+1, word)
+            if similar := similarSlip39Words(word); len(similar) > 0 {
+                msg += " Did you mean " + strings.Join(similar, " or ") + "?"
+            }
+            return nil, newError(ErrMalformedShare, msg)
+        }
+        indices[i] = index
+    }
+    return indices, nil
+}
+
+// The words in the SLIP-39 list closest to word, within an edit distance of
+// two, quoted.
+func similarSlip39Words(word string) []string {
+    best := 3
+    res := []string{}
+    for _, candidate := range(slip39Words) {
+        d := editDistance(word, candidate)
+        if d < best {
+            best = d
+            res = []string{}
+        }
+        if d == best {
+            res = append(res, fmt.Sprintf("%q", candidate))
+        }
+    }
+    return res
+}
+
+// Parses a mnemonic written by slip39Share.mnemonic, checking its checksum
+// and padding.
+func parseSlip39Mnemonic(m string) (slip39Share, error) {
+    words := splitWords(m)
+    if len(words) < SLIP39_MIN_WORDS {
+        return slip39Share{}, newError(ErrMalformedShare, fmt.Sprintf("SLIP-39 shares must be at least %d words long.", SLIP39_MIN_WORDS))
+    }
+    indices, err := slip39WordIndices(words)
+    if err != nil {
+        return slip39Share{}, err
+    }
+
+    header := indices[0] << 10 | indices[1]
+    members := indices[2] << 10 | indices[3]
+    s := slip39Share{
+        identifier:        header >> 5,
+        extendable:        (header >> 4) & 1 == 1,
+        iterationExponent: header & 0xf,
+        groupShare: groupShare{
+            groupIndex:      members >> 16,
+            groupThreshold:  (members >> 12) & 0xf + 1,
+            groupCount:      (members >> 8) & 0xf + 1,
+            memberIndex:     (members >> 4) & 0xf,
+            memberThreshold: members & 0xf + 1,
+        },
+    }
+    if !rs1024Verify(s.extendable, indices) {
+        return slip39Share{}, newError(ErrMalformedShare, "SLIP-39 share checksum does not match, a word is wrong or missing.")
+    }
+    if s.groupThreshold > s.groupCount {
+        return slip39Share{}, newError(ErrMalformedShare, "SLIP-39 share has a group threshold above its group count.")
+    }
+
+    value_words := indices[SLIP39_HEADER_WORDS:len(indices)-SLIP39_CHECKSUM_WORDS]
+    padding := SLIP39_RADIX_BITS * len(value_words) % 16
+    if padding > 8 {
+        return slip39Share{}, newError(ErrMalformedShare, "SLIP-39 share has an invalid length.")
+    }
+    v := new(big.Int)
+    for _, index := range(value_words) {
+        v.Lsh(v, SLIP39_RADIX_BITS)
+        v.Or(v, big.NewInt(int64(index)))
+    }
+    length := (SLIP39_RADIX_BITS * len(value_words) - padding) / 8
+    if v.BitLen() > 8 * length {
+        return slip39Share{}, newError(ErrMalformedShare, "SLIP-39 share has non-zero padding.")
+    }
+    s.value = v.FillBytes(make([]byte, length))
+    return s, nil
+}
+
+// Splits combine arguments into mnemonics. The words may be given one per
+// argument, all the shares' words may be run together, or each share may be
+// quoted. All shares of a backup are the same length, which is found by
+// looking for the shortest run of words with a valid checksum.
+func splitSlip39Args(args []string) ([]string, error) {
+    words := splitWords(strings.Join(args, " "))
+    if len(words) == 0 {
+        return nil, newError(ErrThresholdNotMet, "No shares given.")
+    }
+    indices, err := slip39WordIndices(words)
+    if err != nil {
+        return nil, err
+    }
+    extendable := len(indices) > 1 && (indices[1] >> 4) & 1 == 1
+    length := len(words)
+    for l := SLIP39_MIN_WORDS; l < len(words); l++ {
+        if rs1024Verify(extendable, indices[:l]) {
+            length = l
+            break
+        }
+    }
+    if len(words) % length != 0 {
+        return nil, newError(ErrMalformedShare, "SLIP-39 shares must all have the same number of words.")
+    }
+    mnemonics := []string{}
+    for i := 0; i < len(words); i += length {
+        mnemonics = append(mnemonics, strings.Join(words[i:i+length], " "))
+    }
+    return mnemonics, nil
+}
+
+// Recovers the master secret from SLIP-39 mnemonics and the passphrase used
+// when splitting. Shares beyond the thresholds are ignored.
+func slip39Combine(mnemonics []string, passphrase []byte) ([]byte, error) {
+    if len(mnemonics) == 0 {
+        return nil, newError(ErrThresholdNotMet, "No shares given.")
+    }
+    if err := validSlip39Passphrase(passphrase); err != nil {
+        return nil, err
+    }
+    shares := make([]slip39Share, len(mnemonics))
+    members := make([]groupShare, len(mnemonics))
+    for i, m := range(mnemonics) {
+        s, err := parseSlip39Mnemonic(m)
+        if err != nil {
+            return nil, err
+        }
+        first := shares[0]
+        if i > 0 && (s.identifier != first.identifier || s.extendable != first.extendable || s.iterationExponent != first.iterationExponent) {
+            return nil, newError(ErrMalformedShare, "Shares do not belong to the same backup.")
+        }
+        shares[i] = s
+        members[i] = s.groupShare
+    }
+    encrypted, err := combineGroups(members)
+    if err != nil {
+        return nil, err
+    }
+    first := shares[0]
+    return slip39Feistel(encrypted, passphrase, first.iterationExponent, first.identifier, first.extendable, false), nil
+}
+
+// Recovers a secret split by splitGroups. The shares must all come from the
+// same split; shares beyond the thresholds are ignored.
+func combineGroups(shares []groupShare) ([]byte, error) {
+    first := shares[0]
+    for _, s := range(shares) {
+        if s.groupThreshold != first.groupThreshold || s.groupCount != first.groupCount || len(s.value) != len(first.value) {
+            return nil, newError(ErrMalformedShare, "Shares do not belong to the same backup.")
+        }
+        if s.groupIndex >= s.groupCount || s.groupThreshold > s.groupCount {
+            return nil, newError(ErrMalformedShare, "Share has a group number above its group count.")
+        }
+    }
+
+    // Collect the member shares of each group in the order given.
+    groups := make(map[int][]groupShare)
+    order := []int{}
+    for _, s := range(shares) {
+        members := groups[s.groupIndex]
+        if len(members) == 0 {
+            order = append(order, s.groupIndex)
+        } else if members[0].memberThreshold != s.memberThreshold {
+            return nil, newError(ErrMalformedShare, fmt.Sprintf("Shares of group %d disagree on the member threshold.", s.groupIndex+1))
+        }
+        for _, m := range(members) {
+            if m.memberIndex == s.memberIndex {
+                if bytes.Equal(m.value, s.value) {
+                    return nil, newError(ErrDuplicateIndex, fmt.Sprintf("Share %d of group %d was given more than once.", s.memberIndex+1, s.groupIndex+1))
+                }
+                return nil, newError(ErrDuplicateIndex, fmt.Sprintf("Two different shares numbered %d in group %d.", s.memberIndex+1, s.groupIndex+1))
+            }
+        }
+        groups[s.groupIndex] = append(members, s)
+    }
+
+    group_xs := []byte{}
+    group_ys := [][]byte{}
+    for _, index := range(order) {
+        members := groups[index]
+        if len(members) < members[0].memberThreshold || len(group_xs) == first.groupThreshold {
+            continue
+        }
+        members = members[:members[0].memberThreshold]
+        xs := make([]byte, len(members))
+        ys := make([][]byte, len(members))
+        for i, m := range(members) {
+            xs[i], ys[i] = byte(m.memberIndex), m.value
+        }
+        value, err := slip39RecoverValue(xs, ys, len(members))
+        if err != nil {
+            return nil, err
+        }
+        group_xs = append(group_xs, byte(index))
+        group_ys = append(group_ys, value)
+    }
+    if len(group_xs) < first.groupThreshold && first.groupCount == 1 {
+        return nil, newError(ErrThresholdNotMet, fmt.Sprintf("Need %d shares to recover the secret, got %d.", first.memberThreshold, len(shares)))
+    }
+    if len(group_xs) < first.groupThreshold {
+        return nil, newError(ErrThresholdNotMet, fmt.Sprintf("Need %d complete groups to recover the secret, got %d.", first.groupThreshold, len(group_xs)))
+    }
+    return slip39RecoverValue(group_xs, group_ys, first.groupThreshold)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+    "bytes"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "testing"
+)
+
+func TestSlip39WordList(t *testing.T) {
+    if len(slip39Words) != 1024 {
+        t.Errorf("Expected 1024 words, got %d", len(slip39Words))
+    }
+    if !sort.StringsAreSorted(slip39Words) {
+        t.Errorf("Word list is not sorted")
+    }
+    prefixes := make(map[string]bool)
+    for _, word := range(slip39Words) {
+        prefix := word
+        if len(prefix) > 4 {
+            prefix = prefix[:4]
+        }
+        if prefixes[prefix] {
+            t.Errorf("Prefix %q is not unique", prefix)
+        }
+        prefixes[prefix] = true
+    }
+}
+
+func TestGF256(t *testing.T) {
+    for a := 1; a < 256; a++ {
+        if gf256Mul(byte(a), gf256Div(1, byte(a))) != 1 {
+            t.Errorf("%d has no inverse", a)
+        }
+    }
+    // From the AES specification: {57} . {83} = {c1}
+    if gf256Mul(0x57, 0x83) != 0xc1 {
+        t.Errorf("Expecting c1, got: %x", gf256Mul(0x57, 0x83))
+    }
+}
+
+func TestPbkdf2SHA256(t *testing.T) {
+    // RFC 7914 section 11.
+    key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+    expected := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+    if hex.EncodeToString(key) != expected {
+        t.Errorf("Expecting %s, got: %x", expected, key)
+    }
+}
+
+// A case of vectors.json from the SLIP-39 specification: a description, the
+// mnemonics, and the master secret they give with the passphrase "TREZOR", or
+// "" if they must be rejected. Later entries, such as the BIP-32 master key,
+// are ignored.
+type slip39Vector struct {
+    description string
+    mnemonics   []string
+    secret      string
+}
+
+// Reads testdata/slip39/vectors.json, the official vectors.json of
+// trezor/python-shamir-mnemonic, all 45 cases, as vendored unchanged in
+// testdata/vectors.json of github.com/gavincarr/go-slip39 v0.1.0.
+func loadSlip39Vectors(t *testing.T) []slip39Vector {
+    data, err := os.ReadFile(filepath.Join("testdata", "slip39", "vectors.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var raw [][]json.RawMessage
+    if err := json.Unmarshal(data, &raw); err != nil {
+        t.Fatal(err)
+    }
+    vectors := []slip39Vector{}
+    for i, fields := range(raw) {
+        var v slip39Vector
+        if len(fields) < 3 {
+            t.Fatalf("vector %d: expected at least 3 fields, got %d", i+1, len(fields))
+        }
+        if json.Unmarshal(fields[0], &v.description) != nil || json.Unmarshal(fields[1], &v.mnemonics) != nil || json.Unmarshal(fields[2], &v.secret) != nil {
+            t.Fatalf("vector %d: malformed", i+1)
+        }
+        vectors = append(vectors, v)
+    }
+    return vectors
+}
+
+// The error a vector that must be rejected is expected to give, going by its
+// description, or nil if the description is not one this knows about.
+func slip39VectorError(description string) error {
+    description = strings.ToLower(description)
+    switch {
+        case strings.Contains(description, "duplicate member indices"):
+            return ErrDuplicateIndex
+        case strings.Contains(description, "insufficient number"), strings.Contains(description, "basic sharing"):
+            return ErrThresholdNotMet
+        case strings.Contains(description, "invalid checksum"), strings.Contains(description, "invalid padding"),
+            strings.Contains(description, "different identifiers"), strings.Contains(description, "different iteration exponents"),
+            strings.Contains(description, "mismatching"), strings.Contains(description, "greater group threshold"),
+            strings.Contains(description, "invalid digest"), strings.Contains(description, "invalid master secret length"),
+            strings.Contains(description, "insufficient length"):
+            return ErrMalformedShare
+    }
+    return nil
+}
+
+func TestSlip39Vectors(t *testing.T) {
+    vectors := loadSlip39Vectors(t)
+    if len(vectors) != 45 {
+        t.Fatalf("Expecting the 45 official cases, got %d", len(vectors))
+    }
+    for _, v := range(vectors) {
+        secret, err := slip39Combine(v.mnemonics, []byte("TREZOR"))
+        if v.secret == "" {
+            expected := slip39VectorError(v.description)
+            if expected == nil {
+                t.Errorf("%s: no expected error known for this case", v.description)
+            } else if !errors.Is(err, expected) {
+                t.Errorf("%s: expecting %v, got: %x (%v)", v.description, expected, secret, err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%s: unexpected error: %v", v.description, err)
+            continue
+        }
+        if hex.EncodeToString(secret) != v.secret {
+            t.Errorf("%s: expecting %s, got: %x", v.description, v.secret, secret)
+        }
+        // Re-encoding the parsed shares gives back the same words.
+        for _, m := range(v.mnemonics) {
+            s, _ := parseSlip39Mnemonic(m)
+            if s.mnemonic() != m {
+                t.Errorf("Expecting %q, got: %q", m, s.mnemonic())
+            }
+        }
+    }
+}
+
+func TestSlip39SplitCombine(t *testing.T) {
+    secret, _ := hex.DecodeString("00112233445566778899aabbccddeeff")
+    groups := []slip39Group{{2, 3}, {1, 1}, {3, 5}}
+    for _, extendable := range([]bool{false, true}) {
+        shares, err := slip39Split(secret, []byte("pass"), 2, groups, 0, extendable, rand.Reader)
+        if err != nil {
+            t.Fatal(err)
+        }
+        mnemonics := []string{shares[0][2].mnemonic(), shares[2][4].mnemonic(), shares[0][0].mnemonic(), shares[2][1].mnemonic(), shares[2][0].mnemonic()}
+        result, err := slip39Combine(mnemonics, []byte("pass"))
+        if err != nil || !bytes.Equal(result, secret) {
+            t.Errorf("Expecting %x, got: %x (%v)", secret, result, err)
+        }
+        result, err = slip39Combine([]string{shares[1][0].mnemonic(), shares[2][3].mnemonic(), shares[2][2].mnemonic(), shares[2][1].mnemonic()}, []byte("pass"))
+        if err != nil || !bytes.Equal(result, secret) {
+            t.Errorf("Expecting %x, got: %x (%v)", secret, result, err)
+        }
+        // A different passphrase gives a different secret rather than an error.
+        result, err = slip39Combine(mnemonics, nil)
+        if err != nil || bytes.Equal(result, secret) {
+            t.Errorf("Expecting a different secret, got: %x (%v)", result, err)
+        }
+        _, err = slip39Combine(mnemonics[:3], []byte("pass"))
+        if !errors.Is(err, ErrThresholdNotMet) {
+            t.Errorf("Expecting threshold not met error, got: %v", err)
+        }
+    }
+}
+
+func TestSlip39SplitErrors(t *testing.T) {
+    secret := make([]byte, 16)
+    tests := []struct {
+        secret         []byte
+        groupThreshold int
+        groups         []slip39Group
+    }{
+        {make([]byte, 15), 1, []slip39Group{{1, 1}}},
+        {make([]byte, 17), 1, []slip39Group{{1, 1}}},
+        {secret, 2, []slip39Group{{1, 1}}},
+        {secret, 1, []slip39Group{{1, 3}}},
+        {secret, 1, []slip39Group{{4, 3}}},
+        {secret, 1, []slip39Group{{2, 17}}},
+    }
+    for _, test := range(tests) {
+        _, err := slip39Split(test.secret, nil, test.groupThreshold, test.groups, 0, true, rand.Reader)
+        if !errors.Is(err, ErrInvalidParameters) {
+            t.Errorf("Expecting invalid parameters for %v, got: %v", test, err)
+        }
+    }
+}
+
+func TestSplitSlip39Args(t *testing.T) {
+    mnemonics := loadSlip39Vectors(t)[3].mnemonics
+    args := strings.Fields(strings.Join(mnemonics, " "))
+    result, err := splitSlip39Args(args)
+    if err != nil || len(result) != 2 || result[0] != mnemonics[0] || result[1] != mnemonics[1] {
+        t.Errorf("Expecting %q, got: %q (%v)", mnemonics, result, err)
+    }
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "strings"
+)
+
+// Sharded Secret Key Reconstruction (SSKR), the share format of Blockchain
+// Commons' Gordian wallets
+// (https://github.com/BlockchainCommons/Research/blob/master/papers/bcr-2020-011-sskr.md).
+// Its two levels of groups and members, and the splitting of each level with
+// a digest at x = 254 and the secret at x = 255, are those of SLIP-39, so
+// shares go through the same splitGroups and combineGroups. Unlike SLIP-39
+// there is no passphrase encryption, and a share is five bytes of metadata
+// followed by the value, written as a `ur:sskr/` Uniform Resource or as
+// Bytewords.
+
+const (
+    SSKR_METADATA_BYTES = 5
+    SSKR_MIN_SECRET_BYTES = 16
+    SSKR_MAX_SECRET_BYTES = 32
+    SSKR_MAX_SHARES = 16
+    SSKR_UR_TYPE = "sskr"
+    // The legacy UR type, still read.
+    SSKR_LEGACY_UR_TYPE = "crypto-sskr"
+    // CBOR tags of an SSKR share, 309 from before the registry moved tags
+    // above 40000. Shares are written as Bytewords with the current tag.
+    SSKR_TAG = 40309
+    SSKR_LEGACY_TAG = 309
+)
+
+type sskrShare struct {
+    identifier int
+    groupShare
+}
+
+// Checks the secret and group layout for a split.
+func validSskrParameters(secret []byte, groupThreshold int, groups []slip39Group) error {
+    if len(secret) < SSKR_MIN_SECRET_BYTES || len(secret) > SSKR_MAX_SECRET_BYTES || len(secret) % 2 != 0 {
+        return newError(ErrInvalidParameters, fmt.Sprintf("The secret must be an even number of bytes, from %d to %d.", SSKR_MIN_SECRET_BYTES, SSKR_MAX_SECRET_BYTES))
+    }
+    if len(groups) < 1 || len(groups) > SSKR_MAX_SHARES {
+        return newError(ErrInvalidParameters, fmt.Sprintf("There must be between 1 and %d groups.", SSKR_MAX_SHARES))
+    }
+    if groupThreshold < 1 || groupThreshold > len(groups) {
+        return newError(ErrInvalidParameters, "The group threshold must be between 1 and the number of groups.")
+    }
+    for i, group := range(groups) {
+        if group.count < 1 || group.count > SSKR_MAX_SHARES || group.threshold < 1 || group.threshold > group.count {
+            return newError(ErrInvalidParameters, fmt.Sprintf("Group %d: need 1 <= t <= n <= %d.", i+1, SSKR_MAX_SHARES))
+        }
+    }
+    return nil
+}
+
+// Splits a secret into SSKR shares, returned per group, under a random 16
+// bit identifier. The identifier and shares are drawn from random.
+func sskrSplit(secret []byte, groupThreshold int, groups []slip39Group, random io.Reader) ([][]sskrShare, error) {
+    if err := validSskrParameters(secret, groupThreshold, groups); err != nil {
+        return nil, err
+    }
+    id, err := readRandom(random, 2)
+    if err != nil {
+        return nil, err
+    }
+    identifier := int(id[0]) << 8 | int(id[1])
+
+    shares, err := splitGroups(secret, groupThreshold, groups, random)
+    if err != nil {
+        return nil, err
+    }
+    res := make([][]sskrShare, len(shares))
+    for i, group := range(shares) {
+        for _, share := range(group) {
+            res[i] = append(res[i], sskrShare{identifier, share})
+        }
+    }
+    return res, nil
+}
+
+// Recovers the secret from SSKR shares of one split.
+func sskrCombine(shares []sskrShare) ([]byte, error) {
+    if len(shares) == 0 {
+        return nil, newError(ErrThresholdNotMet, "No shares given.")
+    }
+    group_shares := []groupShare{}
+    for _, s := range(shares) {
+        if s.identifier != shares[0].identifier {
+            return nil, newError(ErrMalformedShare, "Shares do not belong to the same backup, their identifiers differ.")
+        }
+        group_shares = append(group_shares, s.groupShare)
+    }
+    return combineGroups(group_shares)
+}
+
+// Serializes the share: identifier, group threshold and count, group index
+// and member threshold, a reserved nibble and member index, then the value.
+func (s sskrShare) marshal() []byte {
+    return append([]byte{
+        byte(s.identifier >> 8),
+        byte(s.identifier),
+        byte((s.groupThreshold-1) << 4 | (s.groupCount-1)),
+        byte(s.groupIndex << 4 | (s.memberThreshold-1)),
+        byte(s.memberIndex),
+    }, s.value...)
+}
+
+func unmarshalSskrShare(b []byte) (sskrShare, error) {
+    if len(b) < SSKR_METADATA_BYTES + SSKR_MIN_SECRET_BYTES || len(b) > SSKR_METADATA_BYTES + SSKR_MAX_SECRET_BYTES || len(b) % 2 != 1 {
+        return sskrShare{}, newError(ErrMalformedShare, fmt.Sprintf("SSKR shares are %d bytes of metadata and an even number of bytes from %d to %d.", SSKR_METADATA_BYTES, SSKR_MIN_SECRET_BYTES, SSKR_MAX_SECRET_BYTES))
+    }
+    if b[4] >> 4 != 0 {
+        return sskrShare{}, newError(ErrMalformedShare, "The reserved bits of the SSKR share are not zero.")
+    }
+    share := sskrShare{
+        identifier: int(b[0]) << 8 | int(b[1]),
+        groupShare: groupShare{
+            groupThreshold: int(b[2] >> 4) + 1,
+            groupCount: int(b[2] & 0xf) + 1,
+            groupIndex: int(b[3] >> 4),
+            memberThreshold: int(b[3] & 0xf) + 1,
+            memberIndex: int(b[4] & 0xf),
+            value: append([]byte{}, b[SSKR_METADATA_BYTES:]...),
+        },
+    }
+    if share.groupThreshold > share.groupCount || share.groupIndex >= share.groupCount {
+        return sskrShare{}, newError(ErrMalformedShare, "The group numbers of the SSKR share are inconsistent.")
+    }
+    return share, nil
+}
+
+// Formats the share as a `ur:sskr/` UR.
+func (s sskrShare) ur() string {
+    return encodeUR(SSKR_UR_TYPE, cborBytes(s.marshal(), 0))
+}
+
+// Formats the share as standard Bytewords of the tagged share, as Gordian
+// wallets print them.
+func (s sskrShare) bytewords() string {
+    return encodeBytewords(cborBytes(s.marshal(), SSKR_TAG), BYTEWORDS_STANDARD)
+}
+
+// Parses a share given as a UR or as Bytewords in any style.
+func parseSskrShare(s string) (sskrShare, error) {
+    var cbor []byte
+    var err error
+    if strings.HasPrefix(strings.ToLower(strings.TrimSpace(s)), "ur:") {
+        var urType string
+        urType, cbor, err = decodeUR(s)
+        if err == nil && urType != SSKR_UR_TYPE && urType != SSKR_LEGACY_UR_TYPE {
+            err = newError(ErrMalformedShare, fmt.Sprintf("Expected a ur:%s share, got ur:%s.", SSKR_UR_TYPE, urType))
+        }
+    } else {
+        cbor, err = decodeBytewords(s)
+    }
+    if err != nil {
+        return sskrShare{}, err
+    }
+    tag, b, err := parseCborBytes(cbor)
+    if err != nil {
+        return sskrShare{}, err
+    }
+    if tag != 0 && tag != SSKR_TAG && tag != SSKR_LEGACY_TAG {
+        return sskrShare{}, newError(ErrMalformedShare, fmt.Sprintf("Unexpected CBOR tag %d for an SSKR share.", tag))
+    }
+    return unmarshalSskrShare(b)
+}
+
+// Splits command line arguments into shares. URs are single arguments, while
+// unquoted Bytewords shares spread over many are told apart by their
+// checksums.
+func splitSskrArgs(args []string) ([]string, error) {
+    words := []string{}
+    for _, arg := range(args) {
+        words = append(words, splitWords(arg)...)
+    }
+    res := []string{}
+    for start := 0; start < len(words); {
+        if strings.HasPrefix(words[start], "ur:") || len(words[start]) > 4 {
+            res = append(res, words[start])
+            start++
+            continue
+        }
+        end := start + 1
+        for ; end <= len(words); end++ {
+            if _, err := parseSskrShare(strings.Join(words[start:end], " ")); err == nil {
+                break
+            }
+        }
+        if end > len(words) {
+            return nil, newError(ErrMalformedShare, fmt.Sprintf("No valid SSKR share starts at word %d (%q).", start+1, words[start]))
+        }
+        res = append(res, strings.Join(words[start:end], " "))
+        start = end
+    }
+    return res, nil
+}
+
+// Reports whether every argument is a `ur:sskr/` share, so combine can pick
+// the scheme without -scheme.
+func isSskrInput(args []string) bool {
+    for _, arg := range(args) {
+        lower := strings.ToLower(arg)
+        if !strings.HasPrefix(lower, "ur:" + SSKR_UR_TYPE + "/") && !strings.HasPrefix(lower, "ur:" + SSKR_LEGACY_UR_TYPE + "/") {
+            return false
+        }
+    }
+    return len(args) > 0
+}
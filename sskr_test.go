@@ -0,0 +1,186 @@
+package main
+
+import (
+    "bytes"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "strings"
+    "testing"
+)
+
+// The 3 of 5 split from bc-shamir's test suite, made with its fake random
+// number generator. Recovering from any three shares checks both the
+// interpolation and the digest against the reference implementation.
+var sskrShamirShares = []string{
+    "00112233445566778899aabbccddeeff",
+    "d43099fe444807c46921a4f33a2a798b",
+    "d9ad4e3bec2e1a7485698823abf05d36",
+    "0d8cf5f6ec337bc764d1866b5d07ca42",
+    "1aa7fe3199bc5092ef3816b074cabdf2",
+}
+
+func TestSskrShamirVector(t *testing.T) {
+    for _, combination := range([][]byte{{1, 2, 4}, {0, 1, 2}, {4, 0, 3}}) {
+        ys := [][]byte{}
+        for _, x := range(combination) {
+            y, _ := hex.DecodeString(sskrShamirShares[x])
+            ys = append(ys, y)
+        }
+        secret, err := slip39RecoverValue(combination, ys, 3)
+        if err != nil || hex.EncodeToString(secret) != "0ff784df000c4380a5ed683f7e6e3dcf" {
+            t.Errorf("Expecting 0ff784df000c4380a5ed683f7e6e3dcf, got: %x (%v)", secret, err)
+        }
+    }
+}
+
+// The fake random number generator of the bc-shamir and bc-sskr test suites:
+// every call fills its buffer with 0x00, 0x11, 0x22 and so on, starting over
+// each time.
+type bcFakeRandom struct{}
+
+func (bcFakeRandom) Read(b []byte) (int, error) {
+    for i := range(b) {
+        b[i] = byte(17 * i)
+    }
+    return len(b), nil
+}
+
+// With the reference suites' generator, a single group 3 of 5 split draws the
+// identifier and then exactly what bc-shamir's split does, so the member
+// values must be its shares, under the metadata of BCR-2020-011.
+func TestSskrSeededSplit(t *testing.T) {
+    secret, _ := hex.DecodeString("0ff784df000c4380a5ed683f7e6e3dcf")
+    shares, err := sskrSplit(secret, 1, []slip39Group{{3, 5}}, bcFakeRandom{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    tokens := []string{}
+    for i, share := range(shares[0]) {
+        expected := fmt.Sprintf("00110002%02x%s", i, sskrShamirShares[i])
+        if hex.EncodeToString(share.marshal()) != expected {
+            t.Errorf("Expecting share %d to be %s, got: %x", i+1, expected, share.marshal())
+        }
+        tokens = append(tokens, share.ur())
+    }
+    parsed := []sskrShare{}
+    for _, token := range([]string{tokens[4], tokens[0], tokens[3]}) {
+        share, err := parseSskrShare(token)
+        if err != nil {
+            t.Fatal(err)
+        }
+        parsed = append(parsed, share)
+    }
+    result, err := sskrCombine(parsed)
+    if err != nil || !bytes.Equal(result, secret) {
+        t.Errorf("Expecting %x, got: %x (%v)", secret, result, err)
+    }
+}
+
+func TestSskrMetadata(t *testing.T) {
+    share := sskrShare{0x1234, groupShare{2, 3, 4, 5, 6, bytes.Repeat([]byte{0xab}, 16)}}
+    b := share.marshal()
+    if hex.EncodeToString(b[:SSKR_METADATA_BYTES]) != "1234232505" {
+        t.Errorf("Expecting metadata 1234232505, got: %x", b[:SSKR_METADATA_BYTES])
+    }
+    parsed, err := unmarshalSskrShare(b)
+    if err != nil || parsed.identifier != share.identifier || !bytes.Equal(parsed.marshal(), b) {
+        t.Errorf("Expecting %x, got: %x (%v)", b, parsed.marshal(), err)
+    }
+    for _, s := range([]string{share.ur(), share.bytewords(), strings.ToUpper(share.ur())}) {
+        parsed, err := parseSskrShare(s)
+        if err != nil || !bytes.Equal(parsed.marshal(), b) {
+            t.Errorf("Expecting %x from %q, got: %x (%v)", b, s, parsed.marshal(), err)
+        }
+    }
+    if !strings.HasPrefix(share.ur(), "ur:sskr/gobgeecndaah") {
+        t.Errorf("Bad UR %s", share.ur())
+    }
+    if !strings.HasPrefix(share.bytewords(), "tuna next keep gyro brag edge") {
+        t.Errorf("Bad Bytewords %s", share.bytewords())
+    }
+}
+
+func TestSskrSplitCombine(t *testing.T) {
+    secret, _ := hex.DecodeString("204188bfa6b440a1bdfd6753ff55a8241e07af5c5be943db917e3efabc184b1a")
+    shares, err := sskrSplit(secret, 2, []slip39Group{{2, 3}, {1, 2}, {3, 5}}, rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    tokens := []string{shares[2][4].ur(), shares[1][1].bytewords(), shares[2][0].ur(), shares[2][3].ur()}
+    parsed := []sskrShare{}
+    for _, token := range(tokens) {
+        share, err := parseSskrShare(token)
+        if err != nil {
+            t.Fatal(err)
+        }
+        parsed = append(parsed, share)
+    }
+    result, err := sskrCombine(parsed)
+    if err != nil || !bytes.Equal(result, secret) {
+        t.Errorf("Expecting %x, got: %x (%v)", secret, result, err)
+    }
+    if _, err := sskrCombine(parsed[:3]); !errors.Is(err, ErrThresholdNotMet) {
+        t.Errorf("Expecting threshold not met error, got: %v", err)
+    }
+    if _, err := sskrCombine(append(parsed, parsed[0])); !errors.Is(err, ErrDuplicateIndex) {
+        t.Errorf("Expecting duplicate index error, got: %v", err)
+    }
+    other := parsed[0]
+    other.identifier ^= 1
+    if _, err := sskrCombine(append(parsed[1:], other)); !errors.Is(err, ErrMalformedShare) {
+        t.Errorf("Expecting malformed share error, got: %v", err)
+    }
+}
+
+func TestSskrErrors(t *testing.T) {
+    tests := []struct {
+        secret         []byte
+        groupThreshold int
+        groups         []slip39Group
+    }{
+        {make([]byte, 14), 1, []slip39Group{{1, 1}}},
+        {make([]byte, 17), 1, []slip39Group{{1, 1}}},
+        {make([]byte, 34), 1, []slip39Group{{1, 1}}},
+        {make([]byte, 16), 2, []slip39Group{{1, 1}}},
+        {make([]byte, 16), 1, []slip39Group{{4, 3}}},
+        {make([]byte, 16), 1, []slip39Group{{2, 17}}},
+    }
+    for _, test := range(tests) {
+        if _, err := sskrSplit(test.secret, test.groupThreshold, test.groups, rand.Reader); !errors.Is(err, ErrInvalidParameters) {
+            t.Errorf("Expecting invalid parameters for %v, got: %v", test, err)
+        }
+    }
+
+    share := sskrShare{7, groupShare{0, 1, 1, 0, 1, make([]byte, 16)}}
+    reserved := share.marshal()
+    reserved[4] |= 0x10
+    inconsistent := share.marshal()
+    inconsistent[3] |= 0x30
+    for _, s := range([]string{
+        encodeUR(SSKR_UR_TYPE, cborBytes(reserved, 0)),
+        encodeUR(SSKR_UR_TYPE, cborBytes(inconsistent, 0)),
+        encodeUR(SSKR_UR_TYPE, cborBytes(share.marshal()[:20], 0)),
+        encodeUR("bytes", cborBytes(share.marshal(), 0)),
+        encodeUR(SSKR_UR_TYPE, cborBytes(share.marshal(), 1234)),
+    }) {
+        if _, err := parseSskrShare(s); !errors.Is(err, ErrMalformedShare) {
+            t.Errorf("Expecting malformed share for %q, got: %v", s, err)
+        }
+    }
+}
+
+func TestSplitSskrArgs(t *testing.T) {
+    shares, _ := sskrSplit(make([]byte, 16), 1, []slip39Group{{2, 3}}, rand.Reader)
+    expected := []string{shares[0][0].bytewords(), shares[0][2].ur(), shares[0][1].bytewords()}
+    args := append(strings.Fields(expected[0]), expected[1])
+    args = append(args, strings.Fields(expected[2])...)
+    result, err := splitSskrArgs(args)
+    if err != nil || strings.Join(result, "|") != strings.Join(expected, "|") {
+        t.Errorf("Expecting %q, got: %q (%v)", expected, result, err)
+    }
+    if !isSskrInput([]string{expected[1], "UR:SSKR/abc"}) || isSskrInput(expected[:2]) {
+        t.Errorf("Bad UR detection")
+    }
+}
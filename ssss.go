@@ -0,0 +1,353 @@
+package main
+
+import (
+    "fmt"
+    "math/big"
+    "strconv"
+    "strings"
+)
+
+// Shares in the format of B. Poettering's ssss (ssss-split and ssss-combine).
+// The secret is a single element of GF(2^degree), where the degree, ssss's
+// security level, is a multiple of 8 up to 1024, and shares are written as
+// "index-hex" lines, optionally prefixed with a token. Before splitting, ssss
+// runs the secret through a diffusion layer so that partial information about
+// it is spread over all of its bits.
+
+const (
+    SSSS_MIN_DEGREE = 8
+    SSSS_MAX_DEGREE = 1024
+    // The diffusion layer is skipped below this security level, as in ssss.
+    SSSS_MIN_DIFFUSION_DEGREE = 64
+    SSSS_MAX_TOKEN_LENGTH = 128
+)
+
+// For every degree 8, 16, .., 1024 the exponents k3, k2, k1 of the
+// irreducible pentanomial x^degree + x^k3 + x^k2 + x^k1 + 1 that defines the
+// field. These are ssss's table, taken from G. Seroussi, "Table of Low-Weight
+// Binary Irreducible Polynomials": the pentanomial with the smallest k3, then
+// k2, then k1.
+var ssssIrreducible = [...]int{
+    4, 3, 1, 5, 3, 1, 4, 3, 1, 7, 3, 2, 5, 4, 3, 5, 3, 2, 7, 4, 2, 4, 3, 1, 10,
+    9, 3, 9, 4, 2, 7, 6, 2, 10, 9, 6, 4, 3, 1, 5, 4, 3, 4, 3, 1, 7, 2, 1, 5, 3,
+    2, 7, 4, 2, 6, 3, 2, 5, 3, 2, 15, 3, 2, 11, 3, 2, 9, 8, 7, 7, 2, 1, 5, 3, 2,
+    9, 3, 1, 7, 3, 1, 9, 8, 3, 9, 4, 2, 8, 5, 3, 15, 14, 10, 10, 5, 2, 9, 6, 2,
+    9, 3, 2, 9, 5, 2, 11, 10, 1, 7, 3, 2, 11, 2, 1, 9, 7, 4, 4, 3, 1, 8, 3, 1,
+    7, 4, 1, 7, 2, 1, 13, 11, 6, 5, 3, 2, 7, 3, 2, 8, 7, 5, 12, 3, 2, 13, 10, 6,
+    5, 3, 2, 5, 3, 2, 9, 5, 2, 9, 7, 2, 13, 4, 3, 4, 3, 1, 11, 6, 4, 18, 9, 6,
+    19, 18, 13, 11, 3, 2, 15, 9, 6, 4, 3, 1, 16, 5, 2, 15, 14, 6, 8, 5, 2, 15,
+    11, 2, 11, 6, 2, 7, 5, 3, 8, 3, 1, 19, 16, 9, 11, 9, 6, 15, 7, 6, 13, 4, 3,
+    14, 13, 3, 13, 6, 3, 9, 5, 2, 19, 13, 6, 19, 10, 3, 11, 6, 5, 9, 2, 1, 14,
+    3, 2, 13, 3, 1, 7, 5, 4, 11, 9, 8, 11, 6, 5, 23, 16, 9, 19, 14, 6, 23, 10,
+    2, 8, 3, 2, 5, 4, 3, 9, 6, 4, 4, 3, 2, 13, 8, 6, 13, 11, 1, 13, 10, 3, 11,
+    6, 5, 19, 17, 4, 15, 14, 7, 13, 9, 6, 9, 7, 3, 9, 7, 1, 14, 3, 2, 11, 8, 2,
+    11, 6, 4, 13, 5, 2, 11, 5, 1, 11, 4, 1, 19, 10, 3, 21, 10, 6, 13, 3, 1, 15,
+    7, 5, 19, 18, 10, 7, 5, 3, 12, 7, 2, 7, 5, 1, 14, 9, 6, 10, 3, 2, 15, 13,
+    12, 12, 11, 9, 16, 9, 7, 12, 9, 3, 9, 5, 2, 17, 10, 6, 24, 9, 3, 17, 15, 13,
+    5, 4, 3, 19, 17, 8, 15, 6, 3, 19, 6, 1,
+}
+
+// Arithmetic in GF(2^degree), with elements held as big.Ints whose bits are
+// the coefficients of a polynomial over GF(2).
+type ssssField struct {
+    degree int
+    poly   *big.Int
+}
+
+func validSsssDegree(degree int) bool {
+    return degree >= SSSS_MIN_DEGREE && degree <= SSSS_MAX_DEGREE && degree % 8 == 0
+}
+
+func newSsssField(degree int) ssssField {
+    poly := new(big.Int).SetBit(big.NewInt(1), degree, 1)
+    for _, k := range(ssssIrreducible[3 * (degree / 8 - 1):3 * (degree / 8)]) {
+        poly.SetBit(poly, k, 1)
+    }
+    return ssssField{degree, poly}
+}
+
+func (f ssssField) mul(a, b *big.Int) *big.Int {
+    res := new(big.Int)
+    a = new(big.Int).Set(a)
+    for i := 0; i < b.BitLen(); i++ {
+        if b.Bit(i) == 1 {
+            res.Xor(res, a)
+        }
+        a.Lsh(a, 1)
+        if a.Bit(f.degree) == 1 {
+            a.Xor(a, f.poly)
+        }
+    }
+    return res
+}
+
+// The multiplicative inverse of a non-zero a, by the extended Euclidean
+// algorithm for polynomials over GF(2).
+func (f ssssField) inv(a *big.Int) *big.Int {
+    u, v := new(big.Int).Set(a), new(big.Int).Set(f.poly)
+    g1, g2 := big.NewInt(1), new(big.Int)
+    for u.Cmp(big.NewInt(1)) != 0 {
+        j := u.BitLen() - v.BitLen()
+        if j < 0 {
+            u, v = v, u
+            g1, g2 = g2, g1
+            j = -j
+        }
+        u.Xor(u, new(big.Int).Lsh(v, uint(j)))
+        g1.Xor(g1, new(big.Int).Lsh(g2, uint(j)))
+    }
+    return g1
+}
+
+// x^e for a small e.
+func (f ssssField) pow(x *big.Int, e int) *big.Int {
+    res := big.NewInt(1)
+    for i := 0; i < e; i++ {
+        res = f.mul(res, x)
+    }
+    return res
+}
+
+// Evaluates at x the polynomial x^t + coefficients[t-1] x^(t-1) + .. +
+// coefficients[0], where t = len(coefficients). ssss's polynomials have this
+// extra leading term because its Horner loop starts from x rather than 0.
+func (f ssssField) horner(coefficients []*big.Int, x *big.Int) *big.Int {
+    y := new(big.Int).Set(x)
+    for i := len(coefficients) - 1; i > 0; i-- {
+        y = f.mul(y.Xor(y, coefficients[i]), x)
+    }
+    return y.Xor(y, coefficients[0])
+}
+
+// ssss's diffusion layer: XTEA with an all zero key, applied to overlapping
+// 8 byte windows stepping two bytes at a time around the secret, 40 times
+// over. The bytes are the secret's 16 bit words, least significant word
+// first, each word big-endian.
+func ssssDiffuse(x *big.Int, degree int, encode bool) *big.Int {
+    length := degree / 8
+    data := make([]byte, length)
+    for k := 0; k < length; k++ {
+        // Byte k is the high byte of word k/2 for even k, the low for odd.
+        shift := 8 * (k ^ 1)
+        if degree % 16 == 8 && k == length - 1 {
+            // The top word only has its low byte.
+            shift = 8 * k
+        }
+        data[k] = byte(new(big.Int).Rsh(x, uint(shift)).Uint64())
+    }
+
+    if encode {
+        for i := 0; i < 40 * length; i += 2 {
+            ssssDiffuseSlice(data, i, xteaEncipher)
+        }
+    } else {
+        for i := 40 * length - 2; i >= 0; i -= 2 {
+            ssssDiffuseSlice(data, i, xteaDecipher)
+        }
+    }
+
+    res := new(big.Int)
+    for k := length - 1; k >= 0; k-- {
+        shift := 8 * (k ^ 1)
+        if degree % 16 == 8 && k == length - 1 {
+            shift = 8 * k
+        }
+        res.Or(res, new(big.Int).Lsh(big.NewInt(int64(data[k])), uint(shift)))
+    }
+    return res
+}
+
+// Runs block on the 8 bytes of data starting at i, wrapping around the end.
+func ssssDiffuseSlice(data []byte, i int, block func(v *[2]uint32)) {
+    var v [2]uint32
+    for j := 0; j < 8; j++ {
+        v[j / 4] = v[j / 4] << 8 | uint32(data[(i + j) % len(data)])
+    }
+    block(&v)
+    for j := 0; j < 8; j++ {
+        data[(i + j) % len(data)] = byte(v[j / 4] >> (24 - 8 * uint(j % 4)))
+    }
+}
+
+const XTEA_DELTA = 0x9E3779B9
+
+func xteaEncipher(v *[2]uint32) {
+    sum := uint32(0)
+    for i := 0; i < 32; i++ {
+        v[0] += ((v[1] << 4 ^ v[1] >> 5) + v[1]) ^ sum
+        sum += XTEA_DELTA
+        v[1] += ((v[0] << 4 ^ v[0] >> 5) + v[0]) ^ sum
+    }
+}
+
+func xteaDecipher(v *[2]uint32) {
+    // XTEA_DELTA * 32, truncated to 32 bits.
+    sum := uint32(0xC6EF3720)
+    for i := 0; i < 32; i++ {
+        v[1] -= ((v[0] << 4 ^ v[0] >> 5) + v[0]) ^ sum
+        sum -= XTEA_DELTA
+        v[0] -= ((v[1] << 4 ^ v[1] >> 5) + v[1]) ^ sum
+    }
+}
+
+// One ssss share. The y value is written as degree/4 hex digits, so its
+// length gives the security level.
+type ssssShare struct {
+    token  string
+    index  int
+    y      *big.Int
+    degree int
+}
+
+// Formats the share as ssss prints it, with the index zero padded to width
+// digits.
+// E.g. "1-1c41ef496eccfbeba439714085df8437236298da8dd824" or "backup-03-8e5d"
+func (s ssssShare) format(width int) string {
+    res := fmt.Sprintf("%0*d-%0*x", width, s.index, s.degree / 4, s.y)
+    if s.token != "" {
+        res = s.token + "-" + res
+    }
+    return res
+}
+
+// Parses a share line written by ssss-split or ssssShare.format.
+func parseSsssShare(s string) (ssssShare, error) {
+    fields := strings.Split(strings.TrimSpace(s), "-")
+    if len(fields) < 2 || len(fields) > 3 {
+        return ssssShare{}, newError(ErrMalformedShare, "ssss shares must be of the form '[token-]index-hex'.")
+    }
+    share := ssssShare{}
+    if len(fields) == 3 {
+        share.token = fields[0]
+        fields = fields[1:]
+    }
+    index, err := strconv.Atoi(fields[0])
+    if err != nil || index < 1 {
+        return ssssShare{}, newError(ErrMalformedShare, fmt.Sprintf("Bad share number %q in ssss share.", fields[0]))
+    }
+    share.index = index
+    share.degree = 4 * len(fields[1])
+    if !validSsssDegree(share.degree) {
+        return ssssShare{}, newError(ErrMalformedShare, "ssss share has an invalid length.")
+    }
+    y, ok := new(big.Int).SetString(fields[1], 16)
+    if !ok || strings.HasPrefix(fields[1], "+") || strings.HasPrefix(fields[1], "-") {
+        return ssssShare{}, newError(ErrMalformedShare, "ssss share is not valid hex.")
+    }
+    share.y = y
+    return share, nil
+}
+
+// Checks the parameters for an ssss split. A degree of 0 picks the smallest
+// security level that fits the secret, as ssss does.
+func validSsssParameters(secret []byte, n, t, degree int, token string) error {
+    switch {
+        case len(secret) == 0:
+            return newError(ErrInvalidParameters, "Empty secret.")
+        case t < 2:
+            return newError(ErrInvalidParameters, "The threshold must be at least 2.")
+        case n < t:
+            return newError(ErrInvalidParameters, "The number of shares cannot be less than the threshold.")
+        case degree != 0 && !validSsssDegree(degree):
+            return newError(ErrInvalidParameters, fmt.Sprintf("The security level must be a multiple of 8 between %d and %d bits.", SSSS_MIN_DEGREE, SSSS_MAX_DEGREE))
+        case 8 * len(secret) > SSSS_MAX_DEGREE || (degree != 0 && 8 * len(secret) > degree):
+            return newError(ErrInvalidParameters, "The secret is too long for the security level.")
+        case len(token) > SSSS_MAX_TOKEN_LENGTH || strings.Contains(token, "-"):
+            return newError(ErrInvalidParameters, fmt.Sprintf("The token must be at most %d characters and contain no '-'.", SSSS_MAX_TOKEN_LENGTH))
+    }
+    if degree == 0 {
+        degree = 8 * len(secret)
+    }
+    if big.NewInt(int64(n)).BitLen() > degree {
+        return newError(ErrInvalidParameters, "Too many shares for the security level.")
+    }
+    return nil
+}
+
+// Splits secret into n ssss shares of which t recover it, at the given
+// security level in bits (0 for the length of the secret).
+func ssssSplit(secret []byte, n, t, degree int, diffusion bool, token string) ([]ssssShare, error) {
+    if err := validSsssParameters(secret, n, t, degree, token); err != nil {
+        return nil, err
+    }
+    if degree == 0 {
+        degree = 8 * len(secret)
+    }
+    coefficients := make([]*big.Int, t - 1)
+    for i := range(coefficients) {
+        b, err := randomBytes(degree / 8)
+        if err != nil {
+            return nil, err
+        }
+        coefficients[i] = new(big.Int).SetBytes(b)
+    }
+    return _ssssSplitWithCoefficients(secret, n, degree, diffusion, token, coefficients), nil
+}
+
+// Splits secret using the given x^1.. coefficients, which must be field
+// elements.
+func _ssssSplitWithCoefficients(secret []byte, n, degree int, diffusion bool, token string, coefficients []*big.Int) []ssssShare {
+    f := newSsssField(degree)
+    c0 := new(big.Int).SetBytes(secret)
+    if diffusion && degree >= SSSS_MIN_DIFFUSION_DEGREE {
+        c0 = ssssDiffuse(c0, degree, true)
+    }
+    poly := append([]*big.Int{c0}, coefficients...)
+    shares := make([]ssssShare, n)
+    for i := range(shares) {
+        x := big.NewInt(int64(i + 1))
+        shares[i] = ssssShare{token, i + 1, f.horner(poly, x), degree}
+    }
+    return shares
+}
+
+// Recovers the secret from the first t shares, as ssss-combine does, and
+// returns it as degree/8 bytes. t must be the threshold the shares were split
+// with.
+func ssssCombine(shares []ssssShare, t int, diffusion bool) ([]byte, error) {
+    if t < 2 {
+        return nil, newError(ErrInvalidParameters, "The threshold must be at least 2.")
+    }
+    if len(shares) < t {
+        return nil, newError(ErrThresholdNotMet, fmt.Sprintf("Need %d shares to recover the secret, got %d.", t, len(shares)))
+    }
+    shares = shares[:t]
+    degree := shares[0].degree
+    f := newSsssField(degree)
+    seen := make(map[int]bool)
+    xs := make([]*big.Int, t)
+    ys := make([]*big.Int, t)
+    for i, share := range(shares) {
+        if share.degree != degree {
+            return nil, newError(ErrMalformedShare, "Shares have different security levels.")
+        }
+        if seen[share.index] {
+            return nil, newError(ErrDuplicateIndex, fmt.Sprintf("Share %d was given more than once.", share.index))
+        }
+        seen[share.index] = true
+        xs[i] = big.NewInt(int64(share.index))
+        if xs[i].BitLen() > degree {
+            return nil, newError(ErrMalformedShare, fmt.Sprintf("Share number %d is too large for the security level.", share.index))
+        }
+        // Take off the leading x^t term so the rest is a polynomial of
+        // degree t-1 that t points determine.
+        ys[i] = new(big.Int).Xor(share.y, f.pow(xs[i], t))
+    }
+
+    // Lagrange interpolation at 0. Subtraction is XOR in GF(2^degree).
+    secret := new(big.Int)
+    for i := range(xs) {
+        num, den := big.NewInt(1), big.NewInt(1)
+        for j := range(xs) {
+            if i != j {
+                num = f.mul(num, xs[j])
+                den = f.mul(den, new(big.Int).Xor(xs[i], xs[j]))
+            }
+        }
+        secret.Xor(secret, f.mul(ys[i], f.mul(num, f.inv(den))))
+    }
+    if diffusion && degree >= SSSS_MIN_DIFFUSION_DEGREE {
+        secret = ssssDiffuse(secret, degree, false)
+    }
+    return secret.FillBytes(make([]byte, degree / 8)), nil
+}
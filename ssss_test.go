@@ -0,0 +1,159 @@
+package main
+
+import (
+    "bytes"
+    "errors"
+    "math/big"
+    "testing"
+)
+
+func TestSsssIrreducible(t *testing.T) {
+    if len(ssssIrreducible) != 3 * SSSS_MAX_DEGREE / 8 {
+        t.Errorf("Expected %d exponents, got %d", 3 * SSSS_MAX_DEGREE / 8, len(ssssIrreducible))
+    }
+    // Degree 8 is the AES field.
+    if newSsssField(8).poly.Int64() != GF256_POLYNOMIAL {
+        t.Errorf("Expecting %x, got: %x", GF256_POLYNOMIAL, newSsssField(8).poly)
+    }
+    f := newSsssField(184)
+    for _, a := range([]int64{1, 2, 3, 0x1234567, 255}) {
+        x := big.NewInt(a)
+        if f.mul(x, f.inv(x)).Cmp(big.NewInt(1)) != 0 {
+            t.Errorf("%d has no inverse", a)
+        }
+    }
+}
+
+func TestSsssDiffusion(t *testing.T) {
+    for _, degree := range([]int{64, 72, 184, 256}) {
+        x := new(big.Int).SetBytes([]byte("diffuse me, please, ............")[:degree/8])
+        encoded := ssssDiffuse(x, degree, true)
+        if encoded.Cmp(x) == 0 || encoded.BitLen() > degree {
+            t.Errorf("Bad diffusion for degree %d: %x", degree, encoded)
+        }
+        if decoded := ssssDiffuse(encoded, degree, false); decoded.Cmp(x) != 0 {
+            t.Errorf("Expecting %x, got: %x", x, decoded)
+        }
+    }
+}
+
+// The example from the ssss-split man page, with the default diffusion.
+var ssssManPageShares = []string{
+    "1-1c41ef496eccfbeba439714085df8437236298da8dd824",
+    "2-fbc74a03a50e14ab406c225afb5f45c40ae11976d2b665",
+    "3-fa1c3a9c6df8af0779c36de6c33f6e36e989d0e0b91309",
+    "4-468de7d6eb36674c9cf008c8e8fc8c566537ad6301eb9e",
+    "5-4756974923c0dce0a55f4774d09ca7a4865f64f56a4ee0",
+}
+
+func TestSsssManPage(t *testing.T) {
+    for _, combination := range([][]int{{0, 1, 2}, {4, 2, 3}, {0, 2, 4}}) {
+        shares := []ssssShare{}
+        for _, i := range(combination) {
+            share, err := parseSsssShare(ssssManPageShares[i])
+            if err != nil {
+                t.Fatal(err)
+            }
+            shares = append(shares, share)
+        }
+        secret, err := ssssCombine(shares, 3, true)
+        if err != nil || string(secret) != "my secret root password" {
+            t.Errorf("Expecting 'my secret root password', got: %q (%v)", secret, err)
+        }
+    }
+}
+
+// Shares for fixed coefficients, computed with an independent port of
+// ssss.c.
+func TestSsssSplitVectors(t *testing.T) {
+    tests := []struct {
+        secret       string
+        degree       int
+        diffusion    bool
+        coefficients []string
+        shares       []string
+    }{
+        {"old escrow key", 256, true, []string{"1234567890abcdef00000000000000000000000000000000000000000000feed", "deadbeef0000000000000000000000007"}, []string{
+            "1-3751bc71321d378d96d95b700fd9f9c966091460e7588359f2756d76e2bd886a",
+            "2-010d46f883e161bc96d95b700fd9f9f327bd4150e7588359f2756d76e2bc8b4f",
+            "3-13391080134aac5396d95b700fd9f9fecd66afa0e7588359f2756d76e2bc75a2",
+            "4-6db4b3ebe019cdde96d95b700fd9f91a216c1590e7588359f2756d76e2be8d05",
+            "5-7f80e59370b2003196d95b700fd9f917cbb7fb60e7588359f2756d76e2be73fa",
+        }},
+        {"pin", 32, true, []string{"a5a5a5"}, []string{"1-00d5ccca", "2-013b2220", "3-019e8784"}},
+        {"no diffusion", 96, false, []string{"ab54a98ceb1f0ad2"}, []string{"1-6e6f2064c232cff9987665bd", "2-6e6f20653fcf356ca5577ace", "3-6e6f2065949b9ce04e48701d"}},
+    }
+    for _, test := range(tests) {
+        coefficients := []*big.Int{}
+        for _, c := range(test.coefficients) {
+            v, _ := new(big.Int).SetString(c, 16)
+            coefficients = append(coefficients, v)
+        }
+        shares := _ssssSplitWithCoefficients([]byte(test.secret), len(test.shares), test.degree, test.diffusion, "", coefficients)
+        for i, share := range(shares) {
+            if share.format(1) != test.shares[i] {
+                t.Errorf("Expecting %s, got: %s", test.shares[i], share.format(1))
+            }
+        }
+        secret, err := ssssCombine(shares[1:], len(coefficients) + 1, test.diffusion)
+        if err != nil || string(bytes.TrimLeft(secret, "\x00")) != test.secret {
+            t.Errorf("Expecting %q, got: %q (%v)", test.secret, secret, err)
+        }
+    }
+}
+
+func TestSsssSplitCombine(t *testing.T) {
+    secret := []byte("0123456789abcdef")
+    shares, err := ssssSplit(secret, 12, 4, 0, true, "escrow")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if line := shares[2].format(2); line[:10] != "escrow-03-" || len(line) != 10 + 32 {
+        t.Errorf("Bad share line %q", line)
+    }
+    parsed := []ssssShare{}
+    for _, i := range([]int{11, 3, 7, 0}) {
+        share, err := parseSsssShare(shares[i].format(2))
+        if err != nil {
+            t.Fatal(err)
+        }
+        parsed = append(parsed, share)
+    }
+    result, err := ssssCombine(parsed, 4, true)
+    if err != nil || !bytes.Equal(result, secret) {
+        t.Errorf("Expecting %x, got: %x (%v)", secret, result, err)
+    }
+    if _, err := ssssCombine(parsed[:3], 4, true); !errors.Is(err, ErrThresholdNotMet) {
+        t.Errorf("Expecting threshold not met error, got: %v", err)
+    }
+    if _, err := ssssCombine(append(parsed[:1], parsed[:3]...), 4, true); !errors.Is(err, ErrDuplicateIndex) {
+        t.Errorf("Expecting duplicate index error, got: %v", err)
+    }
+}
+
+func TestSsssErrors(t *testing.T) {
+    tests := []struct {
+        secret   string
+        n, t     int
+        degree   int
+        token    string
+    }{
+        {"", 3, 2, 0, ""},
+        {"secret", 3, 1, 0, ""},
+        {"secret", 2, 3, 0, ""},
+        {"secret", 3, 2, 12, ""},
+        {"secret", 3, 2, 40, ""},
+        {"secret", 3, 2, 0, "a-b"},
+    }
+    for _, test := range(tests) {
+        _, err := ssssSplit([]byte(test.secret), test.n, test.t, test.degree, true, test.token)
+        if !errors.Is(err, ErrInvalidParameters) {
+            t.Errorf("Expecting invalid parameters for %v, got: %v", test, err)
+        }
+    }
+    for _, s := range([]string{"1", "x-abcd", "0-abcd", "1-abc", "1-zz", "a-b-1-ab"}) {
+        if _, err := parseSsssShare(s); !errors.Is(err, ErrMalformedShare) {
+            t.Errorf("Expecting malformed share for %q, got: %v", s, err)
+        }
+    }
+}
@@ -0,0 +1,55 @@
+// Makes the Vault shares of vault_test.go with Vault's own shamir package,
+// and checks that Vault combines the shares this tool makes. Run it from this
+// directory with go run generate.go; it needs network access to fetch Vault.
+package main
+
+import (
+    "encoding/hex"
+    "fmt"
+    "os"
+
+    "github.com/hashicorp/vault/shamir"
+)
+
+func main() {
+    for _, c := range []struct {
+        secret    string
+        parts     int
+        threshold int
+    }{
+        {hex.EncodeToString([]byte("vault unseal key")), 5, 3},
+        {"4813494d137e1631bba301d5acab6e7bb7aa74ce1185d456565ef51d737677b2", 3, 2},
+    } {
+        secret, _ := hex.DecodeString(c.secret)
+        shares, err := shamir.Split(secret, c.parts, c.threshold)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+        fmt.Printf("%s %d of %d:\n", c.secret, c.threshold, c.parts)
+        for _, share := range shares {
+            fmt.Printf("    %x\n", share)
+        }
+    }
+
+    // The shares vaultVectors gives for fixed polynomials, made by this tool.
+    for _, c := range []struct {
+        secret string
+        shares []string
+    }{
+        {hex.EncodeToString([]byte("vault unseal key")), []string{"3e0b8758e5efb26f732209c22b68f39241", "a1d08bf2e0f9106b412cb6db9d2fd01a8f", "b9e99bde2525f0b7d3685706a996e132c3"}},
+        {"4813494d137e1631bba301d5acab6e7bb7aa74ce1185d456565ef51d737677b2", []string{"48104f481f711c38a3b81fc8b8bc7c6a879942fb2dbaee6f7e75db305751559303", "48124b4e177b1036b3aa0bdea0a66074a7bb66dd0590c2414e47ef066f6b69ad01"}},
+    } {
+        parts := [][]byte{}
+        for _, s := range c.shares {
+            share, _ := hex.DecodeString(s)
+            parts = append(parts, share)
+        }
+        secret, err := shamir.Combine(parts)
+        if err != nil || hex.EncodeToString(secret) != c.secret {
+            fmt.Fprintf(os.Stderr, "Vault combines %v to %x (%v), expecting %s\n", c.shares, secret, err, c.secret)
+            os.Exit(1)
+        }
+        fmt.Printf("Vault combines this tool's shares of %s\n", c.secret)
+    }
+}
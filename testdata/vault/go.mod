@@ -0,0 +1,5 @@
+module vaultgen
+
+go 1.21
+
+require github.com/hashicorp/vault v1.15.6
@@ -0,0 +1,132 @@
+package main
+
+import (
+    "crypto/rand"
+    "encoding/base64"
+    "encoding/hex"
+    "fmt"
+    "math/big"
+)
+
+// Shares in the layout of HashiCorp Vault's shamir package, which Vault uses
+// for its unseal and recovery keys. Every byte of the secret is split on its
+// own over GF(256) with the AES polynomial, and a share is the y bytes
+// followed by a single byte x coordinate, so shares made by either tool can
+// be combined by the other.
+
+// Vault's x coordinates are single non-zero bytes.
+const VAULT_MAX_SHARES = 255
+
+// Checks the parameters the same way Vault's shamir.Split does.
+func validVaultParameters(secret []byte, n, t int) error {
+    switch {
+        case len(secret) == 0:
+            return newError(ErrInvalidParameters, "Empty secret.")
+        case t < 2:
+            return newError(ErrInvalidParameters, "The threshold must be at least 2.")
+        case n < t:
+            return newError(ErrInvalidParameters, "The number of shares cannot be less than the threshold.")
+        case n > VAULT_MAX_SHARES:
+            return newError(ErrInvalidParameters, fmt.Sprintf("The number of shares cannot exceed %d.", VAULT_MAX_SHARES))
+    }
+    return nil
+}
+
+// Picks n distinct x coordinates at random from 1..255, as Vault does.
+func randomXCoordinates(n int) ([]byte, error) {
+    xs := make([]byte, VAULT_MAX_SHARES)
+    for i := range(xs) {
+        xs[i] = byte(i + 1)
+    }
+    // Fisher-Yates shuffle, stopping once the first n are chosen.
+    for i := 0; i < n; i++ {
+        j, err := rand.Int(rand.Reader, big.NewInt(int64(len(xs) - i)))
+        if err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrRandomness, err)
+        }
+        k := i + int(j.Int64())
+        xs[i], xs[k] = xs[k], xs[i]
+    }
+    return xs[:n], nil
+}
+
+// Splits secret into n Vault shares of which t recover it.
+func vaultSplit(secret []byte, n, t int) ([][]byte, error) {
+    if err := validVaultParameters(secret, n, t); err != nil {
+        return nil, err
+    }
+    xs, err := randomXCoordinates(n)
+    if err != nil {
+        return nil, err
+    }
+    // The higher coefficients of the polynomial for each byte of the secret.
+    coefficients := make([][]byte, len(secret))
+    for i := range(coefficients) {
+        if coefficients[i], err = randomBytes(t - 1); err != nil {
+            return nil, err
+        }
+    }
+    return _vaultSplitWithPolynomials(secret, xs, coefficients), nil
+}
+
+// Splits secret at the given x coordinates, using coefficients[i] as the
+// x^1.. coefficients of the polynomial for byte i.
+func _vaultSplitWithPolynomials(secret, xs []byte, coefficients [][]byte) [][]byte {
+    shares := make([][]byte, len(xs))
+    for i, x := range(xs) {
+        shares[i] = make([]byte, len(secret) + 1)
+        shares[i][len(secret)] = x
+    }
+    for k, b := range(secret) {
+        p := append([]byte{b}, coefficients[k]...)
+        for i, x := range(xs) {
+            shares[i][k] = gf256Evaluate(p, x)
+        }
+    }
+    return shares
+}
+
+// Recovers the secret from Vault shares. Like Vault, this cannot tell when
+// fewer shares than the threshold are given and then returns garbage.
+func vaultCombine(shares [][]byte) ([]byte, error) {
+    if len(shares) < 2 {
+        return nil, newError(ErrThresholdNotMet, "At least two shares are needed to recover the secret.")
+    }
+    length := len(shares[0])
+    if length < 2 {
+        return nil, newError(ErrMalformedShare, "Vault shares must be at least two bytes long.")
+    }
+    xs := make([]byte, len(shares))
+    seen := make(map[byte]bool)
+    for i, share := range(shares) {
+        if len(share) != length {
+            return nil, newError(ErrMalformedShare, "All shares must be the same length.")
+        }
+        xs[i] = share[length-1]
+        if xs[i] == 0 {
+            return nil, newError(ErrMalformedShare, "Share has an x coordinate of 0.")
+        }
+        if seen[xs[i]] {
+            return nil, newError(ErrDuplicateIndex, fmt.Sprintf("Two shares have the x coordinate %d.", xs[i]))
+        }
+        seen[xs[i]] = true
+    }
+
+    ys := make([][]byte, len(shares))
+    for i, share := range(shares) {
+        ys[i] = share[:length-1]
+    }
+    return gf256Interpolate(xs, ys, 0), nil
+}
+
+// Decodes a Vault share given as hex or base64, trying hex first as
+// `vault operator unseal` does.
+func decodeVaultShare(s string) ([]byte, error) {
+    if b, err := hex.DecodeString(s); err == nil {
+        return b, nil
+    }
+    if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+        return b, nil
+    }
+    return nil, newError(ErrMalformedShare, "Vault shares must be hex or base64 encoded.")
+}
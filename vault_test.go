@@ -0,0 +1,236 @@
+package main
+
+import (
+    "bytes"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "testing"
+)
+
+// Shares made by Vault itself: the output of shamir.Split from
+// github.com/hashicorp/vault v1.15.6, printed by testdata/vault/generate.go.
+// Split draws its x coordinates and polynomials from crypto/rand, so these
+// are one run's output.
+var vaultShares = []struct {
+    secret    string
+    threshold int
+    shares    []string
+}{
+    {
+        hex.EncodeToString([]byte("vault unseal key")),
+        3,
+        []string{
+            "ddca182d995aa4ce26e253d6b8ab574468",
+            "2bcbc21d9ef98082f7766de32510fb2804",
+            "200e24441cab11e4c0208af9a4a0f65eac",
+            "6bdf74d7b8232e9de6e83a1ad02a8ec2ed",
+            "33e42b5bd2fcf09b7b0f76b5e5c8f60f94",
+        },
+    },
+    {
+        "4813494d137e1631bba301d5acab6e7bb7aa74ce1185d456565ef51d737677b2",
+        2,
+        []string{
+            "6bc2cbcdc6a9ef56f4fe881d8acaf4e2da3b363cf32fc6c8b9a53219bb1ce47cc8",
+            "ed71e688a5a23daf6c63424ce88b0f45f027f109ab63c3e3be1f54c9eaba909599",
+            "90d3b65e109109aefb2ef9dfc11b2da259ee1cd628a519d647ad3bde79c1df970a",
+        },
+    },
+}
+
+// Every set of at least threshold of Vault's shares, starting from any of
+// them, combines to the secret.
+func TestVaultShares(t *testing.T) {
+    for _, v := range(vaultShares) {
+        secret, _ := hex.DecodeString(v.secret)
+        parsed := [][]byte{}
+        for _, s := range(v.shares) {
+            share, err := decodeVaultShare(s)
+            if err != nil {
+                t.Fatal(err)
+            }
+            parsed = append(parsed, share)
+        }
+        for subset := 0; subset < 1 << len(parsed); subset++ {
+            parts := [][]byte{}
+            for i := range(parsed) {
+                if subset & (1 << i) != 0 {
+                    parts = append(parts, parsed[i])
+                }
+            }
+            if len(parts) < v.threshold {
+                continue
+            }
+            for r := range(parts) {
+                rotated := append(append([][]byte{}, parts[r:]...), parts[:r]...)
+                result, err := vaultCombine(rotated)
+                if err != nil || !bytes.Equal(result, secret) {
+                    t.Errorf("Expecting %x from shares %b, got: %x (%v)", secret, subset, result, err)
+                }
+            }
+        }
+        // Fewer than the threshold interpolate to something else.
+        if result, _ := vaultCombine(parsed[:v.threshold-1]); bytes.Equal(result, secret) {
+            t.Errorf("Expecting %d shares not to give the secret", v.threshold-1)
+        }
+    }
+}
+
+// Shares in Vault's layout for fixed polynomials, made by this tool.
+// Vault's Split takes its x coordinates and polynomials from crypto/rand with
+// no way to fix them, so these are checked the other way round:
+// testdata/vault/generate.go has Vault v1.15.6's shamir.Combine recover the
+// secret from a threshold of the shares of each.
+var vaultVectors = []struct {
+    secret       string
+    xs           []byte
+    coefficients func(i int) []byte
+    shares       []string
+}{
+    {
+        hex.EncodeToString([]byte("vault unseal key")),
+        []byte{0x8f, 0x02, 0xff, 0x41, 0xc3},
+        func(i int) []byte { return []byte{byte(17*i + 3), byte(29*i + 101)} },
+        []string{
+            "a1d08bf2e0f9106b412cb6db9d2fd01a8f",
+            "ff7775c6b365eb5a51f50aad8ce55d1d02",
+            "c9bc00094fb7404bde457b667455c42fff",
+            "3e0b8758e5efb26f732209c22b68f39241",
+            "b9e99bde2525f0b7d3685706a996e132c3",
+        },
+    },
+    {
+        "4813494d137e1631bba301d5acab6e7bb7aa74ce1185d456565ef51d737677b2",
+        []byte{1, 2, 3},
+        func(i int) []byte { return []byte{byte(i)} },
+        []string{
+            "48124b4e177b1036b3aa0bdea0a66074a7bb66dd0590c2414e47ef066f6b69ad01",
+            "48114d4b1b741a3fabb115c3b4b17265978850e839aff878666cc12b4b4c4b8c02",
+            "48104f481f711c38a3b81fc8b8bc7c6a879942fb2dbaee6f7e75db305751559303",
+        },
+    },
+}
+
+func TestVaultVectors(t *testing.T) {
+    for _, v := range(vaultVectors) {
+        secret, _ := hex.DecodeString(v.secret)
+        coefficients := make([][]byte, len(secret))
+        for i := range(coefficients) {
+            coefficients[i] = v.coefficients(i)
+        }
+        shares := _vaultSplitWithPolynomials(secret, v.xs, coefficients)
+        for i, share := range(shares) {
+            if hex.EncodeToString(share) != v.shares[i] {
+                t.Errorf("Expecting %s, got: %x", v.shares[i], share)
+            }
+        }
+
+        parsed := [][]byte{}
+        for _, s := range(v.shares) {
+            share, _ := hex.DecodeString(s)
+            parsed = append(parsed, share)
+        }
+        threshold := len(coefficients[0]) + 1
+        result, err := vaultCombine(parsed[len(parsed)-threshold:])
+        if err != nil || !bytes.Equal(result, secret) {
+            t.Errorf("Expecting %x, got: %x (%v)", secret, result, err)
+        }
+    }
+}
+
+// The cases of TestField_Add, TestField_Mult, TestField_Divide,
+// TestSplit_invalid and TestCombine_invalid in Vault's shamir/shamir_test.go.
+func TestVaultReferenceSuite(t *testing.T) {
+    for _, c := range([][3]byte{{16, 16, 0}, {3, 4, 7}}) {
+        if c[0] ^ c[1] != c[2] {
+            t.Errorf("Expecting %d + %d = %d", c[0], c[1], c[2])
+        }
+    }
+    for _, c := range([][3]byte{{3, 7, 9}, {3, 0, 0}, {0, 3, 0}}) {
+        if gf256Mul(c[0], c[1]) != c[2] {
+            t.Errorf("Expecting %d * %d = %d, got: %d", c[0], c[1], c[2], gf256Mul(c[0], c[1]))
+        }
+    }
+    for _, c := range([][3]byte{{0, 7, 0}, {3, 3, 1}, {6, 3, 2}}) {
+        if gf256Div(c[0], c[1]) != c[2] {
+            t.Errorf("Expecting %d / %d = %d, got: %d", c[0], c[1], c[2], gf256Div(c[0], c[1]))
+        }
+    }
+
+    secret := []byte("test")
+    for _, p := range([][2]int{{0, 0}, {2, 3}, {1000, 3}, {10, 1}}) {
+        if _, err := vaultSplit(secret, p[0], p[1]); err == nil {
+            t.Errorf("Expecting an error for n=%d t=%d", p[0], p[1])
+        }
+    }
+    if _, err := vaultSplit(nil, 3, 2); err == nil {
+        t.Errorf("Expecting an error for an empty secret")
+    }
+
+    for _, parts := range([][][]byte{
+        nil,
+        {[]byte("foo"), []byte("ba")},
+        {[]byte("f"), []byte("b")},
+        {[]byte("foo"), []byte("foo")},
+    }) {
+        if _, err := vaultCombine(parts); err == nil {
+            t.Errorf("Expecting an error combining %q", parts)
+        }
+    }
+}
+
+func TestVaultSplitCombine(t *testing.T) {
+    secret := []byte("0123456789abcdef0123456789abcdef")
+    shares, err := vaultSplit(secret, 5, 3)
+    if err != nil {
+        t.Fatal(err)
+    }
+    seen := make(map[byte]bool)
+    for _, share := range(shares) {
+        if len(share) != len(secret) + 1 || share[len(secret)] == 0 || seen[share[len(secret)]] {
+            t.Errorf("Bad share %x", share)
+        }
+        seen[share[len(secret)]] = true
+    }
+    result, err := vaultCombine([][]byte{shares[4], shares[1], shares[3]})
+    if err != nil || !bytes.Equal(result, secret) {
+        t.Errorf("Expecting %x, got: %x (%v)", secret, result, err)
+    }
+}
+
+func TestVaultErrors(t *testing.T) {
+    secret := []byte("secret")
+    for _, p := range([][2]int{{3, 1}, {2, 3}, {256, 2}}) {
+        if _, err := vaultSplit(secret, p[0], p[1]); !errors.Is(err, ErrInvalidParameters) {
+            t.Errorf("Expecting invalid parameters for n=%d t=%d, got: %v", p[0], p[1], err)
+        }
+    }
+    if _, err := vaultSplit(nil, 3, 2); !errors.Is(err, ErrInvalidParameters) {
+        t.Errorf("Expecting invalid parameters for an empty secret, got: %v", err)
+    }
+
+    shares, _ := vaultSplit(secret, 3, 2)
+    if _, err := vaultCombine(shares[:1]); !errors.Is(err, ErrThresholdNotMet) {
+        t.Errorf("Expecting threshold not met error, got: %v", err)
+    }
+    if _, err := vaultCombine([][]byte{shares[0], shares[0]}); !errors.Is(err, ErrDuplicateIndex) {
+        t.Errorf("Expecting duplicate index error, got: %v", err)
+    }
+    if _, err := vaultCombine([][]byte{shares[0], shares[1][1:]}); !errors.Is(err, ErrMalformedShare) {
+        t.Errorf("Expecting malformed share error, got: %v", err)
+    }
+}
+
+func TestDecodeVaultShare(t *testing.T) {
+    share := []byte{0xde, 0xad, 0xbe, 0xef, 0x07}
+    for _, s := range([]string{hex.EncodeToString(share), base64.StdEncoding.EncodeToString(share)}) {
+        result, err := decodeVaultShare(s)
+        if err != nil || !bytes.Equal(result, share) {
+            t.Errorf("Expecting %x, got: %x (%v)", share, result, err)
+        }
+    }
+    if _, err := decodeVaultShare("not a share!"); !errors.Is(err, ErrMalformedShare) {
+        t.Errorf("Expecting malformed share error, got: %v", err)
+    }
+}
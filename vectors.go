@@ -0,0 +1,276 @@
+package main
+
+import (
+    "context"
+    "crypto/ed25519"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// Known-answer test vectors for native splits. Each vector gives the
+// parameters of a split made with split -seed, and the shares it comes out
+// with, bare and as a record in every share encoding. Vectors are written by
+// the testvectors command into testdata/vectors/v<version>, one JSON file
+// each, and are never changed once committed: the tests check that today's
+// split still gives exactly the shares of the current version, and that
+// today's combine still recovers the secret from the shares of every
+// version.
+
+// The version of the vectors the testvectors command writes. It is bumped
+// whenever a change to split would give different shares for the same seed,
+// and the new vectors go in a new directory alongside the old ones.
+const TEST_VECTOR_VERSION = 1
+
+type testVector struct {
+    Version     int    `json:"version"`
+    Name        string `json:"name"`
+    Description string `json:"description"`
+    Secret      string `json:"secret"`
+    // The split as made by split -seed=<seed> -field=<field> ..., with
+    // -dealer-key set to testVectorDealerKey(seed) if signed.
+    Seed         string `json:"seed"`
+    Field        string `json:"field"`
+    N            int    `json:"n"`
+    T            int    `json:"t"`
+    XCoordinates string `json:"x_coordinates,omitempty"`
+    Pad          string `json:"pad,omitempty"`
+    Signed       bool   `json:"signed"`
+    // What the split came out with. The dealer public key is hex, and only
+    // given for signed vectors.
+    DealerPublicKey string            `json:"dealer_public_key,omitempty"`
+    SplitID         string            `json:"split_id"`
+    Shares          []testVectorShare `json:"shares"`
+}
+
+// A share as its x-coordinate and bare payload, and as a record in each
+// encoding: "text" for a shamir1 record, and the names of shareEncodings.
+type testVectorShare struct {
+    X       *big.Int          `json:"x"`
+    Payload string            `json:"payload"`
+    Records map[string]string `json:"records"`
+}
+
+// The vectors the testvectors command writes, between them covering every
+// field and every form of share record: text records with and without a
+// field, and binary records of versions 1, 2 and 3.
+var standardTestVectors = []testVector{
+    {Name: "p127-basic", Description: "A short secret in the default field.",
+        Secret: "Hello, World! This is my secret.", Field: "p127", N: 5, T: 3},
+    {Name: "p127-signed-padded", Description: "A secret of several subsecrets, padded and signed by the dealer.",
+        Secret: "correct horse battery staple, and then some more words to go over a few subsecrets", Field: "p127", N: 3, T: 2, Pad: "bucket", Signed: true},
+    {Name: "p127-chosen-x", Description: "Shares at chosen x-coordinates, whose records leave out n.",
+        Secret: "Hello, World! This is my secret.", Field: "p127", N: 3, T: 3, XCoordinates: "17,4242,99"},
+    {Name: "p127-random-x", Description: "Shares at random x-coordinates, above 2^31, in version 2 binary records.",
+        Secret: "Hello, World! This is my secret.", Field: "p127", N: 4, T: 2, XCoordinates: "random"},
+    {Name: "p127-whole-chunks", Description: "A secret of exactly three 15 byte subsecrets.",
+        Secret: "This secret is exactly forty-five bytes long.", Field: "p127", N: 2, T: 2},
+    {Name: "p521-signed", Description: "A 64 byte secret in a single subsecret of the 2^521 - 1 field, signed by the dealer.",
+        Secret: strings.Repeat("0123456789abcdef", 4), Field: "p521", N: 3, T: 2, Signed: true},
+    {Name: "p256-scalar", Description: "The field of NIST P-256 scalars.",
+        Secret: "Hello, World! This is my secret.", Field: "p256-scalar", N: 3, T: 2},
+    {Name: "ed25519-scalar", Description: "The field of Ed25519 scalars.",
+        Secret: "Hello, World! This is my secret.", Field: "ed25519-scalar", N: 3, T: 2},
+    {Name: "gf256", Description: "GF(2^8), a byte at a time.",
+        Secret: "Shamir", Field: "gf256", N: 5, T: 3},
+    {Name: "gf128-random-x", Description: "GF(2^128) at random x-coordinates.",
+        Secret: "Hello, World! This is my secret.", Field: "gf128", N: 3, T: 2, XCoordinates: "random"},
+}
+
+// The dealer key signed test vectors are signed with. Like everything else
+// about them it comes from the seed, so it is no secret.
+func testVectorDealerKey(seed string) ed25519.PrivateKey {
+    key_seed, _ := readRandom(newSeededReader(seed + "\ndealer"), ed25519.SeedSize)
+    return ed25519.NewKeyFromSeed(key_seed)
+}
+
+// Makes the split a vector describes, seeded by its name unless it gives a
+// seed, and fills in the shares.
+func generateTestVector(v testVector) (testVector, error) {
+    v.Version = TEST_VECTOR_VERSION
+    if v.Seed == "" {
+        v.Seed = v.Name
+    }
+    random := newSeededReader(v.Seed)
+    var err error
+    if v.SplitID, err = newSplitID(random); err != nil {
+        return testVector{}, err
+    }
+    _, records, err := splitNative(context.Background(), v.SplitID, v.Secret, v.N, v.T, v.Field, 1, v.XCoordinates, v.Pad, random)
+    if err != nil {
+        return testVector{}, err
+    }
+    var dealer_key ed25519.PrivateKey
+    if v.Signed {
+        dealer_key = testVectorDealerKey(v.Seed)
+        v.DealerPublicKey = hex.EncodeToString(dealer_key.Public().(ed25519.PublicKey))
+    }
+    v.Shares = []testVectorShare{}
+    for _, record := range(records) {
+        if dealer_key != nil {
+            record.sign(dealer_key)
+        }
+        share := testVectorShare{X: record.index, Payload: record.payload, Records: map[string]string{"text": record.String()}}
+        for _, enc := range(shareEncodings) {
+            if share.Records[enc.name], err = encodeShareRecord(record, enc); err != nil {
+                return testVector{}, err
+            }
+        }
+        v.Shares = append(v.Shares, share)
+    }
+    return v, nil
+}
+
+// Checks that the secret of a vector is recovered from its first t and its
+// last t shares, both bare and as records in each encoding the vector has.
+// Signed records are also checked against the dealer key.
+func checkTestVector(v testVector) error {
+    var trusted_key ed25519.PublicKey
+    if v.DealerPublicKey != "" {
+        key, err := hex.DecodeString(v.DealerPublicKey)
+        if err != nil || len(key) != ed25519.PublicKeySize {
+            return fmt.Errorf("bad dealer public key %q", v.DealerPublicKey)
+        }
+        trusted_key = key
+    }
+    if len(v.Shares) < v.T || v.T < 1 {
+        return fmt.Errorf("%d shares for a threshold of %d", len(v.Shares), v.T)
+    }
+    encodings := []string{}
+    for name := range(v.Shares[0].Records) {
+        encodings = append(encodings, name)
+    }
+    sort.Strings(encodings)
+    for _, shares := range([][]testVectorShare{v.Shares[:v.T], v.Shares[len(v.Shares)-v.T:]}) {
+        bare := []string{}
+        for _, share := range(shares) {
+            bare = append(bare, share.X.String(), share.Payload)
+        }
+        secret, err := combineNative(context.Background(), bare, nil, v.Field, 1, nil)
+        if err != nil || secret != v.Secret {
+            return fmt.Errorf("bare shares: expecting %q, got: %q, %v", v.Secret, secret, err)
+        }
+        for _, name := range(encodings) {
+            tokens := []string{}
+            for _, share := range(shares) {
+                tokens = append(tokens, share.Records[name])
+            }
+            input, records, err := parseShareArgs(tokens)
+            if err == nil {
+                secret, err = combineNative(context.Background(), input, records, "", 1, trusted_key)
+            }
+            if err != nil || secret != v.Secret {
+                return fmt.Errorf("%s records: expecting %q, got: %q, %v", name, v.Secret, secret, err)
+            }
+        }
+    }
+    return nil
+}
+
+// Reads the vectors in the JSON files of dir, in name order.
+func readTestVectors(dir string) ([]testVector, error) {
+    paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+    if err != nil {
+        return nil, err
+    }
+    sort.Strings(paths)
+    vectors := []testVector{}
+    for _, path := range(paths) {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return nil, err
+        }
+        var v testVector
+        if err := json.Unmarshal(data, &v); err != nil {
+            return nil, fmt.Errorf("%s: %v", path, err)
+        }
+        vectors = append(vectors, v)
+    }
+    return vectors, nil
+}
+
+// Writes a vector as indented JSON, the form the golden files are kept in.
+func marshalTestVector(v testVector) []byte {
+    data, _ := json.MarshalIndent(v, "", "  ")
+    return append(data, '\n')
+}
+
+type jsonTestVector struct {
+    Name  string `json:"name"`
+    Valid bool   `json:"valid"`
+    Error string `json:"error,omitempty"`
+}
+
+type jsonTestVectors struct {
+    Version int              `json:"version"`
+    Valid   bool             `json:"valid"`
+    Vectors []jsonTestVector `json:"vectors"`
+}
+
+// testvectors -out writes the standard vectors to a directory, checking each
+// as it goes; testvectors -check checks the vectors already in a directory,
+// such as those of an earlier version.
+func testVectorsCommand(args []string) {
+    cmd := newFlagSet("testvectors")
+    outDir := cmd.String("out", "", "Directory to write the standard test vectors to, one JSON file each.")
+    checkDir := cmd.String("check", "", "Directory of test vectors to check still combine.")
+    format := addFormatFlag(cmd)
+    out := parseFlags(cmd, args, format)
+
+    if (*outDir == "") == (*checkDir == "") {
+        out.fail(EXIT_USAGE, "Give either -out or -check.")
+    }
+    vectors := []testVector{}
+    if *checkDir != "" {
+        var err error
+        if vectors, err = readTestVectors(*checkDir); err != nil {
+            out.fail(EXIT_IO, "Failed to read test vectors: " + err.Error())
+        }
+        if len(vectors) == 0 {
+            out.fail(EXIT_USAGE, fmt.Sprintf("No test vectors in %s.", *checkDir))
+        }
+    } else {
+        if err := os.MkdirAll(*outDir, 0755); err != nil {
+            out.fail(EXIT_IO, "Failed to create test vector directory: " + err.Error())
+        }
+        for _, spec := range(standardTestVectors) {
+            v, err := generateTestVector(spec)
+            if err != nil {
+                out.failWith(err)
+            }
+            if err := os.WriteFile(filepath.Join(*outDir, v.Name + ".json"), marshalTestVector(v), 0644); err != nil {
+                out.fail(EXIT_IO, "Failed to write test vector: " + err.Error())
+            }
+            vectors = append(vectors, v)
+        }
+    }
+
+    res := jsonTestVectors{JSON_SCHEMA_VERSION, true, []jsonTestVector{}}
+    for _, v := range(vectors) {
+        result := jsonTestVector{Name: v.Name, Valid: true}
+        if err := checkTestVector(v); err != nil {
+            result.Valid, result.Error = false, err.Error()
+            res.Valid = false
+        }
+        res.Vectors = append(res.Vectors, result)
+    }
+    if out.json {
+        out.emit(res)
+    } else {
+        for _, v := range(res.Vectors) {
+            if v.Valid {
+                fmt.Printf("%s: OK\n", v.Name)
+            } else {
+                fmt.Printf("%s: FAILED: %s\n", v.Name, v.Error)
+            }
+        }
+    }
+    if !res.Valid {
+        os.Exit(EXIT_INVALID_SHARES)
+    }
+}
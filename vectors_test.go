@@ -0,0 +1,85 @@
+package main
+
+import (
+    "bytes"
+    "crypto/ed25519"
+    "encoding/hex"
+    "fmt"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// The directory the current version's vectors are kept in.
+var testVectorDir = filepath.Join("testdata", "vectors", fmt.Sprintf("v%d", TEST_VECTOR_VERSION))
+
+// Splitting with the seed of each vector still gives exactly its shares. If
+// this fails after a deliberate change to split, bump TEST_VECTOR_VERSION and
+// write the new vectors with testvectors -out into their own directory,
+// keeping the old ones.
+func TestTestVectorsDeterministic(t *testing.T) {
+    names := map[string]bool{}
+    for _, spec := range(standardTestVectors) {
+        names[spec.Name + ".json"] = true
+        v, err := generateTestVector(spec)
+        if err != nil {
+            t.Fatalf("%s: %v", spec.Name, err)
+        }
+        golden, err := os.ReadFile(filepath.Join(testVectorDir, spec.Name + ".json"))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !bytes.Equal(marshalTestVector(v), golden) {
+            t.Errorf("%s: split no longer gives the shares of the golden file", spec.Name)
+        }
+    }
+    paths, _ := filepath.Glob(filepath.Join(testVectorDir, "*.json"))
+    for _, path := range(paths) {
+        if !names[filepath.Base(path)] {
+            t.Errorf("%s is not one of the standard test vectors", path)
+        }
+    }
+}
+
+// The shares of every version of the vectors, in every form they were
+// written in, still combine.
+func TestTestVectorsCombine(t *testing.T) {
+    dirs, _ := filepath.Glob(filepath.Join("testdata", "vectors", "v*"))
+    if len(dirs) == 0 {
+        t.Fatal("No test vectors found.")
+    }
+    for _, dir := range(dirs) {
+        vectors, err := readTestVectors(dir)
+        if err != nil {
+            t.Fatal(err)
+        }
+        for _, v := range(vectors) {
+            if err := checkTestVector(v); err != nil {
+                t.Errorf("%s/%s: %v", dir, v.Name, err)
+            }
+        }
+    }
+}
+
+func TestCheckTestVectorRejects(t *testing.T) {
+    v, err := generateTestVector(standardTestVectors[1])
+    if err != nil {
+        t.Fatal(err)
+    }
+    bad := v
+    bad.Secret = "something else"
+    if checkTestVector(bad) == nil {
+        t.Error("Expecting a vector with the wrong secret to fail")
+    }
+    // Records checked against some other key.
+    bad = v
+    bad.DealerPublicKey = hex.EncodeToString(testVectorDealerKey("other").Public().(ed25519.PublicKey))
+    if checkTestVector(bad) == nil {
+        t.Error("Expecting a vector with the wrong dealer key to fail")
+    }
+    bad = v
+    bad.T = len(v.Shares) + 1
+    if checkTestVector(bad) == nil {
+        t.Error("Expecting a vector with too few shares to fail")
+    }
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+    "context"
+    "runtime"
+    "sync"
+)
+
+// Splitting and combining work on each chunk of the secret on its own,
+// so chunks are shared out between a fixed number of workers in batches. This
+// keeps the number of goroutines and channel sends down to a few per worker
+// however large the secret or however many shares there are.
+
+// The number of chunks each worker takes at a time.
+const WORK_BATCH_SIZE = 64
+
+// The number of workers to use when asked for workers <= 0: one for each
+// thread Go will run at once.
+func defaultWorkers(workers int) int {
+    if workers <= 0 {
+        return runtime.GOMAXPROCS(0)
+    }
+    return workers
+}
+
+// Calls work(i) for every i in [0, jobs), on at most workers goroutines, each
+// taking batch consecutive values of i at a time. Stops handing out work at
+// the first error or once ctx is done, and returns that error.
+func runBatches(ctx context.Context, workers, jobs, batch int, work func(i int) error) error {
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+
+    workers = defaultWorkers(workers)
+    if batches := (jobs + batch - 1) / batch; batches < workers {
+        workers = batches
+    }
+    starts := make(chan int)
+    var once sync.Once
+    var res_err error
+    fail := func(err error) {
+        once.Do(func() {
+            res_err = err
+            cancel()
+        })
+    }
+
+    var wg sync.WaitGroup
+    for w := 0; w < workers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for start := range(starts) {
+                end := start + batch
+                if end > jobs {
+                    end = jobs
+                }
+                for i := start; i < end; i++ {
+                    if err := work(i); err != nil {
+                        fail(err)
+                        break
+                    }
+                }
+            }
+        }()
+    }
+
+    // Hand out the batches until they run out or the work is cancelled.
+    func() {
+        for start := 0; start < jobs; start += batch {
+            select {
+                case starts <- start:
+                case <-ctx.Done():
+                    fail(ctx.Err())
+                    return
+            }
+        }
+    }()
+    close(starts)
+    wg.Wait()
+    return res_err
+}
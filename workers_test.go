@@ -0,0 +1,119 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "errors"
+    "fmt"
+    "math/big"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+)
+
+func TestRunBatches(t *testing.T) {
+    for _, jobs := range([]int{0, 1, 63, 64, 65, 1000}) {
+        var mu sync.Mutex
+        done := make([]int, jobs)
+        var running, most int32
+        err := runBatches(context.Background(), 3, jobs, 7, func(i int) error {
+            now := atomic.AddInt32(&running, 1)
+            defer atomic.AddInt32(&running, -1)
+            mu.Lock()
+            done[i]++
+            if now > most {
+                most = now
+            }
+            mu.Unlock()
+            return nil
+        })
+        if err != nil {
+            t.Fatal(err)
+        }
+        for i, count := range(done) {
+            if count != 1 {
+                t.Errorf("Expecting job %d of %d to run once, ran %d times", i, jobs, count)
+            }
+        }
+        if most > 3 {
+            t.Errorf("Expecting at most 3 jobs at once, got: %d", most)
+        }
+    }
+}
+
+func TestRunBatchesStops(t *testing.T) {
+    // The first error is returned and no more batches are handed out.
+    var count int32
+    failure := errors.New("failure")
+    err := runBatches(context.Background(), 2, 10000, 10, func(i int) error {
+        atomic.AddInt32(&count, 1)
+        if i == 25 {
+            return failure
+        }
+        return nil
+    })
+    if err != failure || count > 1000 {
+        t.Errorf("Expecting an early failure, got: %v after %d jobs", err, count)
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, err := splitSecretAt(ctx, strings.Repeat("x", 10000), sequentialXCoordinates(3), 2, newPrimeField("1613", big.NewInt(1613)), 2, rand.Reader); !errors.Is(err, context.Canceled) {
+        t.Errorf("Expecting a cancelled split, got: %v", err)
+    }
+}
+
+func TestSplitCombineWorkers(t *testing.T) {
+    field, _ := lookupField(DEFAULT_FIELD)
+    secret := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 200)
+    for _, workers := range([]int{1, 3, 0}) {
+        shares, err := splitSecretAt(context.Background(), secret, sequentialXCoordinates(4), 3, field, workers, rand.Reader)
+        if err != nil {
+            t.Fatal(err)
+        }
+        result, err := combineSharesWith(context.Background(), []string{"4", shares[3], "2", shares[1], "1", shares[0]}, field, workers)
+        if err != nil || result != secret {
+            t.Errorf("Expecting the secret back with %d workers, got: %v", workers, err)
+        }
+    }
+}
+
+// Splitting and combining a 64 KiB secret into 20 shares, with one worker
+// and with GOMAXPROCS of them.
+func BenchmarkSplitLargeSecret(b *testing.B) {
+    field, _ := lookupField(DEFAULT_FIELD)
+    secret := strings.Repeat("0123456789abcdef", 4 * 1024)
+    for _, workers := range([]int{1, 0}) {
+        b.Run(map[int]string{1: "workers=1", 0: "workers=GOMAXPROCS"}[workers], func(b *testing.B) {
+            b.SetBytes(int64(len(secret)))
+            b.ReportAllocs()
+            for i := 0; i < b.N; i++ {
+                if _, err := splitSecretAt(context.Background(), secret, sequentialXCoordinates(20), 5, field, workers, rand.Reader); err != nil {
+                    b.Fatal(err)
+                }
+            }
+        })
+    }
+}
+
+func BenchmarkCombineLargeSecret(b *testing.B) {
+    field, _ := lookupField(DEFAULT_FIELD)
+    secret := strings.Repeat("0123456789abcdef", 4 * 1024)
+    shares, _ := splitSecret(secret, 20, 5, field)
+    input := []string{}
+    for i := 0; i < 5; i++ {
+        input = append(input, fmt.Sprint(i + 1), shares[i])
+    }
+    for _, workers := range([]int{1, 0}) {
+        b.Run(map[int]string{1: "workers=1", 0: "workers=GOMAXPROCS"}[workers], func(b *testing.B) {
+            b.SetBytes(int64(len(secret)))
+            b.ReportAllocs()
+            for i := 0; i < b.N; i++ {
+                if _, err := combineSharesWith(context.Background(), input, field, workers); err != nil {
+                    b.Fatal(err)
+                }
+            }
+        })
+    }
+}